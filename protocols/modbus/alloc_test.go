@@ -0,0 +1,182 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// 不分配内存的 RTU 从站，读寄存器返回地址加偏移作为值，读位返回交替的 1 和 0，写请求原样确认
+type staticDevice struct {
+	response []byte
+	pending  []byte
+}
+
+func newStaticDevice() *staticDevice {
+	return &staticDevice{response: make([]byte, 0, maxRTUFrameLength)}
+}
+
+func (d *staticDevice) Write(p []byte) (int, error) {
+	r := append(d.response[:0], p[0], p[1])
+	address := binary.BigEndian.Uint16(p[2:4])
+	quantity := binary.BigEndian.Uint16(p[4:6])
+	switch p[1] {
+	case FuncReadHoldingRegisters, FuncReadInputRegisters:
+		r = append(r, byte(quantity*2))
+		for i := range quantity {
+			r = binary.BigEndian.AppendUint16(r, address+i)
+		}
+	case FuncReadCoils, FuncReadDiscreteInputs:
+		r = append(r, byte((quantity+7)/8))
+		for range (quantity + 7) / 8 {
+			r = append(r, 0x55)
+		}
+	default:
+		r = append(r, p[2:6]...)
+	}
+	d.pending = appendCRC(r, 0)
+	return len(p), nil
+}
+
+func (d *staticDevice) Read(p []byte) (int, error) {
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func TestAppendRequests(t *testing.T) {
+	prefix := []byte{0xAA, 0xBB}
+	tests := []struct {
+		name   string
+		append []byte
+		want   []byte
+	}{
+		{"read coils", AppendReadCoilsRequest(prefix, 1, 10, 20), NewReadCoilsRequest(1, 10, 20)},
+		{"read discrete inputs", AppendReadDiscreteInputsRequest(prefix, 1, 10, 0), NewReadDiscreteInputsRequest(1, 10, 0)},
+		{"write single coil", AppendWriteSingleCoilRequest(prefix, 1, 10, true), NewWriteSingleCoilRequest(1, 10, true)},
+		{"write multiple coils", AppendWriteMultipleCoilsRequest(prefix, 1, 10, []bool{true, false, true, true, false, false, false, false, true}),
+			frame(1, FuncWriteMultipleCoils, 0, 10, 0, 9, 2, 0x0D, 0x01)},
+		{"read holding registers", AppendReadHoldingRegistersRequest(prefix, 1, 10, 126), frame(1, FuncReadHoldingRegisters, 0, 10, 0, 1)},
+		{"read input registers", AppendReadInputRegistersRequest(prefix, 1, 10, 2), NewReadInputRegistersRequest(1, 10, 2)},
+		{"write single register", AppendWriteSingleRegisterRequest(prefix, 1, 10, 0x1234), NewWriteSingleRegisterRequest(1, 10, 0x1234)},
+		{"write multiple registers", AppendWriteMultipleRegistersRequest(prefix, 1, 10, []uint16{0x0102, 0x0304}),
+			frame(1, FuncWriteMultipleRegisters, 0, 10, 0, 2, 4, 1, 2, 3, 4)},
+	}
+	for _, tt := range tests {
+		if !bytes.HasPrefix(tt.append, prefix) || !bytes.Equal(tt.append[len(prefix):], tt.want) {
+			t.Errorf("%s: got % X, want prefix + % X", tt.name, tt.append, tt.want)
+		}
+	}
+
+	if AppendWriteMultipleRegistersRequest(nil, 1, 0, make([]uint16, 124)) != nil {
+		t.Error("124 registers accepted")
+	}
+	if AppendWriteMultipleCoilsRequest(nil, 1, 0, nil) != nil {
+		t.Error("empty coil write accepted")
+	}
+
+	// 容量足够时写入调用方的缓冲区
+	buf := make([]byte, 0, 16)
+	if out := AppendReadHoldingRegistersRequest(buf, 1, 0, 1); &out[0] != &buf[:1][0] {
+		t.Error("request not built in the caller's buffer")
+	}
+}
+
+func TestReadInto(t *testing.T) {
+	client := NewClient(newStaticDevice(), 1).SetInterFrameDelay(0)
+
+	registers := make([]uint16, 3)
+	if err := client.ReadHoldingRegistersInto(registers, 100); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(registers, []uint16{100, 101, 102}) {
+		t.Errorf("registers = %v", registers)
+	}
+	if err := client.ReadInputRegistersInto(registers[:1], 7); err != nil || registers[0] != 7 {
+		t.Errorf("input register = %d, %v", registers[0], err)
+	}
+
+	bits := make([]bool, 10)
+	if err := client.ReadCoilsInto(bits, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{true, false, true, false, true, false, true, false, true, false}
+	if !slices.Equal(bits, want) {
+		t.Errorf("coils = %v", bits)
+	}
+	if err := client.ReadDiscreteInputsInto(bits[:3], 0); err != nil || !slices.Equal(bits[:3], want[:3]) {
+		t.Errorf("discrete inputs = %v, %v", bits[:3], err)
+	}
+
+	var opErr *OpError
+	if err := client.ReadHoldingRegistersInto(make([]uint16, 126), 0); !errors.Is(err, ErrInvalidLength) || !errors.As(err, &opErr) {
+		t.Errorf("126 registers: %v, want invalid length", err)
+	}
+	if err := client.ReadCoilsInto(nil, 0); !errors.Is(err, ErrInvalidLength) {
+		t.Errorf("no coils: %v, want invalid length", err)
+	}
+
+	// 从站返回的寄存器数量与请求不一致
+	short := &scriptedTransport{responses: [][]byte{frame(1, FuncReadHoldingRegisters, 2, 0, 1)}}
+	err := NewClient(short, 1).SetInterFrameDelay(0).ReadHoldingRegistersInto(registers, 0)
+	if !errors.Is(err, ErrQuantityMismatch) {
+		t.Errorf("short response: %v, want quantity mismatch", err)
+	}
+}
+
+func TestZeroAllocs(t *testing.T) {
+	client := NewClient(newStaticDevice(), 1).SetInterFrameDelay(0)
+	registers := make([]uint16, 125)
+	bits := make([]bool, 2000)
+	values := make([]uint16, 123)
+
+	tests := map[string]func() error{
+		"ReadHoldingRegistersInto": func() error { return client.ReadHoldingRegistersInto(registers, 0) },
+		"ReadInputRegistersInto":   func() error { return client.ReadInputRegistersInto(registers[:10], 0) },
+		"ReadCoilsInto":            func() error { return client.ReadCoilsInto(bits, 0) },
+		"ReadDiscreteInputsInto":   func() error { return client.ReadDiscreteInputsInto(bits[:5], 0) },
+		"WriteSingleRegister":      func() error { return client.WriteSingleRegister(1, 2) },
+		"WriteMultipleRegisters":   func() error { return client.WriteMultipleRegisters(0, values) },
+		"WriteSingleCoil":          func() error { return client.WriteSingleCoil(1, true) },
+		"WriteMultipleCoils":       func() error { return client.WriteMultipleCoils(0, bits[:16]) },
+	}
+	for name, call := range tests {
+		if err := call(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if allocs := testing.AllocsPerRun(100, func() { call() }); allocs != 0 {
+			t.Errorf("%s: %.1f allocations per call, want 0", name, allocs)
+		}
+	}
+}
+
+func BenchmarkAppendReadHoldingRegistersRequest(b *testing.B) {
+	buf := make([]byte, 0, maxRTUFrameLength)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = AppendReadHoldingRegistersRequest(buf[:0], 1, 0, 125)
+	}
+}
+
+func BenchmarkReadHoldingRegisters(b *testing.B) {
+	client := NewClient(newStaticDevice(), 1).SetInterFrameDelay(0)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := client.ReadHoldingRegisters(0, 125); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadHoldingRegistersInto(b *testing.B) {
+	client := NewClient(newStaticDevice(), 1).SetInterFrameDelay(0)
+	registers := make([]uint16, 125)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := client.ReadHoldingRegistersInto(registers, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package modbus
+
+import "errors"
+
+// batch.go 实现了按顺序执行一组读写操作的批处理
+
+// ErrBatchAborted 是批处理因前面的操作失败而没有执行的操作的错误
+var ErrBatchAborted = errors.New("modbus: batch aborted")
+
+// BatchResult 是批处理中一个操作的结果
+type BatchResult struct {
+	FunctionCode byte     // 功能码
+	Address      uint16   // 起始地址
+	Quantity     uint16   // 数量
+	Bits         []bool   // 读线圈和离散输入的结果
+	Registers    []uint16 // 读寄存器的结果
+	Response     []byte   // Send 的响应 PDU
+	Err          error    // 操作的错误，没有执行的操作为 ErrBatchAborted
+}
+
+// Batch 是按顺序执行的一组读写操作，通过 Client.NewBatch 创建
+//
+// 例如"写设定值、写使能线圈、读状态"：
+//
+//	results, err := client.NewBatch().
+//		SetStopOnError(true).
+//		WriteSingleRegister(100, 1500).
+//		WriteSingleCoil(10, true).
+//		ReadInputRegisters(0, 4).
+//		Execute()
+//
+// RTU 客户端在整个批处理期间持有总线锁，其他协程的请求不会插入到批处理的操作之间；
+// Transactor 客户端（TCP、UDP）上批处理的操作按顺序执行，但不阻止其他协程的并发请求。
+type Batch struct {
+	client      *Client
+	ops         []batchOp
+	stopOnError bool
+}
+
+// 批处理中的一个操作
+type batchOp struct {
+	result BatchResult
+	run    func(c *Client, r *BatchResult) error
+}
+
+// NewBatch 创建一个空的批处理
+func (c *Client) NewBatch() *Batch {
+	return &Batch{client: c}
+}
+
+// SetStopOnError 设置是否在第一个失败的操作后停止，默认继续执行后面的操作
+func (b *Batch) SetStopOnError(stop bool) *Batch {
+	b.stopOnError = stop
+	return b
+}
+
+// Len 返回批处理中的操作数量
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// 添加一个操作
+func (b *Batch) add(functionCode byte, address, quantity uint16, run func(c *Client, r *BatchResult) error) *Batch {
+	b.ops = append(b.ops, batchOp{
+		result: BatchResult{FunctionCode: functionCode, Address: address, Quantity: quantity},
+		run:    run,
+	})
+	return b
+}
+
+// ReadCoils 添加读取线圈状态的操作，结果在 BatchResult.Bits 中
+func (b *Batch) ReadCoils(startAddress, quantity uint16) *Batch {
+	return b.add(FuncReadCoils, startAddress, quantity, func(c *Client, r *BatchResult) (err error) {
+		r.Bits, err = c.ReadCoils(startAddress, quantity)
+		return err
+	})
+}
+
+// ReadDiscreteInputs 添加读取离散输入状态的操作，结果在 BatchResult.Bits 中
+func (b *Batch) ReadDiscreteInputs(startAddress, quantity uint16) *Batch {
+	return b.add(FuncReadDiscreteInputs, startAddress, quantity, func(c *Client, r *BatchResult) (err error) {
+		r.Bits, err = c.ReadDiscreteInputs(startAddress, quantity)
+		return err
+	})
+}
+
+// ReadHoldingRegisters 添加读取保持寄存器的操作，结果在 BatchResult.Registers 中
+func (b *Batch) ReadHoldingRegisters(startAddress, quantity uint16) *Batch {
+	return b.add(FuncReadHoldingRegisters, startAddress, quantity, func(c *Client, r *BatchResult) (err error) {
+		r.Registers, err = c.ReadHoldingRegisters(startAddress, quantity)
+		return err
+	})
+}
+
+// ReadInputRegisters 添加读取输入寄存器的操作，结果在 BatchResult.Registers 中
+func (b *Batch) ReadInputRegisters(startAddress, quantity uint16) *Batch {
+	return b.add(FuncReadInputRegisters, startAddress, quantity, func(c *Client, r *BatchResult) (err error) {
+		r.Registers, err = c.ReadInputRegisters(startAddress, quantity)
+		return err
+	})
+}
+
+// WriteSingleCoil 添加写单个线圈的操作
+func (b *Batch) WriteSingleCoil(address uint16, value bool, opts ...WriteOption) *Batch {
+	return b.add(FuncWriteSingleCoil, address, 1, func(c *Client, r *BatchResult) error {
+		return c.WriteSingleCoil(address, value, opts...)
+	})
+}
+
+// WriteMultipleCoils 添加写多个线圈的操作
+func (b *Batch) WriteMultipleCoils(startAddress uint16, values []bool, opts ...WriteOption) *Batch {
+	return b.add(FuncWriteMultipleCoils, startAddress, uint16(len(values)), func(c *Client, r *BatchResult) error {
+		return c.WriteMultipleCoils(startAddress, values, opts...)
+	})
+}
+
+// WriteSingleRegister 添加写单个寄存器的操作
+func (b *Batch) WriteSingleRegister(address, value uint16, opts ...WriteOption) *Batch {
+	return b.add(FuncWriteSingleRegister, address, 1, func(c *Client, r *BatchResult) error {
+		return c.WriteSingleRegister(address, value, opts...)
+	})
+}
+
+// WriteMultipleRegisters 添加写多个寄存器的操作
+func (b *Batch) WriteMultipleRegisters(startAddress uint16, values []uint16, opts ...WriteOption) *Batch {
+	return b.add(FuncWriteMultipleRegisters, startAddress, uint16(len(values)), func(c *Client, r *BatchResult) error {
+		return c.WriteMultipleRegisters(startAddress, values, opts...)
+	})
+}
+
+// Send 添加向指定从站发送原始 PDU 的操作，响应在 BatchResult.Response 中
+func (b *Batch) Send(slaveID byte, pdu []byte) *Batch {
+	var functionCode byte
+	var address, quantity uint16
+	if len(pdu) > 0 {
+		functionCode = pdu[0]
+		address, quantity, _ = RequestAddressRange(functionCode, pdu[1:])
+	}
+	return b.add(functionCode, address, quantity, func(c *Client, r *BatchResult) (err error) {
+		r.Response, err = c.Send(slaveID, pdu)
+		return err
+	})
+}
+
+// Execute 按添加的顺序执行所有操作，返回每个操作的结果和第一个错误
+//
+// 设置了 SetStopOnError 时，第一个失败的操作之后的操作不再执行，其 Err 为 ErrBatchAborted。
+// 批处理可以重复执行。
+func (b *Batch) Execute() ([]BatchResult, error) {
+	c := b.client
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+
+	// 副本与客户端共享总线状态，执行操作时不再加锁
+	held := *c
+	held.busHeld = true
+
+	results := make([]BatchResult, len(b.ops))
+	var firstErr error
+	for i, op := range b.ops {
+		results[i] = op.result
+		if firstErr != nil && b.stopOnError {
+			results[i].Err = ErrBatchAborted
+			continue
+		}
+		if err := op.run(&held, &results[i]); err != nil {
+			results[i].Err = err
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return results, firstErr
+}
@@ -0,0 +1,113 @@
+package modbus
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 由数据模型应答的 RTU 总线，记录每个请求的起始地址
+func modelBus(t *testing.T, model *DataModel, log *[]uint16, mu *sync.Mutex) *rtuBus {
+	return &rtuBus{t: t, handle: func(request []byte) []byte {
+		req := &Request{UnitID: request[0], FunctionCode: request[1], Data: request[2 : len(request)-2]}
+		address, _, _ := req.AddressRange()
+		mu.Lock()
+		*log = append(*log, address)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return pduToRTU(req.UnitID, handleRequest(model, nil, req))
+	}}
+}
+
+func TestBatch(t *testing.T) {
+	var (
+		log []uint16
+		mu  sync.Mutex
+	)
+	model := NewDataModel(100)
+	model.Write(TableInputRegisters, 0, 7, 8)
+	client := NewClient(modelBus(t, model, &log, &mu), 1).SetInterFrameDelay(0)
+
+	batch := client.NewBatch().
+		WriteSingleRegister(10, 1500).
+		WriteSingleCoil(3, true).
+		ReadHoldingRegisters(99, 2). // 超出范围
+		ReadInputRegisters(0, 2).
+		ReadCoils(3, 1).
+		Send(1, []byte{FuncReadHoldingRegisters, 0, 10, 0, 1})
+	results, err := batch.Execute()
+	if !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("Execute() error = %v, want illegal data address", err)
+	}
+	if len(results) != batch.Len() {
+		t.Fatalf("got %d results, want %d", len(results), batch.Len())
+	}
+	for i, r := range results {
+		if (r.Err != nil) != (i == 2) {
+			t.Errorf("result %d error = %v", i, r.Err)
+		}
+	}
+	if results[2].FunctionCode != FuncReadHoldingRegisters || results[2].Address != 99 || results[2].Quantity != 2 {
+		t.Errorf("result 2 = %+v", results[2])
+	}
+	if !slices.Equal(results[3].Registers, []uint16{7, 8}) || !slices.Equal(results[4].Bits, []bool{true}) {
+		t.Errorf("read results = %v, %v", results[3].Registers, results[4].Bits)
+	}
+	if want := []byte{FuncReadHoldingRegisters, 2, 0x05, 0xDC}; !slices.Equal(results[5].Response, want) {
+		t.Errorf("send response = % X, want % X", results[5].Response, want)
+	}
+
+	// 失败后停止
+	results, err = batch.SetStopOnError(true).Execute()
+	if err == nil || results[1].Err != nil || !errors.Is(results[3].Err, ErrBatchAborted) || !errors.Is(results[5].Err, ErrBatchAborted) {
+		t.Errorf("stop on error: err = %v, results = %+v", err, results)
+	}
+}
+
+func TestBatchHoldsBus(t *testing.T) {
+	var (
+		log []uint16
+		mu  sync.Mutex
+	)
+	client := NewClient(modelBus(t, NewDataModel(1000), &log, &mu), 1).SetInterFrameDelay(0)
+
+	batch := client.NewBatch()
+	for i := range 10 {
+		batch.ReadHoldingRegisters(uint16(100+i), 1)
+	}
+
+	// 其他协程同时发送请求，批处理的操作之间不能插入其他请求
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 10 {
+			client.ReadHoldingRegisters(uint16(500+i), 1)
+		}
+	}()
+	time.Sleep(2 * time.Millisecond)
+	if _, err := batch.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	requests := slices.Clone(log)
+	mu.Unlock()
+	start := slices.Index(requests, 100)
+	if start < 0 || start+10 > len(requests) {
+		t.Fatalf("batch requests missing from %v", requests)
+	}
+	for i := range 10 {
+		if requests[start+i] != uint16(100+i) {
+			t.Fatalf("batch interleaved with other requests: %v", requests)
+		}
+	}
+
+	// 批处理之后客户端仍然可以正常使用
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,197 @@
+package modbus
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// breaker.go 实现了按从站 ID 隔离的熔断器，避免一个故障设备拖慢整条总线
+
+// ErrCircuitOpen 表示目标从站的熔断器处于打开状态，请求未被发送
+var ErrCircuitOpen = errors.New("modbus: circuit breaker open")
+
+// BreakerState 表示熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 关闭：请求正常发送
+	BreakerOpen                         // 打开：请求直接失败
+	BreakerHalfOpen                     // 半开：放行少量探测请求
+)
+
+// String 返回熔断器状态的名称
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker 是按从站 ID 维护状态的熔断器
+//
+// 某个从站连续失败 FailureThreshold 次后熔断器打开，之后的请求直接返回 ErrCircuitOpen；
+// 经过 OpenTimeout 后进入半开状态，放行 HalfOpenRequests 个探测请求，
+// 探测成功则关闭熔断器，失败则重新打开。
+// 从站返回的异常响应说明设备在线，不计为失败（网关路径不可用和目标设备无响应除外）。
+// 在状态变化之前放行、之后才完成的请求的结果被忽略，不会影响新的状态。
+type CircuitBreaker struct {
+	FailureThreshold int                                       // 打开熔断器所需的连续失败次数
+	OpenTimeout      time.Duration                             // 打开状态持续时间
+	HalfOpenRequests int                                       // 半开状态下允许同时进行的探测请求数
+	IsFailure        func(err error) bool                      // 判断错误是否计为失败，nil 时使用默认规则
+	OnStateChange    func(slaveID byte, from, to BreakerState) // 状态变化回调，在锁外调用
+
+	mu      sync.Mutex
+	devices map[byte]*breakerDevice
+	now     func() time.Time
+}
+
+// 单个从站的熔断状态
+type breakerDevice struct {
+	state      BreakerState
+	failures   int       // 连续失败次数
+	openedAt   time.Time // 进入打开状态的时间
+	probes     int       // 半开状态下进行中的探测请求数
+	generation uint64    // 每次状态变化时递增，用于识别过期的请求结果
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+		HalfOpenRequests: 1,
+	}
+}
+
+// State 返回指定从站当前的熔断器状态
+func (b *CircuitBreaker) State(slaveID byte) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if d, ok := b.devices[slaveID]; ok {
+		return d.state
+	}
+	return BreakerClosed
+}
+
+// Reset 将指定从站的熔断器恢复为关闭状态
+func (b *CircuitBreaker) Reset(slaveID byte) {
+	b.mu.Lock()
+	d := b.device(slaveID)
+	from := d.state
+	*d = breakerDevice{generation: d.generation + 1}
+	b.mu.Unlock()
+
+	b.notify(slaveID, from, BreakerClosed)
+}
+
+// allow 判断是否允许向指定从站发送请求，返回放行时的状态代数，由 record 使用
+func (b *CircuitBreaker) allow(slaveID byte) (uint64, error) {
+	b.mu.Lock()
+	d := b.device(slaveID)
+	from := d.state
+
+	switch d.state {
+	case BreakerOpen:
+		if b.clock().Sub(d.openedAt) < b.OpenTimeout {
+			b.mu.Unlock()
+			return 0, ErrCircuitOpen
+		}
+		d.state = BreakerHalfOpen
+		d.probes = 1
+		d.generation++
+	case BreakerHalfOpen:
+		if d.probes >= max(b.HalfOpenRequests, 1) {
+			b.mu.Unlock()
+			return 0, ErrCircuitOpen
+		}
+		d.probes++
+	}
+	to, generation := d.state, d.generation
+	b.mu.Unlock()
+
+	b.notify(slaveID, from, to)
+	return generation, nil
+}
+
+// record 记录一次请求的结果，generation 为 allow 返回的状态代数
+// 放行后熔断器的状态已经变化时，结果已经过期，不再记录
+func (b *CircuitBreaker) record(slaveID byte, generation uint64, err error) {
+	b.mu.Lock()
+	d := b.device(slaveID)
+	from := d.state
+	if d.generation != generation {
+		b.mu.Unlock()
+		return
+	}
+
+	if !b.isFailure(err) {
+		if d.state != BreakerClosed {
+			d.state = BreakerClosed
+			d.generation++
+		}
+		d.failures, d.probes = 0, 0
+	} else {
+		d.failures++
+		if d.state == BreakerHalfOpen || d.failures >= b.FailureThreshold {
+			d.state = BreakerOpen
+			d.openedAt = b.clock()
+			d.probes = 0
+			d.generation++
+		}
+	}
+	to := d.state
+	b.mu.Unlock()
+
+	b.notify(slaveID, from, to)
+}
+
+// 判断错误是否计为失败
+func (b *CircuitBreaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+	var modbusErr *ModbusError
+	if errors.As(err, &modbusErr) {
+		return modbusErr.ExceptionCode == ExcGatewayPathUnavailable ||
+			modbusErr.ExceptionCode == ExcGatewayTargetDeviceFailedToRespond
+	}
+	return true
+}
+
+// 获取从站状态，调用者需持有锁
+func (b *CircuitBreaker) device(slaveID byte) *breakerDevice {
+	if b.devices == nil {
+		b.devices = make(map[byte]*breakerDevice)
+	}
+	d, ok := b.devices[slaveID]
+	if !ok {
+		d = &breakerDevice{}
+		b.devices[slaveID] = d
+	}
+	return d
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+func (b *CircuitBreaker) notify(slaveID byte, from, to BreakerState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(slaveID, from, to)
+	}
+}
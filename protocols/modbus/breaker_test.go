@@ -0,0 +1,138 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type breakerTransition struct {
+	slaveID  byte
+	from, to BreakerState
+}
+
+// 测试熔断器在连续失败后打开、超时后半开探测并在成功后关闭
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	var transitions []breakerTransition
+
+	breaker := NewCircuitBreaker(2, 10*time.Second)
+	breaker.now = func() time.Time { return now }
+	breaker.OnStateChange = func(slaveID byte, from, to BreakerState) {
+		transitions = append(transitions, breakerTransition{slaveID, from, to})
+	}
+
+	transport := &scriptedTransport{responses: [][]byte{
+		corruptFrame(0x05, 0x03, 0x02, 0x00, 0x01),
+		corruptFrame(0x05, 0x03, 0x02, 0x00, 0x01),
+		corruptFrame(0x05, 0x03, 0x02, 0x00, 0x01),
+		frame(0x05, 0x03, 0x02, 0x00, 0x01),
+	}}
+	client := NewClient(transport, 0x05).SetInterFrameDelay(0).SetCircuitBreaker(breaker)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrCRCMismatch) {
+			t.Fatalf("ReadHoldingRegisters() #%d error = %v, want ErrCRCMismatch", i, err)
+		}
+	}
+	if state := breaker.State(0x05); state != BreakerOpen {
+		t.Fatalf("State() = %v, want open", state)
+	}
+
+	// 打开状态下直接失败，不访问总线
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("ReadHoldingRegisters() error = %v, want ErrCircuitOpen", err)
+	}
+	if transport.writes != 2 {
+		t.Errorf("request sent %d times, want 2", transport.writes)
+	}
+
+	// 其他从站不受影响
+	if state := breaker.State(0x06); state != BreakerClosed {
+		t.Errorf("State(0x06) = %v, want closed", state)
+	}
+
+	// 半开探测失败后重新打开
+	now = now.Add(11 * time.Second)
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrCRCMismatch) {
+		t.Fatalf("probe error = %v, want ErrCRCMismatch", err)
+	}
+	if state := breaker.State(0x05); state != BreakerOpen {
+		t.Fatalf("State() after failed probe = %v, want open", state)
+	}
+
+	// 半开探测成功后关闭
+	now = now.Add(11 * time.Second)
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if state := breaker.State(0x05); state != BreakerClosed {
+		t.Fatalf("State() after successful probe = %v, want closed", state)
+	}
+
+	want := []breakerTransition{
+		{0x05, BreakerClosed, BreakerOpen},
+		{0x05, BreakerOpen, BreakerHalfOpen},
+		{0x05, BreakerHalfOpen, BreakerOpen},
+		{0x05, BreakerOpen, BreakerHalfOpen},
+		{0x05, BreakerHalfOpen, BreakerClosed},
+	}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition[%d] = %v, want %v", i, transitions[i], want[i])
+		}
+	}
+}
+
+// 测试异常响应不计为失败
+func TestCircuitBreakerIgnoresExceptions(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Minute)
+	transport := &scriptedTransport{responses: [][]byte{
+		frame(0x01, 0x83, ExcIllegalDataAddress),
+		frame(0x01, 0x83, ExcGatewayTargetDeviceFailedToRespond),
+	}}
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).SetCircuitBreaker(breaker)
+
+	client.ReadHoldingRegisters(0, 1)
+	if state := breaker.State(0x01); state != BreakerClosed {
+		t.Errorf("State() after illegal data address = %v, want closed", state)
+	}
+
+	client.ReadHoldingRegisters(0, 1)
+	if state := breaker.State(0x01); state != BreakerOpen {
+		t.Errorf("State() after gateway target failure = %v, want open", state)
+	}
+}
+
+// 测试状态变化之前放行的请求的结果被忽略
+func TestCircuitBreakerIgnoresStaleOutcomes(t *testing.T) {
+	now := time.Unix(0, 0)
+	breaker := NewCircuitBreaker(1, 10*time.Second)
+	breaker.HalfOpenRequests = 2
+	breaker.now = func() time.Time { return now }
+
+	// 关闭状态下放行的慢请求在熔断器打开后才成功
+	slow, _ := breaker.allow(0x01)
+	failed, _ := breaker.allow(0x01)
+	breaker.record(0x01, failed, ErrTimeout)
+	breaker.record(0x01, slow, nil)
+	if state := breaker.State(0x01); state != BreakerOpen {
+		t.Fatalf("State() after stale success = %v, want open", state)
+	}
+
+	// 半开状态下第一个探测成功后，另一个探测的失败不会重新打开熔断器
+	now = now.Add(11 * time.Second)
+	first, _ := breaker.allow(0x01)
+	second, err := breaker.allow(0x01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker.record(0x01, first, nil)
+	breaker.record(0x01, second, ErrTimeout)
+	if state := breaker.State(0x01); state != BreakerClosed {
+		t.Errorf("State() after stale probe failure = %v, want closed", state)
+	}
+}
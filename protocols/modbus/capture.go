@@ -0,0 +1,253 @@
+package modbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// capture.go 实现了收发报文的录制和回放，用于在测试中复现现场问题
+
+// 录制文件中的数据方向
+const (
+	CaptureTx = "tx" // 主站发送
+	CaptureRx = "rx" // 主站接收
+)
+
+// ErrReplayDiverged 表示回放时的请求与录制的内容不一致
+var ErrReplayDiverged = errors.New("modbus: replay diverged from capture")
+
+// CaptureRecord 是录制文件中的一条记录
+//
+// 录制文件为 JSON Lines 格式，每行一条记录，例如：
+//
+//	{"time":"2024-05-01T12:00:00.000123Z","dir":"tx","data":"01030064000285d4"}
+type CaptureRecord struct {
+	Time time.Time // 读写完成的时间
+	Dir  string    // CaptureTx 或 CaptureRx
+	Data []byte    // 一次读写的数据
+}
+
+// JSON 格式的记录，数据使用十六进制
+type captureJSON struct {
+	Time time.Time `json:"time"`
+	Dir  string    `json:"dir"`
+	Data string    `json:"data"`
+}
+
+// MarshalJSON 实现 json.Marshaler 接口
+func (r CaptureRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(captureJSON{Time: r.Time, Dir: r.Dir, Data: hex.EncodeToString(r.Data)})
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler 接口
+func (r *CaptureRecord) UnmarshalJSON(b []byte) error {
+	var v captureJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.Dir != CaptureTx && v.Dir != CaptureRx {
+		return fmt.Errorf("modbus: invalid capture direction %q", v.Dir)
+	}
+	data, err := hex.DecodeString(v.Data)
+	if err != nil {
+		return fmt.Errorf("modbus: invalid capture data: %w", err)
+	}
+	*r = CaptureRecord{Time: v.Time, Dir: v.Dir, Data: data}
+	return nil
+}
+
+// ReadCapture 读取录制文件中的全部记录
+func ReadCapture(r io.Reader) ([]CaptureRecord, error) {
+	var records []CaptureRecord
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("modbus: capture line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Recorder 包装传递给 NewClient 的传输接口，把每次读写的数据带时间戳写入录制文件
+//
+// 传输接口支持 SetReadDeadline、Flush 时 Recorder 会转发。
+// Recorder 不提供串口线路参数，使用串口时需要手动调用 Client.SetSerialConfig。
+type Recorder struct {
+	transport io.ReadWriter
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error // 第一次写入录制文件的错误
+}
+
+// NewRecorder 创建录制传输接口，记录写入 w
+func NewRecorder(transport io.ReadWriter, w io.Writer) *Recorder {
+	return &Recorder{transport: transport, enc: json.NewEncoder(w)}
+}
+
+// Write 实现 io.Writer 接口
+func (r *Recorder) Write(p []byte) (int, error) {
+	n, err := r.transport.Write(p)
+	if n > 0 {
+		r.record(CaptureTx, p[:n])
+	}
+	return n, err
+}
+
+// Read 实现 io.Reader 接口
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.transport.Read(p)
+	if n > 0 {
+		r.record(CaptureRx, p[:n])
+	}
+	return n, err
+}
+
+// SetReadDeadline 在传输接口支持时设置读截止时间，否则不做任何处理
+func (r *Recorder) SetReadDeadline(t time.Time) error {
+	if d, ok := r.transport.(ReadDeadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
+
+// Flush 在传输接口支持时丢弃接收缓冲区中的数据，否则不做任何处理
+func (r *Recorder) Flush() error {
+	if f, ok := r.transport.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Err 返回写入录制文件时遇到的第一个错误
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(dir string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(CaptureRecord{Time: time.Now(), Dir: dir, Data: append([]byte(nil), data...)})
+}
+
+// Replayer 按录制文件回放通信
+//
+// 作为传输接口传递给 NewClient 时扮演从站：每次写入必须与下一条发送记录完全一致，
+// 否则返回 ErrReplayDiverged；之后的读取依次返回录制的接收数据，没有接收数据时返回 ErrTimeout。
+// 回放不等待录制时的时间间隔，结果是确定的。PlayMaster 则扮演主站，把录制的请求发送给服务器。
+type Replayer struct {
+	mu      sync.Mutex
+	records []CaptureRecord
+	next    int // 下一条待回放的记录
+}
+
+// NewReplayer 从录制文件创建回放传输接口
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	records, err := ReadCapture(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{records: records}, nil
+}
+
+// Write 实现 io.Writer 接口
+func (r *Replayer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 跳过上一个请求未被读取的响应
+	for r.next < len(r.records) && r.records[r.next].Dir == CaptureRx {
+		r.next++
+	}
+	if r.next == len(r.records) {
+		return 0, fmt.Errorf("%w: unexpected request [% x] after end of capture", ErrReplayDiverged, p)
+	}
+
+	record := r.records[r.next]
+	if string(record.Data) != string(p) {
+		return 0, fmt.Errorf("%w: record %d: sent [% x], captured [% x]", ErrReplayDiverged, r.next+1, p, record.Data)
+	}
+	r.next++
+	return len(p), nil
+}
+
+// Read 实现 io.Reader 接口，每次返回一条接收记录，录制的数据比 p 长时分多次返回
+func (r *Replayer) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next == len(r.records) || r.records[r.next].Dir != CaptureRx {
+		return 0, ErrTimeout
+	}
+	record := &r.records[r.next]
+	n := copy(p, record.Data)
+	if n < len(record.Data) {
+		record.Data = record.Data[n:]
+	} else {
+		r.next++
+	}
+	return n, nil
+}
+
+// Remaining 返回尚未回放的记录数
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.records) - r.next
+}
+
+// PlayMaster 扮演主站，把录制的请求依次写入 rw（例如连接到 RTU 从站的串口），
+// 并检查收到的响应与录制的响应一致，不一致时返回 ErrReplayDiverged。
+// 录制中没有响应的请求不等待响应。rw 应当设置读超时，否则从站不响应时会一直阻塞
+func (r *Replayer) PlayMaster(rw io.ReadWriter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for r.next < len(r.records) {
+		record := r.records[r.next]
+		if record.Dir != CaptureTx {
+			return fmt.Errorf("%w: record %d: response without request", ErrReplayDiverged, r.next+1)
+		}
+		if _, err := rw.Write(record.Data); err != nil {
+			return err
+		}
+		r.next++
+
+		// 收集这个请求之后录制的全部接收数据
+		var expected []byte
+		for r.next < len(r.records) && r.records[r.next].Dir == CaptureRx {
+			expected = append(expected, r.records[r.next].Data...)
+			r.next++
+		}
+		if len(expected) == 0 {
+			continue
+		}
+
+		got := make([]byte, len(expected))
+		if n, err := io.ReadFull(rw, got); err != nil {
+			return fmt.Errorf("%w: response to [% x]: got [% x], captured [% x]: %w",
+				ErrReplayDiverged, record.Data, got[:n], expected, err)
+		}
+		if string(got) != string(expected) {
+			return fmt.Errorf("%w: response to [% x]: got [% x], captured [% x]",
+				ErrReplayDiverged, record.Data, got, expected)
+		}
+	}
+	return nil
+}
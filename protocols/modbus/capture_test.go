@@ -0,0 +1,108 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 录制一段与 gatewayBus 的通信
+func recordSession(t *testing.T) []byte {
+	t.Helper()
+	var capture bytes.Buffer
+	recorder := NewRecorder(gatewayBus(t), &capture)
+	client := NewClient(recorder, 1).SetInterFrameDelay(0)
+
+	if _, err := client.ReadHoldingRegisters(42, 1); err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if _, err := client.ReadHoldingRegisters(9999, 1); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("ReadHoldingRegisters(9999) error = %v", err)
+	}
+	if _, err := client.SetSlaveID(2).ReadHoldingRegisters(0, 1); !IsTimeout(err) {
+		t.Fatalf("slave 2 ReadHoldingRegisters() error = %v, want timeout", err)
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("recorder error = %v", err)
+	}
+	return capture.Bytes()
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	capture := recordSession(t)
+	if lines := strings.Count(string(capture), "\n"); lines != 5 {
+		t.Fatalf("capture has %d records, want 5:\n%s", lines, capture)
+	}
+
+	replayer, err := NewReplayer(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+	client := NewClient(replayer, 1).SetInterFrameDelay(0)
+
+	registers, err := client.ReadHoldingRegisters(42, 1)
+	if err != nil || registers[0] != 42 {
+		t.Errorf("replayed ReadHoldingRegisters() = %v, %v", registers, err)
+	}
+	if _, err := client.ReadHoldingRegisters(9999, 1); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("replayed ReadHoldingRegisters(9999) error = %v", err)
+	}
+	if _, err := client.SetSlaveID(2).ReadHoldingRegisters(0, 1); !IsTimeout(err) {
+		t.Errorf("replayed slave 2 error = %v, want timeout", err)
+	}
+	if n := replayer.Remaining(); n != 0 {
+		t.Errorf("Remaining() = %d, want 0", n)
+	}
+
+	// 超出录制范围的请求
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrReplayDiverged) {
+		t.Errorf("request after end error = %v, want ErrReplayDiverged", err)
+	}
+}
+
+func TestReplayDiverged(t *testing.T) {
+	replayer, err := NewReplayer(bytes.NewReader(recordSession(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewClient(replayer, 1).SetInterFrameDelay(0).ReadHoldingRegisters(43, 1)
+	if !errors.Is(err, ErrReplayDiverged) {
+		t.Errorf("ReadHoldingRegisters(43) error = %v, want ErrReplayDiverged", err)
+	}
+}
+
+func TestPlayMaster(t *testing.T) {
+	capture := recordSession(t)
+
+	replayer, _ := NewReplayer(bytes.NewReader(capture))
+	if err := replayer.PlayMaster(gatewayBus(t)); err != nil {
+		t.Errorf("PlayMaster() error = %v", err)
+	}
+
+	// 从站的行为与录制时不同
+	bus := gatewayBus(t)
+	handle := bus.handle
+	bus.handle = func(request []byte) []byte {
+		if response := handle(request); response != nil && !IsError(response[1]) {
+			return frame(0x01, 0x03, 0x02, 0x00, 0x00)
+		}
+		return handle(request)
+	}
+	replayer, _ = NewReplayer(bytes.NewReader(capture))
+	if err := replayer.PlayMaster(bus); !errors.Is(err, ErrReplayDiverged) {
+		t.Errorf("PlayMaster() error = %v, want ErrReplayDiverged", err)
+	}
+}
+
+func TestReadCaptureErrors(t *testing.T) {
+	for _, input := range []string{
+		`{"time":"2024-05-01T12:00:00Z","dir":"up","data":"01"}`,
+		`{"time":"2024-05-01T12:00:00Z","dir":"tx","data":"zz"}`,
+		`not json`,
+	} {
+		if _, err := ReadCapture(strings.NewReader(input)); err == nil {
+			t.Errorf("ReadCapture(%s) succeeded", input)
+		}
+	}
+}
@@ -1,200 +1,665 @@
 package modbus
 
 import (
-	"io"
+	"sync"
 	"time"
 )
 
-// Client 是 Modbus RTU 客户端
+// Client 是 Modbus 客户端
+// 通过 NewClient 创建的客户端在 RTU 总线上串行收发，
+// 通过 NewTCPClient 等创建的客户端把请求交给 Transactor，可以并发使用
 type Client struct {
-	transport       io.ReadWriter // 通讯接口
-	slaveID         byte          // 从站 ID
-	timeout         time.Duration // 超时时间
-	interFrameDelay time.Duration // 帧间延时
+	transport       Transport       // 通讯接口
+	transactor      Transactor      // 以帧为单位的传输层，非 nil 时代替 transport
+	bus             *serialBus      // RTU 总线的访问状态，批处理时由副本共享
+	busHeld         bool            // 调用方（批处理）已持有总线锁
+	slaveID         byte            // 从站 ID
+	timeout         time.Duration   // 超时时间
+	interFrameDelay time.Duration   // 帧间延时
+	retryPolicy     *RetryPolicy    // 重试策略，nil 表示不重试
+	breaker         *CircuitBreaker // 熔断器，nil 表示不熔断
+	hooks           []Hook          // 交互钩子
+	timing          *RTUTiming      // RTU 时间参数，nil 表示线路参数未知
+	echo            bool            // 是否去掉转换器回显的请求
+	resync          bool            // 是否在接收数据中查找有效帧
+	verify          bool            // 写操作成功后是否读回确认
+}
+
+// serialBus 是 RTU 总线的访问状态
+type serialBus struct {
+	mu        sync.Mutex // 串行访问总线
+	lastFrame time.Time  // 上一次交互结束的时间
+	reconnect bool       // 传输接口读写出错，下一次请求前需要重新连接
 }
 
 // NewClient 创建一个新的 Modbus RTU 客户端
-func NewClient(transport io.ReadWriter, slaveID byte) *Client {
-	return &Client{
+// 如果 transport 能够提供串口线路参数（例如 *SerialPort），
+// 客户端会据此计算 RTU 时间参数，不再使用固定的帧间延时。
+// 传输接口的其他可选能力见 Transport
+func NewClient(transport Transport, slaveID byte) *Client {
+	c := &Client{
 		transport:       transport,
+		bus:             &serialBus{},
 		slaveID:         slaveID,
 		timeout:         1 * time.Second,
 		interFrameDelay: 100 * time.Millisecond,
 	}
+	if line, ok := transport.(LineConfigProvider); ok {
+		c.SetSerialConfig(line.Config())
+	}
+	return c
+}
+
+// 创建使用 Transactor 的客户端
+func newTransactorClient(transactor Transactor, slaveID byte) *Client {
+	return &Client{
+		transactor: transactor,
+		bus:        &serialBus{},
+		slaveID:    slaveID,
+		timeout:    1 * time.Second,
+	}
 }
 
 // SetTimeout 设置请求超时时间
+// TCP、UDP 客户端把超时时间设置到 TCPTransport、UDPTransport 上（UDP 为每次发送后
+// 等待响应的时间），共享同一个传输的其他客户端同样生效，应在发起请求前设置；
+// 未调用时使用传输自身的超时时间
 func (c *Client) SetTimeout(timeout time.Duration) *Client {
 	c.timeout = timeout
+	if t, ok := c.transactor.(timeoutSetter); ok {
+		t.setTimeout(timeout)
+	}
 	return c
 }
 
-// SetInterFrameDelay 设置帧间延时
+// SetInterFrameDelay 设置发送请求后、读取响应前的固定延时
+// 未设置 RTU 时间参数时默认为 100ms，设置后默认为 0
 func (c *Client) SetInterFrameDelay(delay time.Duration) *Client {
 	c.interFrameDelay = delay
 	return c
 }
 
+// SetSerialConfig 根据串口线路参数设置 RTU 时间参数
+// 适用于传输接口本身无法提供线路参数的情况（例如第三方串口库）
+func (c *Client) SetSerialConfig(config SerialConfig) *Client {
+	return c.SetRTUTiming(NewRTUTiming(config))
+}
+
+// SetRTUTiming 设置 RTU 时间参数，同时取消固定的帧间延时
+// 客户端在两帧之间保持至少 t3.5 的静默，并以 t3.5 的静默判断未知长度响应帧的结束
+func (c *Client) SetRTUTiming(timing RTUTiming) *Client {
+	c.timing = &timing
+	c.interFrameDelay = 0
+	return c
+}
+
+// SetEchoSuppression 设置是否去掉接收数据开头回显的请求
+// 部分廉价的半双工 RS-485 转换器会把发送的数据原样回显，出现在从站响应之前
+func (c *Client) SetEchoSuppression(enable bool) *Client {
+	c.echo = enable
+	return c
+}
+
+// SetResync 设置是否在接收数据中查找第一个从站 ID、功能码匹配且 CRC 正确的帧
+// 用于跳过线路噪声在帧前产生的多余字节；开启后不完整或损坏的帧会等到响应超时才返回
+func (c *Client) SetResync(enable bool) *Client {
+	c.resync = enable
+	return c
+}
+
 // SetSlaveID 设置从站 ID
 func (c *Client) SetSlaveID(slaveID byte) *Client {
 	c.slaveID = slaveID
 	return c
 }
 
-// 发送请求并读取响应
-func (c *Client) sendAndReceive(request []byte, expectedFunctionCode byte) ([]byte, error) {
-	// 清空接收缓冲区
-	// 注意：这个步骤依赖于具体实现，可能需要根据实际情况进行调整或移除
-	/*
-		if flusher, ok := c.transport.(interface{ Flush() error }); ok {
-			if err := flusher.Flush(); err != nil {
-				return nil, err
-			}
+// SetRetryPolicy 设置请求失败后的重试策略，传入 nil 关闭重试
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) *Client {
+	c.retryPolicy = policy
+	return c
+}
+
+// SetCircuitBreaker 设置按从站 ID 隔离的熔断器，传入 nil 关闭熔断
+// 同一个熔断器可以在共享总线的多个客户端之间共用
+func (c *Client) SetCircuitBreaker(breaker *CircuitBreaker) *Client {
+	c.breaker = breaker
+	return c
+}
+
+// AddHook 添加交互钩子，每次在总线上收发请求时按添加顺序调用
+func (c *Client) AddHook(hook Hook) *Client {
+	c.hooks = append(c.hooks, hook)
+	return c
+}
+
+// 执行一次请求，按重试策略对可重试的错误重新发送
+// write 表示请求为写操作，只有重试策略允许时才会重试
+// 响应读取到 buffer 中，返回的响应引用 buffer
+func (c *Client) do(request, buffer []byte, expectedFunctionCode byte, write bool) ([]byte, error) {
+	attempts := c.retryPolicy.attempts(write)
+	for attempt := 1; ; attempt++ {
+		response, err := c.attempt(request, buffer, expectedFunctionCode, attempt)
+		if err == nil || attempt >= attempts || !c.retryPolicy.retryable(err) {
+			return response, err
 		}
-	*/
+		time.Sleep(c.retryPolicy.Backoff(attempt))
+	}
+}
+
+// 经过熔断器发送一次请求
+func (c *Client) attempt(request, buffer []byte, expectedFunctionCode byte, attempt int) ([]byte, error) {
+	if c.breaker == nil {
+		return c.exchange(request, buffer, expectedFunctionCode, attempt)
+	}
+
+	slaveID := request[0]
+	generation, err := c.breaker.allow(slaveID)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.exchange(request, buffer, expectedFunctionCode, attempt)
+	c.breaker.record(slaveID, generation, err)
+	return response, err
+}
+
+// 在交互钩子之间发送请求并读取响应
+func (c *Client) exchange(request, buffer []byte, expectedFunctionCode byte, attempt int) ([]byte, error) {
+	if len(c.hooks) == 0 {
+		return c.sendAndReceive(request, buffer, expectedFunctionCode)
+	}
+
+	tx := &Transaction{
+		SlaveID:      request[0],
+		FunctionCode: expectedFunctionCode,
+		Attempt:      attempt,
+		Request:      request,
+		Start:        time.Now(),
+	}
+	for _, hook := range c.hooks {
+		hook.BeforeTransaction(tx)
+	}
+
+	response, err := c.sendAndReceive(request, buffer, expectedFunctionCode)
+	tx.Response = response
+	tx.Latency = time.Since(tx.Start)
+	tx.Err = err
+
+	for _, hook := range c.hooks {
+		hook.AfterTransaction(tx)
+	}
+	return response, err
+}
+
+// 发送请求并读取响应，从站 ID 取自请求帧
+// 响应校验失败时仍返回已读取的原始数据，便于钩子记录
+// RTU 总线上的响应读取到 buffer 中，Transactor 返回的响应不使用 buffer
+func (c *Client) sendAndReceive(request, buffer []byte, expectedFunctionCode byte) ([]byte, error) {
+	if c.transactor != nil {
+		var response []byte
+		var err error
+		if r, ok := c.transactor.(Resender); ok && c.retryPolicy != nil && c.retryPolicy.RetryWrites {
+			// 重试策略允许重复写入时，传输同样重发写请求
+			response, err = r.TransactResend(request, true)
+		} else {
+			response, err = c.transactor.Transact(request)
+		}
+		if err != nil {
+			return response, err
+		}
+		return response, ValidateResponse(response, request[0], expectedFunctionCode)
+	}
+
+	if !c.busHeld {
+		c.bus.mu.Lock()
+		defer c.bus.mu.Unlock()
+	}
+
+	if err := c.reconnectIfNeeded(); err != nil {
+		return nil, err
+	}
+
+	// 清空接收缓冲区，丢弃上一次超时后迟到的响应和线路噪声
+	if flusher, ok := c.transport.(Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			return nil, c.transportError(err)
+		}
+	}
+
+	// 与上一帧之间保持至少 t3.5 的静默
+	if c.timing != nil {
+		if wait := time.Until(c.bus.lastFrame.Add(c.timing.T35)); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
 
 	// 发送请求
 	if _, err := c.transport.Write(request); err != nil {
-		return nil, err
+		return nil, c.transportError(err)
 	}
 
 	// 等待帧间延时
-	time.Sleep(c.interFrameDelay)
+	if c.interFrameDelay > 0 {
+		time.Sleep(c.interFrameDelay)
+	}
 
 	// 读取响应
-	// 注意：实际应用中，你可能需要处理更复杂的读取逻辑，例如处理超时
-	buffer := make([]byte, 256) // 足够大的缓冲区
-	n, err := c.transport.Read(buffer)
+	response, err := c.readFrame(request, buffer, expectedFunctionCode)
+	c.bus.lastFrame = time.Now()
 	if err != nil {
-		return nil, err
+		return response, err
 	}
 
-	response := buffer[:n]
-
 	// 验证响应
-	if err := ValidateResponse(response, c.slaveID, expectedFunctionCode); err != nil {
-		return nil, err
+	if err := ValidateResponse(response, request[0], expectedFunctionCode); err != nil {
+		return response, err
 	}
 
 	return response, nil
 }
 
-// ================= 位操作功能 =================
+// 读取一个响应帧
+//
+// 能够根据功能码确定帧长度时一直读取到完整的帧；无法确定时，
+// 如果传输接口支持读截止时间且已知 RTU 时间参数，则以超过 t3.5 的静默作为帧结束，
+// 否则以单次读取的数据作为整帧。
+// 传输接口支持读截止时间时，响应超时从请求发送完毕开始计算。
+// 接收的数据写入 buffer，buffer 写满时以已收到的数据作为整帧。
+func (c *Client) readFrame(request, buffer []byte, expectedFunctionCode byte) ([]byte, error) {
+	deadliner, _ := c.transport.(ReadDeadliner)
+	if deadliner != nil && c.timeout > 0 {
+		defer deadliner.SetReadDeadline(time.Time{})
+	} else {
+		deadliner = nil
+	}
 
-// ReadCoils 读取线圈状态
-func (c *Client) ReadCoils(startAddress uint16, quantity uint16) ([]bool, error) {
-	request := NewReadCoilsRequest(c.slaveID, startAddress, quantity)
-	response, err := c.sendAndReceive(request, FuncReadCoils)
-	if err != nil {
-		return nil, err
+	deadline := time.Now().Add(c.timeout)
+	if c.timing != nil {
+		deadline = deadline.Add(c.timing.FrameTime(len(request)))
 	}
 
-	bits, err := ParseReadBitsResponse(response, c.slaveID, FuncReadCoils)
-	if err != nil {
-		return nil, err
+	n := 0
+	status := frameIncomplete
+	for {
+		if deadliner != nil {
+			d := deadline
+			if n > 0 && status == frameUnknownLength && c.timing != nil {
+				d = time.Now().Add(c.timing.T35)
+			}
+			if err := deadliner.SetReadDeadline(d); err != nil {
+				return nil, err
+			}
+		}
+
+		m, err := c.transport.Read(buffer[n:])
+		n += m
+
+		var frame []byte
+		frame, status = extractResponse(buffer[:n], request, request[0], expectedFunctionCode, c.echo, c.resync)
+		if err != nil {
+			// 已收到部分数据后超时，视为帧结束
+			if n > 0 && IsTimeout(err) {
+				if status == frameIncomplete && !c.resync {
+					return frame, ErrResponseTooShort
+				}
+				return frame, nil
+			}
+			return frame, c.transportError(err)
+		}
+
+		switch {
+		case status == frameComplete:
+			return frame, nil
+		case status == frameUnknownLength && (deadliner == nil || c.timing == nil):
+			return frame, nil
+		case m == 0 || n == len(buffer):
+			return frame, nil
+		}
 	}
+}
 
-	// 只返回请求的位数量
-	if int(quantity) < len(bits) {
-		bits = bits[:quantity]
+// frameBuffer 是一次请求使用的请求帧和接收缓冲区，通过 framePool 复用
+type frameBuffer struct {
+	request  []byte // 请求帧，容量为 RTU 帧的最大长度
+	response []byte // 接收缓冲区，足够容纳回显的请求和响应
+}
+
+var framePool = sync.Pool{New: func() any {
+	return &frameBuffer{
+		request:  make([]byte, 0, maxRTUFrameLength),
+		response: make([]byte, 2*maxRTUFrameLength),
 	}
+}}
 
-	return bits, nil
+// 从池中获取缓冲区，使用完毕后调用 release 归还
+func getFrameBuffer() *frameBuffer {
+	return framePool.Get().(*frameBuffer)
+}
+
+// 归还缓冲区，之后不能再使用其中的请求和响应
+func (b *frameBuffer) release() {
+	framePool.Put(b)
+}
+
+// 为操作错误附加从站 ID、功能码和地址范围
+func (c *Client) opError(functionCode byte, address, quantity uint16, err error) error {
+	return &OpError{
+		SlaveID:      c.slaveID,
+		FunctionCode: functionCode,
+		Address:      address,
+		Quantity:     quantity,
+		Err:          err,
+	}
+}
+
+// ================= 位操作功能 =================
+
+// ReadCoils 读取线圈状态
+func (c *Client) ReadCoils(startAddress uint16, quantity uint16) ([]bool, error) {
+	return c.readBits(nil, startAddress, quantity, FuncReadCoils)
+}
+
+// ReadCoilsInto 读取 len(dst) 个线圈状态到 dst 中，不分配内存
+// 数量超出 1~2000 时返回 ErrInvalidLength，响应的位数不足时返回 ErrQuantityMismatch
+func (c *Client) ReadCoilsInto(dst []bool, startAddress uint16) error {
+	return c.readBitsInto(dst, startAddress, FuncReadCoils)
 }
 
 // ReadDiscreteInputs 读取离散输入状态
 func (c *Client) ReadDiscreteInputs(startAddress uint16, quantity uint16) ([]bool, error) {
-	request := NewReadDiscreteInputsRequest(c.slaveID, startAddress, quantity)
-	response, err := c.sendAndReceive(request, FuncReadDiscreteInputs)
-	if err != nil {
-		return nil, err
+	return c.readBits(nil, startAddress, quantity, FuncReadDiscreteInputs)
+}
+
+// ReadDiscreteInputsInto 读取 len(dst) 个离散输入状态到 dst 中，不分配内存
+// 数量超出 1~2000 时返回 ErrInvalidLength，响应的位数不足时返回 ErrQuantityMismatch
+func (c *Client) ReadDiscreteInputsInto(dst []bool, startAddress uint16) error {
+	return c.readBitsInto(dst, startAddress, FuncReadDiscreteInputs)
+}
+
+// 读取线圈或离散输入，把请求的位追加到 dst
+func (c *Client) readBits(dst []bool, startAddress, quantity uint16, functionCode byte) ([]bool, error) {
+	build := AppendReadCoilsRequest
+	if functionCode == FuncReadDiscreteInputs {
+		build = AppendReadDiscreteInputsRequest
 	}
 
-	bits, err := ParseReadBitsResponse(response, c.slaveID, FuncReadDiscreteInputs)
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	request := build(buf.request[:0], c.slaveID, startAddress, quantity)
+	response, err := c.do(request, buf.response, functionCode, false)
 	if err != nil {
-		return nil, err
+		return nil, c.opError(functionCode, startAddress, quantity, err)
 	}
 
 	// 只返回请求的位数量
-	if int(quantity) < len(bits) {
-		bits = bits[:quantity]
+	bits, err := appendReadBits(dst, response, c.slaveID, functionCode, int(quantity))
+	if err != nil {
+		return nil, c.opError(functionCode, startAddress, quantity, err)
 	}
-
 	return bits, nil
 }
 
-// WriteSingleCoil 写单个线圈
-func (c *Client) WriteSingleCoil(address uint16, value bool) error {
-	request := NewWriteSingleCoilRequest(c.slaveID, address, value)
-	response, err := c.sendAndReceive(request, FuncWriteSingleCoil)
+// 读取 len(dst) 个位到 dst 中
+func (c *Client) readBitsInto(dst []bool, startAddress uint16, functionCode byte) error {
+	if len(dst) < 1 || len(dst) > 2000 {
+		return c.opError(functionCode, startAddress, uint16(len(dst)), ErrInvalidLength)
+	}
+	quantity := uint16(len(dst))
+	bits, err := c.readBits(dst[:0], startAddress, quantity, functionCode)
 	if err != nil {
 		return err
 	}
+	if len(bits) != len(dst) {
+		return c.opError(functionCode, startAddress, quantity, ErrQuantityMismatch)
+	}
+	return nil
+}
+
+// WriteSingleCoil 写单个线圈
+func (c *Client) WriteSingleCoil(address uint16, value bool, opts ...WriteOption) error {
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	request := AppendWriteSingleCoilRequest(buf.request[:0], c.slaveID, address, value)
+	response, err := c.do(request, buf.response, FuncWriteSingleCoil, true)
+	if err != nil {
+		return c.opError(FuncWriteSingleCoil, address, 1, err)
+	}
 
-	return ParseWriteSingleCoilResponse(response, c.slaveID, address, value)
+	if err := ParseWriteSingleCoilResponse(response, c.slaveID, address, value); err != nil {
+		return c.opError(FuncWriteSingleCoil, address, 1, err)
+	}
+	if c.verifyEnabled(opts) {
+		if err := c.verifyCoils(address, []bool{value}); err != nil {
+			return c.opError(FuncWriteSingleCoil, address, 1, err)
+		}
+	}
+	return nil
 }
 
 // WriteMultipleCoils 写多个线圈
-func (c *Client) WriteMultipleCoils(startAddress uint16, values []bool) error {
-	request := NewWriteMultipleCoilsRequest(c.slaveID, startAddress, values)
+func (c *Client) WriteMultipleCoils(startAddress uint16, values []bool, opts ...WriteOption) error {
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	quantity := uint16(len(values))
+	request := AppendWriteMultipleCoilsRequest(buf.request[:0], c.slaveID, startAddress, values)
 	if request == nil {
-		return ErrInvalidLength
+		return c.opError(FuncWriteMultipleCoils, startAddress, quantity, ErrInvalidLength)
 	}
 
-	response, err := c.sendAndReceive(request, FuncWriteMultipleCoils)
+	response, err := c.do(request, buf.response, FuncWriteMultipleCoils, true)
 	if err != nil {
-		return err
+		return c.opError(FuncWriteMultipleCoils, startAddress, quantity, err)
 	}
 
-	return ParseWriteMultipleCoilsResponse(response, c.slaveID, startAddress, uint16(len(values)))
+	if err := ParseWriteMultipleCoilsResponse(response, c.slaveID, startAddress, quantity); err != nil {
+		return c.opError(FuncWriteMultipleCoils, startAddress, quantity, err)
+	}
+	if c.verifyEnabled(opts) {
+		if err := c.verifyCoils(startAddress, values); err != nil {
+			return c.opError(FuncWriteMultipleCoils, startAddress, quantity, err)
+		}
+	}
+	return nil
 }
 
 // ================= 字操作功能 =================
 
 // ReadHoldingRegisters 读取保持寄存器
 func (c *Client) ReadHoldingRegisters(startAddress uint16, quantity uint16) ([]uint16, error) {
-	request := NewReadHoldingRegistersRequest(c.slaveID, startAddress, quantity)
-	response, err := c.sendAndReceive(request, FuncReadHoldingRegisters)
-	if err != nil {
-		return nil, err
-	}
+	return c.readRegisters(nil, startAddress, quantity, FuncReadHoldingRegisters)
+}
 
-	return ParseReadRegistersResponse(response, c.slaveID, FuncReadHoldingRegisters)
+// ReadHoldingRegistersInto 读取 len(dst) 个保持寄存器到 dst 中，不分配内存
+// 数量超出 1~125 时返回 ErrInvalidLength，响应的寄存器数量不一致时返回 ErrQuantityMismatch
+func (c *Client) ReadHoldingRegistersInto(dst []uint16, startAddress uint16) error {
+	return c.readRegistersInto(dst, startAddress, FuncReadHoldingRegisters)
 }
 
 // ReadInputRegisters 读取输入寄存器
 func (c *Client) ReadInputRegisters(startAddress uint16, quantity uint16) ([]uint16, error) {
-	request := NewReadInputRegistersRequest(c.slaveID, startAddress, quantity)
-	response, err := c.sendAndReceive(request, FuncReadInputRegisters)
+	return c.readRegisters(nil, startAddress, quantity, FuncReadInputRegisters)
+}
+
+// ReadInputRegistersInto 读取 len(dst) 个输入寄存器到 dst 中，不分配内存
+// 数量超出 1~125 时返回 ErrInvalidLength，响应的寄存器数量不一致时返回 ErrQuantityMismatch
+func (c *Client) ReadInputRegistersInto(dst []uint16, startAddress uint16) error {
+	return c.readRegistersInto(dst, startAddress, FuncReadInputRegisters)
+}
+
+// 读取保持寄存器或输入寄存器，把寄存器值追加到 dst
+func (c *Client) readRegisters(dst []uint16, startAddress, quantity uint16, functionCode byte) ([]uint16, error) {
+	build := AppendReadHoldingRegistersRequest
+	if functionCode == FuncReadInputRegisters {
+		build = AppendReadInputRegistersRequest
+	}
+
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	request := build(buf.request[:0], c.slaveID, startAddress, quantity)
+	response, err := c.do(request, buf.response, functionCode, false)
 	if err != nil {
-		return nil, err
+		return nil, c.opError(functionCode, startAddress, quantity, err)
 	}
 
-	return ParseReadRegistersResponse(response, c.slaveID, FuncReadInputRegisters)
+	registers, err := appendReadRegisters(dst, response, c.slaveID, functionCode)
+	if err != nil {
+		return nil, c.opError(functionCode, startAddress, quantity, err)
+	}
+	return registers, nil
 }
 
-// WriteSingleRegister 写单个寄存器
-func (c *Client) WriteSingleRegister(address uint16, value uint16) error {
-	request := NewWriteSingleRegisterRequest(c.slaveID, address, value)
-	response, err := c.sendAndReceive(request, FuncWriteSingleRegister)
+// 读取 len(dst) 个寄存器到 dst 中
+func (c *Client) readRegistersInto(dst []uint16, startAddress uint16, functionCode byte) error {
+	if len(dst) < 1 || len(dst) > 125 {
+		return c.opError(functionCode, startAddress, uint16(len(dst)), ErrInvalidLength)
+	}
+	quantity := uint16(len(dst))
+	registers, err := c.readRegisters(dst[:0], startAddress, quantity, functionCode)
 	if err != nil {
 		return err
 	}
+	if len(registers) != len(dst) {
+		return c.opError(functionCode, startAddress, quantity, ErrQuantityMismatch)
+	}
+	return nil
+}
+
+// WriteSingleRegister 写单个寄存器
+func (c *Client) WriteSingleRegister(address uint16, value uint16, opts ...WriteOption) error {
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	request := AppendWriteSingleRegisterRequest(buf.request[:0], c.slaveID, address, value)
+	response, err := c.do(request, buf.response, FuncWriteSingleRegister, true)
+	if err != nil {
+		return c.opError(FuncWriteSingleRegister, address, 1, err)
+	}
 
-	return ParseWriteSingleRegisterResponse(response, c.slaveID, address, value)
+	if err := ParseWriteSingleRegisterResponse(response, c.slaveID, address, value); err != nil {
+		return c.opError(FuncWriteSingleRegister, address, 1, err)
+	}
+	if c.verifyEnabled(opts) {
+		if err := c.verifyRegisters(address, []uint16{value}); err != nil {
+			return c.opError(FuncWriteSingleRegister, address, 1, err)
+		}
+	}
+	return nil
 }
 
 // WriteMultipleRegisters 写多个寄存器
-func (c *Client) WriteMultipleRegisters(startAddress uint16, values []uint16) error {
-	request := NewWriteMultipleRegistersRequest(c.slaveID, startAddress, values)
+func (c *Client) WriteMultipleRegisters(startAddress uint16, values []uint16, opts ...WriteOption) error {
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	quantity := uint16(len(values))
+	request := AppendWriteMultipleRegistersRequest(buf.request[:0], c.slaveID, startAddress, values)
 	if request == nil {
-		return ErrInvalidLength
+		return c.opError(FuncWriteMultipleRegisters, startAddress, quantity, ErrInvalidLength)
 	}
 
-	response, err := c.sendAndReceive(request, FuncWriteMultipleRegisters)
+	response, err := c.do(request, buf.response, FuncWriteMultipleRegisters, true)
 	if err != nil {
-		return err
+		return c.opError(FuncWriteMultipleRegisters, startAddress, quantity, err)
+	}
+
+	if err := ParseWriteMultipleRegistersResponse(response, c.slaveID, startAddress, quantity); err != nil {
+		return c.opError(FuncWriteMultipleRegisters, startAddress, quantity, err)
+	}
+	if c.verifyEnabled(opts) {
+		if err := c.verifyRegisters(startAddress, values); err != nil {
+			return c.opError(FuncWriteMultipleRegisters, startAddress, quantity, err)
+		}
+	}
+	return nil
+}
+
+// Send 向指定从站发送原始 PDU（功能码 + 数据），返回响应 PDU
+// 用于客户端没有封装的功能码以及网关、代理等转发场景。
+// 不访问数据表的功能码无法判断是否幂等，按写操作处理，只有重试策略允许时才会重试
+func (c *Client) Send(slaveID byte, pdu []byte) ([]byte, error) {
+	if len(pdu) == 0 || len(pdu) > maxPDULength {
+		return nil, ErrInvalidLength
 	}
+	functionCode := pdu[0]
+	table, write := FunctionTable(functionCode)
+	address, quantity, _ := RequestAddressRange(functionCode, pdu[1:])
 
-	return ParseWriteMultipleRegistersResponse(response, c.slaveID, startAddress, uint16(len(values)))
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	request := appendCRC(append(append(buf.request[:0], slaveID), pdu...), 0)
+	response, err := c.do(request, buf.response, functionCode, write || table == 0)
+	if err != nil {
+		return nil, &OpError{
+			SlaveID:      slaveID,
+			FunctionCode: functionCode,
+			Address:      address,
+			Quantity:     quantity,
+			Err:          err,
+		}
+	}
+	// 响应引用缓冲区，复制后返回
+	return append([]byte(nil), response[1:len(response)-2]...), nil
+}
+
+// Broadcast 以从站 ID 0 向所有从站广播原始 PDU（功能码 + 数据），不等待响应
+// 从站执行广播请求但不响应，只有写类功能码有意义。请求不经过重试策略、熔断器和交互钩子。
+// RTU 总线上发送后等待帧间延时，下一个请求前保持 t3.5 的静默；
+// Transactor 客户端把请求交给传输，等待响应超时视为成功
+func (c *Client) Broadcast(pdu []byte) error {
+	if len(pdu) == 0 || len(pdu) > maxPDULength {
+		return ErrInvalidLength
+	}
+	functionCode := pdu[0]
+	address, quantity, _ := RequestAddressRange(functionCode, pdu[1:])
+
+	buf := getFrameBuffer()
+	defer buf.release()
+
+	request := appendCRC(append(append(buf.request[:0], 0), pdu...), 0)
+	if err := c.broadcast(request); err != nil {
+		return &OpError{
+			FunctionCode: functionCode,
+			Address:      address,
+			Quantity:     quantity,
+			Err:          err,
+		}
+	}
+	return nil
+}
+
+// 发送广播请求帧
+func (c *Client) broadcast(request []byte) error {
+	if c.transactor != nil {
+		if _, err := c.transactor.Transact(request); err != nil && !IsTimeout(err) {
+			return err
+		}
+		return nil
+	}
+
+	if !c.busHeld {
+		c.bus.mu.Lock()
+		defer c.bus.mu.Unlock()
+	}
+
+	if err := c.reconnectIfNeeded(); err != nil {
+		return err
+	}
+	if c.timing != nil {
+		if wait := time.Until(c.bus.lastFrame.Add(c.timing.T35)); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	if _, err := c.transport.Write(request); err != nil {
+		return c.transportError(err)
+	}
+	// 给从站处理广播请求的时间
+	if c.interFrameDelay > 0 {
+		time.Sleep(c.interFrameDelay)
+	}
+	c.bus.lastFrame = time.Now()
+	return nil
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+// config.go 定义了模拟器的 JSON 配置文件格式
+
+// Config 是模拟器配置
+type Config struct {
+	TCP      string         `json:"tcp"`      // Modbus TCP 监听地址，例如 ":5020"，为空表示不监听
+	PTY      bool           `json:"pty"`      // 是否在伪终端上提供 RTU 总线（仅支持 Linux）
+	PTYLink  string         `json:"pty_link"` // 伪终端从设备的符号链接路径，例如 /tmp/ttyMODBUS
+	Interval Duration       `json:"interval"` // 动态数据的更新间隔，默认 100ms
+	Devices  []DeviceConfig `json:"devices"`  // 模拟的从站
+}
+
+// DeviceConfig 描述一个模拟从站
+type DeviceConfig struct {
+	UnitID          byte             `json:"unit_id"`          // 从站 ID，1-247
+	Size            int              `json:"size"`             // 每张数据表的地址数量，默认 10000
+	ExceptionStatus byte             `json:"exception_status"` // 功能码 0x07 返回的异常状态
+	Registers       []RegisterConfig `json:"registers"`        // 初始值和动态数据
+}
+
+// RegisterConfig 描述一段地址的初始值或动态数据
+type RegisterConfig struct {
+	Table    string          `json:"table"`    // coils、discrete_inputs、holding 或 input
+	Address  uint16          `json:"address"`  // 起始地址
+	Values   []uint16        `json:"values"`   // 初始值，线圈和离散输入非 0 即为 1
+	Behavior *BehaviorConfig `json:"behavior"` // 动态数据，应用于 Address 开始的 Count 个地址
+	Count    int             `json:"count"`    // 动态数据的地址数量，默认 1
+}
+
+// BehaviorConfig 描述随时间变化的数据，计算结果四舍五入后按 16 位整数（负数为补码）写入
+type BehaviorConfig struct {
+	Type      string   `json:"type"`      // ramp、sine、random 或 counter
+	Min       float64  `json:"min"`       // ramp 和 random 的最小值
+	Max       float64  `json:"max"`       // ramp 和 random 的最大值
+	Offset    float64  `json:"offset"`    // sine 的中心值
+	Amplitude float64  `json:"amplitude"` // sine 的振幅
+	Period    Duration `json:"period"`    // ramp 和 sine 的周期，默认 10s
+	Start     float64  `json:"start"`     // counter 的初始值
+	Step      float64  `json:"step"`      // counter 每次增加的值，默认 1
+	Every     Duration `json:"every"`     // counter 增加的间隔，默认 1s
+}
+
+// Duration 是以字符串（例如 "500ms"）表示的时间间隔
+type Duration time.Duration
+
+// UnmarshalJSON 实现 json.Unmarshaler 接口
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"100ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// 数据表名称
+var tableNames = map[string]modbus.Table{
+	"coils":           modbus.TableCoils,
+	"discrete_inputs": modbus.TableDiscreteInputs,
+	"holding":         modbus.TableHoldingRegisters,
+	"input":           modbus.TableInputRegisters,
+}
+
+// loadConfig 读取并检查配置文件
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data)
+}
+
+// parseConfig 解析配置并填充默认值
+func parseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = Duration(100 * time.Millisecond)
+	}
+	if len(cfg.Devices) == 0 {
+		return nil, errors.New("config: no devices")
+	}
+
+	seen := make(map[byte]bool)
+	for i := range cfg.Devices {
+		dev := &cfg.Devices[i]
+		if dev.UnitID < 1 || dev.UnitID > 247 {
+			return nil, fmt.Errorf("config: device %d: unit_id %d out of range 1-247", i, dev.UnitID)
+		}
+		if seen[dev.UnitID] {
+			return nil, fmt.Errorf("config: duplicate unit_id %d", dev.UnitID)
+		}
+		seen[dev.UnitID] = true
+		if dev.Size <= 0 {
+			dev.Size = 10000
+		}
+		if dev.Size > 65536 {
+			return nil, fmt.Errorf("config: unit %d: size %d exceeds 65536", dev.UnitID, dev.Size)
+		}
+
+		for j := range dev.Registers {
+			if err := checkRegister(&dev.Registers[j], dev.Size); err != nil {
+				return nil, fmt.Errorf("config: unit %d: registers[%d]: %w", dev.UnitID, j, err)
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+// 检查一段地址的配置并填充默认值
+func checkRegister(reg *RegisterConfig, size int) error {
+	if _, ok := tableNames[reg.Table]; !ok {
+		return fmt.Errorf("unknown table %q", reg.Table)
+	}
+	if int(reg.Address)+len(reg.Values) > size {
+		return fmt.Errorf("values exceed table size %d", size)
+	}
+
+	b := reg.Behavior
+	if b == nil {
+		return nil
+	}
+	if reg.Count <= 0 {
+		reg.Count = 1
+	}
+	if int(reg.Address)+reg.Count > size {
+		return fmt.Errorf("behavior exceeds table size %d", size)
+	}
+	switch b.Type {
+	case "ramp", "sine":
+		if b.Period <= 0 {
+			b.Period = Duration(10 * time.Second)
+		}
+	case "random":
+	case "counter":
+		if b.Step == 0 {
+			b.Step = 1
+		}
+		if b.Every <= 0 {
+			b.Every = Duration(time.Second)
+		}
+	default:
+		return fmt.Errorf("unknown behavior type %q", b.Type)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+// device.go 实现了模拟从站：数据表之外的诊断类功能码、动态数据以及多个从站共用的总线
+
+// 诊断功能码（0x08）的子功能码
+const (
+	diagReturnQueryData       = 0x00
+	diagRestartCommunications = 0x01
+	diagClearCounters         = 0x0A
+	diagBusMessageCount       = 0x0B
+	diagBusCommErrorCount     = 0x0C
+	diagBusExceptionCount     = 0x0D
+	diagServerMessageCount    = 0x0E
+	diagServerNoResponseCount = 0x0F
+)
+
+// device 是一个模拟从站
+//
+// 数据表的读写由 modbus.DataModel 处理，device 在此基础上实现读取异常状态（0x07）、
+// 诊断（0x08）、获取通信事件计数（0x0B）和获取通信事件日志（0x0C），并维护诊断计数器。
+type device struct {
+	unitID          byte
+	model           *modbus.DataModel
+	exceptionStatus byte
+	behaviors       []*behavior
+
+	mu         sync.Mutex
+	messages   uint16 // 收到的请求数量
+	exceptions uint16 // 返回的异常响应数量
+	noResponse uint16 // 没有响应的请求数量（广播）
+	events     uint16 // 成功完成的请求数量，即通信事件计数
+}
+
+// newDevice 按配置创建从站并写入初始值
+func newDevice(cfg DeviceConfig) *device {
+	d := &device{
+		unitID:          cfg.UnitID,
+		model:           modbus.NewDataModel(cfg.Size),
+		exceptionStatus: cfg.ExceptionStatus,
+	}
+	for _, reg := range cfg.Registers {
+		table := tableNames[reg.Table]
+		d.model.Write(table, reg.Address, reg.Values...)
+		if reg.Behavior != nil {
+			d.behaviors = append(d.behaviors, &behavior{
+				table:   table,
+				address: reg.Address,
+				count:   reg.Count,
+				config:  *reg.Behavior,
+			})
+		}
+	}
+	return d
+}
+
+// ServeModbus 实现 modbus.Handler 接口
+func (d *device) ServeModbus(req *modbus.Request) ([]byte, error) {
+	return d.handle(req, false)
+}
+
+// 处理请求并更新诊断计数，broadcast 表示请求是广播，从站执行但不响应
+func (d *device) handle(req *modbus.Request, broadcast bool) ([]byte, error) {
+	data, err := d.serve(req)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messages++
+	var modbusErr *modbus.ModbusError
+	switch {
+	case broadcast:
+		d.noResponse++
+	case errors.As(err, &modbusErr):
+		d.exceptions++
+	case err == nil && req.FunctionCode != modbus.FuncGetCommEventCounter && req.FunctionCode != modbus.FuncGetCommEventLog:
+		d.events++
+	}
+	return data, err
+}
+
+func (d *device) serve(req *modbus.Request) ([]byte, error) {
+	switch req.FunctionCode {
+	case modbus.FuncReadExceptionStatus:
+		return []byte{d.exceptionStatus}, nil
+	case modbus.FuncDiagnostic:
+		return d.diagnostic(req.Data)
+	case modbus.FuncGetCommEventCounter:
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return []byte{0, 0, byte(d.events >> 8), byte(d.events)}, nil // 状态（未忙） + 事件计数
+	case modbus.FuncGetCommEventLog:
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// 字节数 + 状态 + 事件计数 + 消息计数，不保存事件记录
+		data := []byte{6, 0, 0}
+		data = binary.BigEndian.AppendUint16(data, d.events)
+		return binary.BigEndian.AppendUint16(data, d.messages), nil
+	default:
+		return d.model.ServeModbus(req)
+	}
+}
+
+// 处理诊断请求
+func (d *device) diagnostic(data []byte) ([]byte, error) {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return nil, modbus.ErrIllegalDataValue
+	}
+	sub := binary.BigEndian.Uint16(data[0:2])
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var count uint16
+	switch sub {
+	case diagReturnQueryData:
+		return data, nil
+	case diagRestartCommunications, diagClearCounters:
+		d.messages, d.exceptions, d.noResponse, d.events = 0, 0, 0, 0
+		return data, nil
+	case diagBusMessageCount, diagServerMessageCount:
+		count = d.messages
+	case diagBusCommErrorCount:
+		count = 0 // CRC 错误的帧在到达从站之前已被丢弃
+	case diagBusExceptionCount:
+		count = d.exceptions
+	case diagServerNoResponseCount:
+		count = d.noResponse
+	default:
+		return nil, modbus.ErrIllegalFunction
+	}
+	return binary.BigEndian.AppendUint16(data[:2:2], count), nil
+}
+
+// update 按经过的时间更新动态数据
+func (d *device) update(elapsed time.Duration) {
+	for _, b := range d.behaviors {
+		v := b.value(elapsed)
+		values := make([]uint16, b.count)
+		for i := range values {
+			values[i] = v
+		}
+		d.model.Write(b.table, b.address, values...)
+	}
+}
+
+// behavior 是一段地址上随时间变化的数据
+type behavior struct {
+	table   modbus.Table
+	address uint16
+	count   int
+	config  BehaviorConfig
+}
+
+// value 返回经过 elapsed 之后的值
+func (b *behavior) value(elapsed time.Duration) uint16 {
+	c := &b.config
+	var v float64
+	switch c.Type {
+	case "ramp":
+		frac := math.Mod(float64(elapsed), float64(c.Period)) / float64(c.Period)
+		v = c.Min + (c.Max-c.Min)*frac
+	case "sine":
+		v = c.Offset + c.Amplitude*math.Sin(2*math.Pi*float64(elapsed)/float64(c.Period))
+	case "random":
+		v = c.Min + (c.Max-c.Min)*rand.Float64()
+	case "counter":
+		v = c.Start + c.Step*float64(elapsed/time.Duration(c.Every))
+	}
+	// 超出 16 位范围的值按补码回绕
+	return uint16(int64(math.Round(v)))
+}
+
+// bus 把请求按单元 ID 分发给从站
+type bus struct {
+	devices map[byte]*device
+	tcp     bool // Modbus TCP 上未知单元返回网关异常，单个从站时单元 0 和 255 指向该从站
+}
+
+// ServeModbus 实现 modbus.Handler 接口
+func (b *bus) ServeModbus(req *modbus.Request) ([]byte, error) {
+	if d, ok := b.devices[req.UnitID]; ok {
+		return d.ServeModbus(req)
+	}
+	if b.tcp && len(b.devices) == 1 && (req.UnitID == 0 || req.UnitID == 0xFF) {
+		for _, d := range b.devices {
+			return d.ServeModbus(req)
+		}
+	}
+	if req.UnitID == 0 {
+		// 广播：所有从站执行，不响应
+		for _, d := range b.devices {
+			d.handle(req, true)
+		}
+		return nil, modbus.ErrNoResponse
+	}
+	if b.tcp {
+		return nil, modbus.ErrGatewayPathUnavailable
+	}
+	return nil, modbus.ErrNoResponse
+}
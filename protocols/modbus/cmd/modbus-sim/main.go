@@ -0,0 +1,159 @@
+// modbus-sim 按配置文件启动一个或多个模拟 Modbus 从站
+//
+// 从站通过 Modbus TCP 或伪终端（RTU）提供服务，数据表的初始值和随时间变化的数据
+// （斜坡、正弦波、随机噪声、计数器）在 JSON 配置文件中定义：
+//
+//	modbus-sim -config sim.json
+//	modbus-sim -config sim.json -tcp :5020 -pty -pty-link /tmp/ttyMODBUS
+//
+// 配置文件格式见 example.json。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+func main() {
+	configPath := flag.String("config", "modbus-sim.json", "配置文件路径")
+	tcp := flag.String("tcp", "", "Modbus TCP 监听地址，覆盖配置文件")
+	pty := flag.Bool("pty", false, "在伪终端上提供 RTU 总线")
+	ptyLink := flag.String("pty-link", "", "伪终端从设备的符号链接路径，覆盖配置文件")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *tcp != "" {
+		cfg.TCP = *tcp
+	}
+	if *pty {
+		cfg.PTY = true
+	}
+	if *ptyLink != "" {
+		cfg.PTY = true
+		cfg.PTYLink = *ptyLink
+	}
+	if cfg.TCP == "" && !cfg.PTY {
+		log.Fatal("nothing to serve: set tcp or pty in the config or on the command line")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := newSimulator(cfg).run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// simulator 管理模拟从站、动态数据更新和各个服务端
+type simulator struct {
+	cfg     *Config
+	devices map[byte]*device
+	start   time.Time
+}
+
+func newSimulator(cfg *Config) *simulator {
+	s := &simulator{cfg: cfg, devices: make(map[byte]*device)}
+	for _, dc := range cfg.Devices {
+		s.devices[dc.UnitID] = newDevice(dc)
+	}
+	return s
+}
+
+// run 启动配置的服务端，直到 ctx 结束或服务端出错
+func (s *simulator) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errc := make(chan error, 2)
+
+	s.start = time.Now()
+	s.update()
+	go s.updateLoop(ctx)
+
+	if s.cfg.TCP != "" {
+		l, err := net.Listen("tcp", s.cfg.TCP)
+		if err != nil {
+			return err
+		}
+		server := s.tcpServer()
+		go func() { errc <- server.Serve(l) }()
+		defer server.Close()
+		log.Printf("modbus-sim: serving %d device(s) on tcp %s", len(s.devices), l.Addr())
+	}
+
+	if s.cfg.PTY {
+		pty, err := modbus.OpenPTY()
+		if err != nil {
+			return fmt.Errorf("open pseudo-terminal: %w", err)
+		}
+		defer pty.Close()
+
+		path := pty.Path()
+		if s.cfg.PTYLink != "" {
+			os.Remove(s.cfg.PTYLink)
+			if err := os.Symlink(path, s.cfg.PTYLink); err != nil {
+				return err
+			}
+			defer os.Remove(s.cfg.PTYLink)
+			path = s.cfg.PTYLink
+		}
+		go func() { errc <- s.rtuServer().Serve(pty) }()
+		log.Printf("modbus-sim: serving %d device(s) on rtu %s", len(s.devices), path)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errc:
+		if errors.Is(err, modbus.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+// Modbus TCP 服务端，单元 ID 选择从站
+func (s *simulator) tcpServer() *modbus.TCPServer {
+	server := modbus.NewTCPServer(&bus{devices: s.devices, tcp: true})
+	server.ErrorLog = log.Default()
+	return server
+}
+
+// RTU 服务端，总线上的所有从站共用一个线路
+func (s *simulator) rtuServer() *modbus.RTUServer {
+	server := modbus.NewRTUServer(&bus{devices: s.devices}, 0)
+	server.ErrorLog = log.Default()
+	return server
+}
+
+// 按配置的间隔更新动态数据
+func (s *simulator) updateLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.update()
+		}
+	}
+}
+
+func (s *simulator) update() {
+	elapsed := time.Since(s.start)
+	for _, d := range s.devices {
+		d.update(elapsed)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+const testConfig = `{
+  "devices": [
+    {
+      "unit_id": 1,
+      "size": 100,
+      "exception_status": 5,
+      "registers": [
+        {"table": "holding", "address": 0, "values": [100, 200, 300]},
+        {"table": "coils", "address": 0, "values": [1, 0, 1]},
+        {"table": "input", "address": 0, "behavior": {"type": "ramp", "min": 0, "max": 1000, "period": "10s"}},
+        {"table": "input", "address": 1, "behavior": {"type": "sine", "offset": 0, "amplitude": 100, "period": "4s"}},
+        {"table": "input", "address": 2, "count": 2, "behavior": {"type": "counter", "start": 10, "step": 5, "every": "1s"}},
+        {"table": "input", "address": 4, "behavior": {"type": "random", "min": 40, "max": 60}}
+      ]
+    },
+    {"unit_id": 2}
+  ]
+}`
+
+// 启动 Modbus TCP 服务端，返回连接到指定单元的客户端
+func startSimulator(t *testing.T, cfg *Config) (*simulator, func(unitID byte) *modbus.Client) {
+	t.Helper()
+	s := newSimulator(cfg)
+	s.start = time.Now()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := s.tcpServer()
+	server.ErrorLog = nil
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+
+	transport := modbus.NewTCPTransport(l.Addr().String())
+	t.Cleanup(func() { transport.Close() })
+	return s, func(unitID byte) *modbus.Client { return modbus.NewTCPClient(transport, unitID) }
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Interval != Duration(100*time.Millisecond) || cfg.Devices[1].Size != 10000 {
+		t.Errorf("defaults not applied: interval %v, size %d", time.Duration(cfg.Interval), cfg.Devices[1].Size)
+	}
+
+	data, err := os.ReadFile("example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseConfig(data); err != nil {
+		t.Errorf("example.json: %v", err)
+	}
+
+	for _, bad := range []string{
+		`{"devices": []}`,
+		`{"devices": [{"unit_id": 0}]}`,
+		`{"devices": [{"unit_id": 1}, {"unit_id": 1}]}`,
+		`{"devices": [{"unit_id": 1, "registers": [{"table": "bogus"}]}]}`,
+		`{"devices": [{"unit_id": 1, "size": 2, "registers": [{"table": "holding", "values": [1, 2, 3]}]}]}`,
+		`{"devices": [{"unit_id": 1, "registers": [{"table": "input", "behavior": {"type": "square"}}]}]}`,
+		`{"interval": 100, "devices": [{"unit_id": 1}]}`,
+	} {
+		if _, err := parseConfig([]byte(bad)); err == nil {
+			t.Errorf("parseConfig(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestBehaviors(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDevice(cfg.Devices[0])
+	d.update(2500 * time.Millisecond)
+
+	values, _ := d.model.Read(modbus.TableInputRegisters, 0, 5)
+	// ramp 经过四分之一周期，sine 经过 2.5/4 个周期（负数按补码），counter 增加两次
+	want := []uint16{250, uint16(0x10000 - 71), 20, 20}
+	if !slices.Equal(values[:4], want) {
+		t.Errorf("values = %v, want %v", values[:4], want)
+	}
+	if values[4] < 40 || values[4] > 60 {
+		t.Errorf("random value %d out of range", values[4])
+	}
+}
+
+func TestSimulatorTCP(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dial := startSimulator(t, cfg)
+	client := dial(1)
+
+	registers, err := client.ReadHoldingRegisters(0, 3)
+	if err != nil || !slices.Equal(registers, []uint16{100, 200, 300}) {
+		t.Fatalf("ReadHoldingRegisters = %v, %v", registers, err)
+	}
+	if err := client.WriteSingleRegister(1, 42); err != nil {
+		t.Fatal(err)
+	}
+	if registers, _ := client.ReadHoldingRegisters(1, 1); !slices.Equal(registers, []uint16{42}) {
+		t.Errorf("register 1 = %v after write, want [42]", registers)
+	}
+	coils, err := client.ReadCoils(0, 3)
+	if err != nil || !slices.Equal(coils, []bool{true, false, true}) {
+		t.Fatalf("ReadCoils = %v, %v", coils, err)
+	}
+
+	if _, err := client.ReadHoldingRegisters(99, 2); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("read beyond size: %v, want illegal data address", err)
+	}
+	if _, err := client.Send(1, []byte{0x2B, 0x0E, 0x01, 0x00}); !errors.Is(err, modbus.ErrIllegalFunction) {
+		t.Errorf("unsupported function: %v, want illegal function", err)
+	}
+	if _, err := dial(9).ReadHoldingRegisters(0, 1); !errors.Is(err, modbus.ErrGatewayPathUnavailable) {
+		t.Errorf("unknown unit: %v, want gateway path unavailable", err)
+	}
+
+	// 读取异常状态
+	if pdu, err := client.Send(1, []byte{modbus.FuncReadExceptionStatus}); err != nil || !bytes.Equal(pdu, []byte{0x07, 5}) {
+		t.Errorf("read exception status = % X, %v", pdu, err)
+	}
+	// 诊断：返回查询数据、异常计数、清除计数器
+	query := []byte{modbus.FuncDiagnostic, 0x00, 0x00, 0xA5, 0x37}
+	if pdu, err := client.Send(1, query); err != nil || !bytes.Equal(pdu, query) {
+		t.Errorf("return query data = % X, %v", pdu, err)
+	}
+	if pdu, err := client.Send(1, []byte{modbus.FuncDiagnostic, 0x00, 0x0D, 0x00, 0x00}); err != nil || !bytes.Equal(pdu, []byte{0x08, 0x00, 0x0D, 0x00, 0x02}) {
+		t.Errorf("bus exception count = % X, %v", pdu, err)
+	}
+	if _, err := client.Send(1, []byte{modbus.FuncDiagnostic, 0x00, 0x0A, 0x00, 0x00}); err != nil {
+		t.Errorf("clear counters: %v", err)
+	}
+	// 清除计数器之后只有清除请求本身被计数
+	if pdu, err := client.Send(1, []byte{modbus.FuncGetCommEventCounter}); err != nil || !bytes.Equal(pdu, []byte{0x0B, 0, 0, 0, 1}) {
+		t.Errorf("get comm event counter = % X, %v", pdu, err)
+	}
+	if pdu, err := client.Send(1, []byte{modbus.FuncGetCommEventLog}); err != nil || !bytes.Equal(pdu, []byte{0x0C, 6, 0, 0, 0, 1, 0, 2}) {
+		t.Errorf("get comm event log = % X, %v", pdu, err)
+	}
+	if _, err := client.Send(1, []byte{modbus.FuncDiagnostic, 0x00, 0x63, 0x00, 0x00}); !errors.Is(err, modbus.ErrIllegalFunction) {
+		t.Errorf("unknown sub-function: %v, want illegal function", err)
+	}
+}
+
+func TestSimulatorBroadcast(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSimulator(cfg)
+	b := &bus{devices: s.devices}
+
+	_, err = b.ServeModbus(&modbus.Request{UnitID: 0, FunctionCode: modbus.FuncWriteSingleRegister, Data: []byte{0, 50, 0, 7}})
+	if !errors.Is(err, modbus.ErrNoResponse) {
+		t.Fatalf("broadcast: %v, want no response", err)
+	}
+	for id, d := range s.devices {
+		if values, _ := d.model.Read(modbus.TableHoldingRegisters, 50, 1); values[0] != 7 {
+			t.Errorf("unit %d register 50 = %d, want 7", id, values[0])
+		}
+	}
+	if _, err := b.ServeModbus(&modbus.Request{UnitID: 9, FunctionCode: modbus.FuncReadCoils, Data: []byte{0, 0, 0, 1}}); !errors.Is(err, modbus.ErrNoResponse) {
+		t.Errorf("unknown unit on RTU: %v, want no response", err)
+	}
+
+	// 广播计入无响应计数；Modbus TCP 上指向唯一从站的单元 0 有响应，不计入
+	noResponse := func(d *device) []byte {
+		pdu, _ := d.diagnostic([]byte{0x00, 0x0F, 0x00, 0x00})
+		return pdu
+	}
+	for id, d := range s.devices {
+		if pdu := noResponse(d); !bytes.Equal(pdu, []byte{0x00, 0x0F, 0x00, 0x01}) {
+			t.Errorf("unit %d no response count = % X, want 1", id, pdu)
+		}
+	}
+	single := newDevice(cfg.Devices[0])
+	tcp := &bus{devices: map[byte]*device{1: single}, tcp: true}
+	if _, err := tcp.ServeModbus(&modbus.Request{UnitID: 0, FunctionCode: modbus.FuncReadCoils, Data: []byte{0, 0, 0, 1}}); err != nil {
+		t.Fatalf("unit 0 on TCP: %v", err)
+	}
+	if pdu := noResponse(single); !bytes.Equal(pdu, []byte{0x00, 0x0F, 0x00, 0x00}) {
+		t.Errorf("no response count after TCP unit 0 = % X, want 0", pdu)
+	}
+}
+
+func TestSimulatorPTY(t *testing.T) {
+	cfg, err := parseConfig([]byte(strings.Replace(testConfig, `"devices"`, `"pty": true, "devices"`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pty, err := modbus.OpenPTY()
+	if err != nil {
+		t.Skipf("pseudo-terminal not available: %v", err)
+	}
+	defer pty.Close()
+	go newSimulator(cfg).rtuServer().Serve(pty)
+
+	port, err := modbus.OpenSerial(modbus.SerialConfig{Address: pty.Path(), Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Skipf("open %s: %v", pty.Path(), err)
+	}
+	defer port.Close()
+
+	client := modbus.NewClient(port, 1).SetTimeout(time.Second)
+	if registers, err := client.ReadHoldingRegisters(0, 3); err != nil || !slices.Equal(registers, []uint16{100, 200, 300}) {
+		t.Fatalf("ReadHoldingRegisters = %v, %v", registers, err)
+	}
+	// 另一个从站共用同一条总线
+	if _, err := client.SetSlaveID(2).ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("unit 2: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// decode.go 实现了寄存器值与各种数据类型之间的转换
+
+// valueType 描述寄存器中的数据类型
+type valueType struct {
+	name      string
+	registers int // 每个值占用的寄存器数量，ascii 为 0 表示所有寄存器组成一个字符串
+}
+
+var valueTypes = map[string]valueType{
+	"uint16":  {"uint16", 1},
+	"int16":   {"int16", 1},
+	"hex":     {"hex", 1},
+	"uint32":  {"uint32", 2},
+	"int32":   {"int32", 2},
+	"float32": {"float32", 2},
+	"uint64":  {"uint64", 4},
+	"int64":   {"int64", 4},
+	"float64": {"float64", 4},
+	"ascii":   {"ascii", 0},
+}
+
+// parseType 解析数据类型名称
+func parseType(name string) (valueType, error) {
+	t, ok := valueTypes[strings.ToLower(name)]
+	if !ok {
+		return valueType{}, fmt.Errorf("unknown type %q (uint16, int16, hex, uint32, int32, float32, uint64, int64, float64, ascii)", name)
+	}
+	return t, nil
+}
+
+// wordOrder 描述多寄存器值的字节顺序，以 32 位值的 4 个字节 ABCD（大端）为参照
+type wordOrder struct {
+	swapWords bool // 低位字在前：CDAB
+	swapBytes bool // 字内低字节在前：BADC
+}
+
+// parseOrder 解析字节顺序：ABCD（大端）、CDAB（字交换）、BADC（字节交换）或 DCBA（小端）
+func parseOrder(s string) (wordOrder, error) {
+	switch strings.ToUpper(s) {
+	case "ABCD", "BIG":
+		return wordOrder{}, nil
+	case "CDAB":
+		return wordOrder{swapWords: true}, nil
+	case "BADC":
+		return wordOrder{swapBytes: true}, nil
+	case "DCBA", "LITTLE":
+		return wordOrder{swapWords: true, swapBytes: true}, nil
+	default:
+		return wordOrder{}, fmt.Errorf("unknown word order %q (ABCD, CDAB, BADC, DCBA)", s)
+	}
+}
+
+// bytes 把一个值的寄存器按字节顺序转换为大端字节
+func (o wordOrder) bytes(registers []uint16) []byte {
+	b := make([]byte, 2*len(registers))
+	for i, r := range registers {
+		j := i
+		if o.swapWords {
+			j = len(registers) - 1 - i
+		}
+		if o.swapBytes {
+			r = r<<8 | r>>8
+		}
+		binary.BigEndian.PutUint16(b[2*j:], r)
+	}
+	return b
+}
+
+// registers 是 bytes 的逆运算
+func (o wordOrder) registers(b []byte) []uint16 {
+	registers := make([]uint16, len(b)/2)
+	for i := range registers {
+		j := i
+		if o.swapWords {
+			j = len(registers) - 1 - i
+		}
+		r := binary.BigEndian.Uint16(b[2*j:])
+		if o.swapBytes {
+			r = r<<8 | r>>8
+		}
+		registers[i] = r
+	}
+	return registers
+}
+
+// value 是解码后的一个值
+type value struct {
+	Address uint16   `json:"address"`
+	Value   any      `json:"value"`
+	Raw     []uint16 `json:"raw,omitempty"`
+}
+
+// decodeRegisters 按数据类型和字节顺序解码从 address 开始的寄存器
+func decodeRegisters(address uint16, registers []uint16, t valueType, order wordOrder) []value {
+	if t.registers == 0 {
+		b := order.bytes(registers)
+		return []value{{Address: address, Value: strings.TrimRight(string(b), "\x00 "), Raw: registers}}
+	}
+
+	values := make([]value, 0, len(registers)/t.registers)
+	for i := 0; i+t.registers <= len(registers); i += t.registers {
+		raw := registers[i : i+t.registers]
+		b := order.bytes(raw)
+		v := value{Address: address + uint16(i), Raw: raw}
+		switch t.name {
+		case "uint16":
+			v.Value = binary.BigEndian.Uint16(b)
+		case "int16":
+			v.Value = int16(binary.BigEndian.Uint16(b))
+		case "hex":
+			v.Value = fmt.Sprintf("0x%04X", binary.BigEndian.Uint16(b))
+		case "uint32":
+			v.Value = binary.BigEndian.Uint32(b)
+		case "int32":
+			v.Value = int32(binary.BigEndian.Uint32(b))
+		case "float32":
+			v.Value = jsonFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(b))), 32)
+		case "uint64":
+			v.Value = binary.BigEndian.Uint64(b)
+		case "int64":
+			v.Value = int64(binary.BigEndian.Uint64(b))
+		case "float64":
+			v.Value = jsonFloat(math.Float64frombits(binary.BigEndian.Uint64(b)), 64)
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// jsonFloat 返回可以编码为 JSON 的浮点数，NaN 和无穷大转换为字符串
+func jsonFloat(f float64, bits int) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, bits)
+	}
+	if bits == 32 {
+		return float32(f)
+	}
+	return f
+}
+
+// encodeValues 把命令行参数按数据类型和字节顺序编码为寄存器
+func encodeValues(args []string, t valueType, order wordOrder) ([]uint16, error) {
+	if t.registers == 0 {
+		s := strings.Join(args, " ")
+		if len(s)%2 != 0 {
+			s += "\x00"
+		}
+		return order.registers([]byte(s)), nil
+	}
+
+	var registers []uint16
+	for _, arg := range args {
+		b := make([]byte, 2*t.registers)
+		var err error
+		switch t.name {
+		case "uint16", "hex":
+			var v uint64
+			if v, err = strconv.ParseUint(arg, 0, 16); err == nil {
+				binary.BigEndian.PutUint16(b, uint16(v))
+			}
+		case "int16":
+			var v int64
+			if v, err = strconv.ParseInt(arg, 0, 16); err == nil {
+				binary.BigEndian.PutUint16(b, uint16(v))
+			}
+		case "uint32":
+			var v uint64
+			if v, err = strconv.ParseUint(arg, 0, 32); err == nil {
+				binary.BigEndian.PutUint32(b, uint32(v))
+			}
+		case "int32":
+			var v int64
+			if v, err = strconv.ParseInt(arg, 0, 32); err == nil {
+				binary.BigEndian.PutUint32(b, uint32(v))
+			}
+		case "float32":
+			var v float64
+			if v, err = strconv.ParseFloat(arg, 32); err == nil {
+				binary.BigEndian.PutUint32(b, math.Float32bits(float32(v)))
+			}
+		case "uint64":
+			var v uint64
+			if v, err = strconv.ParseUint(arg, 0, 64); err == nil {
+				binary.BigEndian.PutUint64(b, v)
+			}
+		case "int64":
+			var v int64
+			if v, err = strconv.ParseInt(arg, 0, 64); err == nil {
+				binary.BigEndian.PutUint64(b, uint64(v))
+			}
+		case "float64":
+			var v float64
+			if v, err = strconv.ParseFloat(arg, 64); err == nil {
+				binary.BigEndian.PutUint64(b, math.Float64bits(v))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q", t.name, arg)
+		}
+		registers = append(registers, order.registers(b)...)
+	}
+	return registers, nil
+}
+
+// parseBool 解析线圈的值：1/0、true/false、on/off
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "on":
+		return true, nil
+	case "0", "false", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid coil value %q (1/0, true/false, on/off)", s)
+	}
+}
@@ -0,0 +1,389 @@
+// modbus 是用于现场调试的 Modbus 命令行客户端
+//
+// 通过串口、Modbus TCP、RTU over TCP 或 Modbus UDP 读写线圈和寄存器，
+// 按指定的数据类型和字节顺序解码，可以轮询并以表格、CSV 或 JSON 输出：
+//
+//	modbus -serial /dev/ttyUSB0 -baud 9600 -parity E -unit 1 read-holding 0 10
+//	modbus -tcp 192.168.1.10 -type float32 -order CDAB -poll 1s -format csv read-input 100 4
+//	modbus -rtu-tcp 192.168.1.20:4001 -unit 3 write-register 10 1 2 3
+//	modbus -tcp 192.168.1.10 write-coil 5 on
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+const usage = `usage: modbus [flags] <command> [args]
+
+commands:
+  read-coils ADDRESS [COUNT]          (rc)  读取线圈
+  read-discrete ADDRESS [COUNT]       (rdi) 读取离散输入
+  read-holding ADDRESS [COUNT]        (rh)  读取保持寄存器，COUNT 为 -type 类型的值的数量
+  read-input ADDRESS [COUNT]          (ri)  读取输入寄存器
+  write-coil ADDRESS VALUE...         (wc)  写线圈，VALUE 为 1/0、true/false 或 on/off
+  write-register ADDRESS VALUE...     (wr)  按 -type 和 -order 编码后写保持寄存器
+
+flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "modbus:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+// options 是命令行参数
+type options struct {
+	serial   string
+	baud     int
+	dataBits int
+	parity   string
+	stopBits int
+	tcp      string
+	rtuTCP   string
+	udp      string
+
+	unit    uint
+	timeout time.Duration
+	typ     string
+	order   string
+	format  string
+	poll    time.Duration
+	count   int
+	fc16    bool
+}
+
+// run 解析参数并执行命令，轮询时直到 ctx 结束或达到次数
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var opts options
+	fs := flag.NewFlagSet("modbus", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.serial, "serial", "", "串口设备路径，例如 /dev/ttyUSB0")
+	fs.IntVar(&opts.baud, "baud", 9600, "串口波特率")
+	fs.IntVar(&opts.dataBits, "data-bits", 8, "串口数据位")
+	fs.StringVar(&opts.parity, "parity", "N", "串口校验方式：N、E 或 O")
+	fs.IntVar(&opts.stopBits, "stop-bits", 1, "串口停止位")
+	fs.StringVar(&opts.tcp, "tcp", "", "Modbus TCP 地址，默认端口 502")
+	fs.StringVar(&opts.rtuTCP, "rtu-tcp", "", "RTU over TCP 地址（串口服务器）")
+	fs.StringVar(&opts.udp, "udp", "", "Modbus UDP 地址，默认端口 502")
+	fs.UintVar(&opts.unit, "unit", 1, "从站 ID（单元 ID）")
+	fs.DurationVar(&opts.timeout, "timeout", time.Second, "请求超时时间")
+	fs.StringVar(&opts.typ, "type", "uint16", "寄存器数据类型：uint16、int16、hex、uint32、int32、float32、uint64、int64、float64、ascii")
+	fs.StringVar(&opts.order, "order", "ABCD", "多寄存器值的字节顺序：ABCD、CDAB、BADC、DCBA")
+	fs.StringVar(&opts.format, "format", "table", "输出格式：table、csv、json")
+	fs.DurationVar(&opts.poll, "poll", 0, "轮询间隔，0 表示只执行一次")
+	fs.IntVar(&opts.count, "count", 0, "轮询次数，0 表示直到中断")
+	fs.BoolVar(&opts.fc16, "fc16", false, "写单个寄存器时也使用功能码 0x10")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.unit > 255 {
+		return fmt.Errorf("unit %d out of range", opts.unit)
+	}
+
+	op, err := parseCommand(fs.Args(), &opts)
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+	out, err := newPrinter(opts.format, stdout, opts.poll > 0)
+	if err != nil {
+		return err
+	}
+
+	client, closer, err := connect(&opts)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	for i := 1; ; i++ {
+		r, msg, err := op(client)
+		switch {
+		case err != nil && opts.poll == 0:
+			return err
+		case err != nil:
+			// 轮询时记录错误并继续
+			fmt.Fprintf(stderr, "%s %v\n", time.Now().Format("15:04:05.000"), err)
+		case r != nil:
+			r.Unit = byte(opts.unit)
+			if err := out.print(r); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintln(stderr, msg)
+		}
+
+		if opts.poll == 0 || (opts.count > 0 && i >= opts.count) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.poll):
+		}
+	}
+}
+
+// operation 执行一次命令，读命令返回结果，写命令返回说明
+type operation func(client *modbus.Client) (*result, string, error)
+
+// parseCommand 解析命令和参数
+func parseCommand(args []string, opts *options) (operation, error) {
+	if len(args) < 2 {
+		return nil, errors.New("missing command or address")
+	}
+	t, err := parseType(opts.typ)
+	if err != nil {
+		return nil, err
+	}
+	order, err := parseOrder(opts.order)
+	if err != nil {
+		return nil, err
+	}
+	address, err := parseUint16(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q", args[1])
+	}
+
+	switch args[0] {
+	case "read-coils", "rc", "read-discrete", "rdi":
+		count, err := parseCount(args)
+		if err != nil {
+			return nil, err
+		}
+		if args[0] == "read-coils" || args[0] == "rc" {
+			return readBits(modbus.TableCoils, address, count), nil
+		}
+		return readBits(modbus.TableDiscreteInputs, address, count), nil
+	case "read-holding", "rh", "read-input", "ri":
+		count, err := parseCount(args)
+		if err != nil {
+			return nil, err
+		}
+		if t.registers == 0 && count > maxReadRegisters {
+			return nil, fmt.Errorf("ascii string longer than %d registers", maxReadRegisters)
+		}
+		if args[0] == "read-holding" || args[0] == "rh" {
+			return readRegisters(modbus.TableHoldingRegisters, address, count, t, order), nil
+		}
+		return readRegisters(modbus.TableInputRegisters, address, count, t, order), nil
+	case "write-coil", "wc":
+		if len(args) < 3 {
+			return nil, errors.New("missing coil values")
+		}
+		values := make([]bool, len(args)-2)
+		for i, arg := range args[2:] {
+			if values[i], err = parseBool(arg); err != nil {
+				return nil, err
+			}
+		}
+		return writeCoils(address, values), nil
+	case "write-register", "wr":
+		if len(args) < 3 {
+			return nil, errors.New("missing register values")
+		}
+		registers, err := encodeValues(args[2:], t, order)
+		if err != nil {
+			return nil, err
+		}
+		return writeRegisters(address, registers, opts.fc16), nil
+	default:
+		return nil, fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// 解析读命令的数量参数，默认为 1
+func parseCount(args []string) (int, error) {
+	if len(args) < 3 {
+		return 1, nil
+	}
+	count, err := strconv.Atoi(args[2])
+	if err != nil || count < 1 {
+		return 0, fmt.Errorf("invalid count %q", args[2])
+	}
+	return count, nil
+}
+
+// 解析十进制或 0x 开头的十六进制地址
+func parseUint16(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 0, 16)
+	return uint16(v), err
+}
+
+// 单个请求最多读取的线圈和寄存器数量
+const (
+	maxReadBits      = 2000
+	maxReadRegisters = 125
+)
+
+func readBits(table modbus.Table, address uint16, count int) operation {
+	return func(client *modbus.Client) (*result, string, error) {
+		r := &result{Time: time.Now(), Table: table.String()}
+		for done := 0; done < count; {
+			n := min(count-done, maxReadBits)
+			start := address + uint16(done)
+			var bits []bool
+			var err error
+			if table == modbus.TableCoils {
+				bits, err = client.ReadCoils(start, uint16(n))
+			} else {
+				bits, err = client.ReadDiscreteInputs(start, uint16(n))
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			for i, b := range bits[:min(n, len(bits))] {
+				r.Values = append(r.Values, value{Address: start + uint16(i), Value: b})
+			}
+			done += n
+		}
+		return r, "", nil
+	}
+}
+
+func readRegisters(table modbus.Table, address uint16, count int, t valueType, order wordOrder) operation {
+	total := count * max(t.registers, 1) // ascii 的 COUNT 为寄存器数量
+	chunk := maxReadRegisters
+	if t.registers > 0 {
+		chunk = maxReadRegisters / t.registers * t.registers // 每个请求读取完整的值
+	}
+
+	return func(client *modbus.Client) (*result, string, error) {
+		var registers []uint16
+		for len(registers) < total {
+			n := min(total-len(registers), chunk)
+			start := address + uint16(len(registers))
+			var values []uint16
+			var err error
+			if table == modbus.TableHoldingRegisters {
+				values, err = client.ReadHoldingRegisters(start, uint16(n))
+			} else {
+				values, err = client.ReadInputRegisters(start, uint16(n))
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			registers = append(registers, values...)
+		}
+		return &result{Time: time.Now(), Table: table.String(), Values: decodeRegisters(address, registers, t, order)}, "", nil
+	}
+}
+
+func writeCoils(address uint16, values []bool) operation {
+	return func(client *modbus.Client) (*result, string, error) {
+		var err error
+		if len(values) == 1 {
+			err = client.WriteSingleCoil(address, values[0])
+		} else {
+			err = client.WriteMultipleCoils(address, values)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return nil, fmt.Sprintf("wrote %d coil(s) at %d", len(values), address), nil
+	}
+}
+
+func writeRegisters(address uint16, registers []uint16, fc16 bool) operation {
+	return func(client *modbus.Client) (*result, string, error) {
+		var err error
+		if len(registers) == 1 && !fc16 {
+			err = client.WriteSingleRegister(address, registers[0])
+		} else {
+			err = client.WriteMultipleRegisters(address, registers)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return nil, fmt.Sprintf("wrote %d register(s) at %d", len(registers), address), nil
+	}
+}
+
+// connect 按参数建立连接，返回客户端和用于关闭连接的 io.Closer
+func connect(opts *options) (*modbus.Client, io.Closer, error) {
+	set := 0
+	for _, s := range []string{opts.serial, opts.tcp, opts.rtuTCP, opts.udp} {
+		if s != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, nil, errors.New("exactly one of -serial, -tcp, -rtu-tcp and -udp is required")
+	}
+	unit := byte(opts.unit)
+
+	switch {
+	case opts.serial != "":
+		parity, err := parseParity(opts.parity)
+		if err != nil {
+			return nil, nil, err
+		}
+		port, err := modbus.OpenSerial(modbus.SerialConfig{
+			Address:  opts.serial,
+			BaudRate: opts.baud,
+			DataBits: opts.dataBits,
+			Parity:   parity,
+			StopBits: opts.stopBits,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return modbus.NewClient(port, unit).SetTimeout(opts.timeout), port, nil
+	case opts.tcp != "":
+		transport := modbus.NewTCPTransport(withDefaultPort(opts.tcp)).SetTimeout(opts.timeout)
+		return modbus.NewTCPClient(transport, unit).SetTimeout(opts.timeout), transport, nil
+	case opts.rtuTCP != "":
+		conn, err := net.DialTimeout("tcp", opts.rtuTCP, opts.timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return modbus.NewClient(conn, unit).SetTimeout(opts.timeout).SetInterFrameDelay(0), conn, nil
+	default:
+		transport := modbus.NewUDPTransport(withDefaultPort(opts.udp)).SetTimeout(opts.timeout)
+		return modbus.NewUDPClient(transport, unit).SetTimeout(opts.timeout), transport, nil
+	}
+}
+
+// 地址中没有端口时使用 Modbus 默认端口 502
+func withDefaultPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(address, "502")
+	}
+	return address
+}
+
+func parseParity(s string) (modbus.Parity, error) {
+	switch strings.ToUpper(s) {
+	case "N", "NONE":
+		return modbus.ParityNone, nil
+	case "E", "EVEN":
+		return modbus.ParityEven, nil
+	case "O", "ODD":
+		return modbus.ParityOdd, nil
+	default:
+		return 0, fmt.Errorf("unknown parity %q (N, E, O)", s)
+	}
+}
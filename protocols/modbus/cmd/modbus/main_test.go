@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+func TestWordOrder(t *testing.T) {
+	// 0x12345678 在四种字节顺序下的寄存器
+	tests := map[string][]uint16{
+		"ABCD": {0x1234, 0x5678},
+		"CDAB": {0x5678, 0x1234},
+		"BADC": {0x3412, 0x7856},
+		"DCBA": {0x7856, 0x3412},
+	}
+	typ, _ := parseType("uint32")
+	for name, registers := range tests {
+		order, err := parseOrder(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := decodeRegisters(0, registers, typ, order)
+		if len(values) != 1 || values[0].Value != uint32(0x12345678) {
+			t.Errorf("%s: decoded %v", name, values)
+		}
+		encoded, err := encodeValues([]string{"0x12345678"}, typ, order)
+		if err != nil || !slices.Equal(encoded, registers) {
+			t.Errorf("%s: encoded %04X, %v", name, encoded, err)
+		}
+	}
+}
+
+func TestDecodeTypes(t *testing.T) {
+	order, _ := parseOrder("ABCD")
+	tests := []struct {
+		typ       string
+		registers []uint16
+		want      string
+	}{
+		{"int16", []uint16{0xFFFE}, "-2"},
+		{"hex", []uint16{0x00AB}, "0x00AB"},
+		{"float32", []uint16{0x4049, 0x0FDB}, "3.1415927"},
+		{"int64", []uint16{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF}, "-1"},
+		{"float64", []uint16{0x7FF8, 0, 0, 1}, "NaN"},
+		{"ascii", []uint16{0x4142, 0x4300}, "ABC"},
+	}
+	for _, tt := range tests {
+		typ, err := parseType(tt.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := decodeRegisters(0, tt.registers, typ, order)
+		if got := strings.TrimSpace(fmtValue(values[0].Value)); got != tt.want {
+			t.Errorf("%s %04X = %s, want %s", tt.typ, tt.registers, got, tt.want)
+		}
+	}
+	if _, err := encodeValues([]string{"70000"}, valueTypes["uint16"], order); err == nil {
+		t.Error("encoding 70000 as uint16 succeeded")
+	}
+}
+
+func fmtValue(v any) string {
+	data, _ := json.Marshal(v)
+	return strings.Trim(string(data), `"`)
+}
+
+// 启动使用数据模型的 Modbus TCP 服务器
+func startServer(t *testing.T) (string, *modbus.DataModel) {
+	t.Helper()
+	model := modbus.NewDataModel(1000)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := modbus.NewTCPServer(model)
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return l.Addr().String(), model
+}
+
+func runCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestRunTCP(t *testing.T) {
+	addr, model := startServer(t)
+
+	if _, err := runCLI(t, "-tcp", addr, "-type", "float32", "-order", "CDAB", "write-register", "10", "1.5", "-2"); err != nil {
+		t.Fatal(err)
+	}
+	registers, _ := model.Read(modbus.TableHoldingRegisters, 10, 4)
+	if !slices.Equal(registers, []uint16{0x0000, 0x3FC0, 0x0000, 0xC000}) {
+		t.Fatalf("registers = %04X", registers)
+	}
+
+	out, err := runCLI(t, "-tcp", addr, "-type", "float32", "-order", "CDAB", "read-holding", "10", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ADDRESS  VALUE  RAW\n10       1.5    0000 3FC0\n12       -2     0000 C000\n"
+	if out != want {
+		t.Errorf("table output:\n%s\nwant:\n%s", out, want)
+	}
+
+	if _, err := runCLI(t, "-tcp", addr, "wc", "3", "on", "off", "1"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCLI(t, "-tcp", addr, "-format", "csv", "-poll", "1ms", "-count", "2", "rc", "3", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 7 || lines[0] != "time,unit,table,address,value,raw" || !strings.HasSuffix(lines[1], ",1,coils,3,true,") {
+		t.Errorf("csv output:\n%s", out)
+	}
+
+	model.Write(modbus.TableInputRegisters, 0, 0xFFFF)
+	out, err = runCLI(t, "-tcp", addr, "-format", "json", "-type", "int16", "ri", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r result
+	if err := json.Unmarshal([]byte(out), &r); err != nil {
+		t.Fatalf("json output %q: %v", out, err)
+	}
+	if r.Table != "input registers" || len(r.Values) != 1 || r.Values[0].Value != float64(-1) {
+		t.Errorf("json result = %+v", r)
+	}
+
+	if _, err := runCLI(t, "-tcp", addr, "rh", "999", "2"); err == nil {
+		t.Error("reading beyond the device succeeded")
+	}
+}
+
+func TestRunRTUOverTCP(t *testing.T) {
+	model := modbus.NewDataModel(300)
+	model.Write(modbus.TableHoldingRegisters, 0, 1, 2, 3)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go modbus.NewRTUServer(model, 7).Serve(conn)
+		}
+	}()
+
+	// 超过单个请求上限的读取被拆分为多个请求
+	out, err := runCLI(t, "-rtu-tcp", l.Addr().String(), "-unit", "7", "-format", "json", "rh", "0", "200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r result
+	if err := json.Unmarshal([]byte(out), &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Values) != 200 || r.Unit != 7 || r.Values[2].Value != float64(3) || r.Values[199].Address != 199 {
+		t.Errorf("got %d values, unit %d", len(r.Values), r.Unit)
+	}
+}
+
+func TestRunUDPTimeout(t *testing.T) {
+	// 只接收不响应的 UDP 从站
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 300)
+		for {
+			if _, _, err := conn.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	_, err = runCLI(t, "-udp", conn.LocalAddr().String(), "-timeout", "20ms", "rh", "0")
+	if !modbus.IsTimeout(err) {
+		t.Fatalf("run() error = %v, want timeout", err)
+	}
+	// 首次发送加上默认的 2 次重发，每次等待 20ms
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timed out after %v, want -timeout to apply to each send", elapsed)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"-tcp", "x", "read-holding"},
+		{"-tcp", "x", "frobnicate", "1"},
+		{"-tcp", "x", "-type", "int128", "rh", "1"},
+		{"-tcp", "x", "wc", "1", "maybe"},
+		{"rh", "1"},
+		{"-tcp", "x", "-serial", "/dev/null", "rh", "1"},
+	} {
+		if _, err := runCLI(t, args...); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+}
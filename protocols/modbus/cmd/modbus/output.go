@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// output.go 实现了表格、CSV 和 JSON 三种输出格式
+
+// result 是一次读取的结果
+type result struct {
+	Time   time.Time `json:"time"`
+	Unit   byte      `json:"unit"`
+	Table  string    `json:"table"`
+	Values []value   `json:"values"`
+}
+
+// printer 输出读取结果
+type printer interface {
+	print(r *result) error
+}
+
+// newPrinter 创建指定格式的输出，轮询时表格格式在每次结果前输出时间
+func newPrinter(format string, w io.Writer, poll bool) (printer, error) {
+	switch format {
+	case "table":
+		return &tablePrinter{w: w, poll: poll}, nil
+	case "csv":
+		return &csvPrinter{w: csv.NewWriter(w)}, nil
+	case "json":
+		return &jsonPrinter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (table, csv, json)", format)
+	}
+}
+
+// 原始寄存器值的十六进制表示，多个寄存器以空格分隔
+func rawHex(raw []uint16) string {
+	parts := make([]string, len(raw))
+	for i, r := range raw {
+		parts[i] = fmt.Sprintf("%04X", r)
+	}
+	return strings.Join(parts, " ")
+}
+
+type tablePrinter struct {
+	w    io.Writer
+	poll bool
+}
+
+func (p *tablePrinter) print(r *result) error {
+	if p.poll {
+		fmt.Fprintf(p.w, "%s\n", r.Time.Format("2006-01-02 15:04:05.000"))
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tVALUE\tRAW")
+	for _, v := range r.Values {
+		fmt.Fprintf(tw, "%d\t%v\t%s\n", v.Address, v.Value, rawHex(v.Raw))
+	}
+	return tw.Flush()
+}
+
+type csvPrinter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (p *csvPrinter) print(r *result) error {
+	if !p.header {
+		p.w.Write([]string{"time", "unit", "table", "address", "value", "raw"})
+		p.header = true
+	}
+	for _, v := range r.Values {
+		p.w.Write([]string{
+			r.Time.Format(time.RFC3339Nano),
+			strconv.Itoa(int(r.Unit)),
+			r.Table,
+			strconv.Itoa(int(v.Address)),
+			fmt.Sprint(v.Value),
+			rawHex(v.Raw),
+		})
+	}
+	p.w.Flush()
+	return p.w.Error()
+}
+
+// jsonPrinter 每次结果输出一行 JSON
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func (p *jsonPrinter) print(r *result) error {
+	return p.enc.Encode(r)
+}
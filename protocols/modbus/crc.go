@@ -1,25 +1,64 @@
 package modbus
 
+import "hash"
+
 // crc.go 实现了 Modbus RTU 协议中使用的 CRC-16 校验算法
 
-// CRC16 计算给定数据的 Modbus CRC-16 校验值
-// Modbus RTU 使用的是 CRC-16-ANSI 算法，多项式为 x^16 + x^15 + x^2 + 1 (0xA001)
-func CRC16(data []byte) uint16 {
-	crc := uint16(0xFFFF)
-	for _, b := range data {
-		crc ^= uint16(b)
-		for i := 0; i < 8; i++ {
+// Modbus RTU 使用的是 CRC-16-ANSI 算法，多项式为 x^16 + x^15 + x^2 + 1 (反转后为 0xA001)
+const crcPolynomial = 0xA001
+
+// crcTables 是 slicing-by-8 查找表
+// crcTables[0] 是逐字节查表使用的标准表，crcTables[k] 对应后面第 k 个字节的贡献
+var crcTables = makeCRCTables()
+
+func makeCRCTables() *[8][256]uint16 {
+	t := new([8][256]uint16)
+	for i := range 256 {
+		crc := uint16(i)
+		for range 8 {
 			if crc&0x0001 != 0 {
-				crc = (crc >> 1) ^ 0xA001
+				crc = (crc >> 1) ^ crcPolynomial
 			} else {
 				crc = crc >> 1
 			}
 		}
+		t[0][i] = crc
+	}
+	for i := range 256 {
+		crc := t[0][i]
+		for k := 1; k < 8; k++ {
+			crc = t[0][byte(crc)] ^ crc>>8
+			t[k][i] = crc
+		}
+	}
+	return t
+}
+
+// 在 crc 的基础上继续计算 data 的 CRC-16
+// 每次处理 8 个字节，剩余的字节逐字节查表
+func updateCRC16(crc uint16, data []byte) uint16 {
+	t := crcTables
+	for len(data) >= 8 {
+		crc ^= uint16(data[0]) | uint16(data[1])<<8
+		crc = t[7][byte(crc)] ^ t[6][crc>>8] ^
+			t[5][data[2]] ^ t[4][data[3]] ^ t[3][data[4]] ^
+			t[2][data[5]] ^ t[1][data[6]] ^ t[0][data[7]]
+		data = data[8:]
+	}
+	for _, b := range data {
+		crc = t[0][byte(crc)^b] ^ crc>>8
 	}
 	return crc
 }
 
+// CRC16 计算给定数据的 Modbus CRC-16 校验值
+// Modbus RTU 使用的是 CRC-16-ANSI 算法，多项式为 x^16 + x^15 + x^2 + 1 (0xA001)
+func CRC16(data []byte) uint16 {
+	return updateCRC16(0xFFFF, data)
+}
+
 // AppendCRC16 计算给定数据的 CRC-16 校验值并以小端序附加到数据末尾
+// 返回新分配的切片，不修改 data；复用缓冲区时使用 AppendCRC16InPlace
 func AppendCRC16(data []byte) []byte {
 	crc := CRC16(data)
 	// Modbus RTU 中 CRC 是以小端序方式添加的 (低字节在前，高字节在后)
@@ -30,6 +69,18 @@ func AppendCRC16(data []byte) []byte {
 	return result
 }
 
+// AppendCRC16InPlace 计算 frame 的 CRC-16 校验值并以小端序 append 到 frame 末尾
+// 与 AppendCRC16 不同，容量足够时直接写入 frame 的底层数组，不复制数据
+func AppendCRC16InPlace(frame []byte) []byte {
+	return appendCRC(frame, 0)
+}
+
+// 计算 frame[start:] 的 CRC-16 并直接追加到 frame 末尾，容量足够时不分配内存
+func appendCRC(frame []byte, start int) []byte {
+	crc := CRC16(frame[start:])
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
 // CheckCRC16 验证数据的 CRC-16 校验值是否正确
 // 输入数据必须包含 CRC 校验值（数据的最后两个字节）
 func CheckCRC16(data []byte) bool {
@@ -54,3 +105,52 @@ func ExtractWithoutCRC16(data []byte) []byte {
 	}
 	return data[:len(data)-2]
 }
+
+// Hash16 是计算 16 位校验值的 hash.Hash
+type Hash16 interface {
+	hash.Hash
+	Sum16() uint16
+}
+
+// crc16Digest 是增量计算 CRC-16 的 Hash16
+type crc16Digest struct {
+	crc uint16
+}
+
+// NewCRC16 创建增量计算 Modbus CRC-16 的 Hash16
+// 适合数据分段到达的场景（例如逐块读取串口）。Sum 按帧中的顺序以小端序追加校验值，
+// 因此 h.Sum(frame) 与 AppendCRC16(frame) 的结果相同
+func NewCRC16() Hash16 {
+	return &crc16Digest{crc: 0xFFFF}
+}
+
+// Write 实现 io.Writer 接口，总是返回 len(p), nil
+func (d *crc16Digest) Write(p []byte) (int, error) {
+	d.crc = updateCRC16(d.crc, p)
+	return len(p), nil
+}
+
+// Sum 以小端序把当前的校验值追加到 b，不改变状态
+func (d *crc16Digest) Sum(b []byte) []byte {
+	return append(b, byte(d.crc), byte(d.crc>>8))
+}
+
+// Sum16 返回当前的校验值
+func (d *crc16Digest) Sum16() uint16 {
+	return d.crc
+}
+
+// Reset 恢复到初始状态
+func (d *crc16Digest) Reset() {
+	d.crc = 0xFFFF
+}
+
+// Size 返回校验值的字节数
+func (d *crc16Digest) Size() int {
+	return 2
+}
+
+// BlockSize 返回块大小
+func (d *crc16Digest) BlockSize() int {
+	return 1
+}
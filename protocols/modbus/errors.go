@@ -0,0 +1,143 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errors.go 定义了异常码对应的哨兵错误、错误分类辅助函数以及带请求上下文的错误类型
+
+// 异常码对应的哨兵错误，可与 errors.Is 配合使用：
+//
+//	if errors.Is(err, modbus.ErrIllegalDataAddress) { ... }
+var (
+	ErrIllegalFunction                    = &ModbusError{ExceptionCode: ExcIllegalFunction}
+	ErrIllegalDataAddress                 = &ModbusError{ExceptionCode: ExcIllegalDataAddress}
+	ErrIllegalDataValue                   = &ModbusError{ExceptionCode: ExcIllegalDataValue}
+	ErrServerDeviceFailure                = &ModbusError{ExceptionCode: ExcServerDeviceFailure}
+	ErrAcknowledge                        = &ModbusError{ExceptionCode: ExcAcknowledge}
+	ErrServerDeviceBusy                   = &ModbusError{ExceptionCode: ExcServerDeviceBusy}
+	ErrMemoryParityError                  = &ModbusError{ExceptionCode: ExcMemoryParityError}
+	ErrGatewayPathUnavailable             = &ModbusError{ExceptionCode: ExcGatewayPathUnavailable}
+	ErrGatewayTargetDeviceFailedToRespond = &ModbusError{ExceptionCode: ExcGatewayTargetDeviceFailedToRespond}
+)
+
+// ErrTimeout 表示在超时时间内没有收到响应，IsTimeout 对其返回 true
+var ErrTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "modbus: request timed out" }
+func (timeoutError) Timeout() bool { return true }
+
+// 功能码名称
+var functionNames = map[byte]string{
+	FuncReadCoils:              "read coils",
+	FuncReadDiscreteInputs:     "read discrete inputs",
+	FuncWriteSingleCoil:        "write single coil",
+	FuncWriteMultipleCoils:     "write multiple coils",
+	FuncReadHoldingRegisters:   "read holding registers",
+	FuncReadInputRegisters:     "read input registers",
+	FuncWriteSingleRegister:    "write single register",
+	FuncWriteMultipleRegisters: "write multiple registers",
+	FuncReadExceptionStatus:    "read exception status",
+	FuncDiagnostic:             "diagnostic",
+	FuncGetCommEventCounter:    "get comm event counter",
+	FuncGetCommEventLog:        "get comm event log",
+}
+
+// 异常码名称
+var exceptionNames = map[byte]string{
+	ExcIllegalFunction:                    "illegal function",
+	ExcIllegalDataAddress:                 "illegal data address",
+	ExcIllegalDataValue:                   "illegal data value",
+	ExcServerDeviceFailure:                "server device failure",
+	ExcAcknowledge:                        "acknowledge",
+	ExcServerDeviceBusy:                   "server device busy",
+	ExcMemoryParityError:                  "memory parity error",
+	ExcGatewayPathUnavailable:             "gateway path unavailable",
+	ExcGatewayTargetDeviceFailedToRespond: "gateway target device failed to respond",
+}
+
+// FunctionName 返回功能码的可读名称，异常响应的最高位会被忽略
+func FunctionName(functionCode byte) string {
+	if name, ok := functionNames[functionCode&^0x80]; ok {
+		return name
+	}
+	return fmt.Sprintf("function %#.2x", functionCode&^0x80)
+}
+
+// ExceptionName 返回异常码的可读名称
+func ExceptionName(exceptionCode byte) string {
+	if name, ok := exceptionNames[exceptionCode]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown exception %#.2x", exceptionCode)
+}
+
+// OpError 为客户端操作返回的错误附加从站 ID、功能码和地址范围等上下文
+// 原始错误可以通过 errors.Is / errors.As 获取
+type OpError struct {
+	SlaveID      byte   // 从站 ID
+	FunctionCode byte   // 功能码
+	Address      uint16 // 起始地址
+	Quantity     uint16 // 数量，单个线圈/寄存器的写操作为 1
+	Err          error  // 原始错误
+}
+
+// Error 实现 error 接口
+func (e *OpError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "modbus: slave %d %s", e.SlaveID, FunctionName(e.FunctionCode))
+	if e.Quantity > 1 {
+		fmt.Fprintf(&b, " addresses %d-%d", e.Address, uint32(e.Address)+uint32(e.Quantity)-1)
+	} else {
+		fmt.Fprintf(&b, " address %d", e.Address)
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(strings.TrimPrefix(e.Err.Error(), "modbus: "))
+	}
+	return b.String()
+}
+
+// Unwrap 返回原始错误
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout 报告该错误是否由超时引起
+func (e *OpError) Timeout() bool {
+	return IsTimeout(e.Err)
+}
+
+// IsTimeout 报告错误是否由超时引起
+// 包括传输层的读写超时（实现了 Timeout() bool 的错误）以及网关目标设备无响应的异常
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrGatewayTargetDeviceFailedToRespond) {
+		return true
+	}
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
+
+// IsRetryable 报告错误是否为暂时性错误，重新发送同一请求有可能成功
+// 包括 CRC 校验失败、响应不完整、超时，以及从站忙、确认和网关目标设备无响应等异常
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch {
+	case errors.Is(err, ErrCRCMismatch),
+		errors.Is(err, ErrResponseTooShort),
+		errors.Is(err, ErrServerDeviceBusy),
+		errors.Is(err, ErrAcknowledge),
+		errors.Is(err, ErrGatewayTargetDeviceFailedToRespond):
+		return true
+	}
+	return IsTimeout(err)
+}
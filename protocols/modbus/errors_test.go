@@ -0,0 +1,115 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 测试异常码哨兵错误与 errors.Is 的配合
+func TestModbusErrorIs(t *testing.T) {
+	err := ParseError(0x83, ExcServerDeviceBusy)
+
+	if !errors.Is(err, ErrServerDeviceBusy) {
+		t.Errorf("errors.Is(%v, ErrServerDeviceBusy) = false, want true", err)
+	}
+	if errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("errors.Is(%v, ErrIllegalDataAddress) = true, want false", err)
+	}
+	if !errors.Is(err, &ModbusError{FunctionCode: FuncReadHoldingRegisters, ExceptionCode: ExcServerDeviceBusy}) {
+		t.Errorf("errors.Is() with matching function code = false, want true")
+	}
+	if errors.Is(err, &ModbusError{FunctionCode: FuncReadCoils, ExceptionCode: ExcServerDeviceBusy}) {
+		t.Errorf("errors.Is() with different function code = true, want false")
+	}
+
+	want := "modbus: server device busy (exception 0x06) in response to read holding registers (function 0x03)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+// 测试 OpError 附带的上下文
+func TestOpError(t *testing.T) {
+	err := error(&OpError{
+		SlaveID:      3,
+		FunctionCode: FuncReadHoldingRegisters,
+		Address:      100,
+		Quantity:     10,
+		Err:          ErrCRCMismatch,
+	})
+
+	want := "modbus: slave 3 read holding registers addresses 100-109: CRC mismatch"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if !errors.Is(err, ErrCRCMismatch) {
+		t.Error("errors.Is(err, ErrCRCMismatch) = false, want true")
+	}
+
+	wrapped := fmt.Errorf("poll meter: %w", err)
+	var opErr *OpError
+	if !errors.As(wrapped, &opErr) || opErr.SlaveID != 3 {
+		t.Errorf("errors.As() did not return the OpError, got %v", opErr)
+	}
+}
+
+// 测试错误分类
+func TestErrorClassification(t *testing.T) {
+	timeout := fmt.Errorf("read: %w", os.ErrDeadlineExceeded)
+
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		timeout   bool
+	}{
+		{"nil", nil, false, false},
+		{"CRC 错误", ErrCRCMismatch, true, false},
+		{"响应过短", ErrResponseTooShort, true, false},
+		{"超时", timeout, true, true},
+		{"从站忙", ParseError(0x83, ExcServerDeviceBusy), true, false},
+		{"确认", ParseError(0x90, ExcAcknowledge), true, false},
+		{"网关目标无响应", ParseError(0x83, ExcGatewayTargetDeviceFailedToRespond), true, true},
+		{"非法地址", ParseError(0x83, ExcIllegalDataAddress), false, false},
+		{"从站 ID 不匹配", ErrInvalidSlaveID, false, false},
+		{"包装后的超时", &OpError{SlaveID: 1, Err: timeout}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.retryable {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.retryable)
+			}
+			if got := IsTimeout(tt.err); got != tt.timeout {
+				t.Errorf("IsTimeout(%v) = %v, want %v", tt.err, got, tt.timeout)
+			}
+		})
+	}
+}
+
+// 测试客户端返回的错误携带请求上下文
+func TestClientErrorContext(t *testing.T) {
+	responseData := []byte{0x01, 0x83, 0x02}
+	responseCRC := CRC16(responseData)
+	transport := &mockTransport{
+		t:      t,
+		mockRx: append(responseData, byte(responseCRC), byte(responseCRC>>8)),
+	}
+
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0)
+	_, err := client.ReadHoldingRegisters(0x6B, 3)
+
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("err is not an OpError, got %T", err)
+	}
+	if opErr.SlaveID != 1 || opErr.FunctionCode != FuncReadHoldingRegisters || opErr.Address != 0x6B || opErr.Quantity != 3 {
+		t.Errorf("OpError = %+v, want slave 1, function 0x03, address 0x6B, quantity 3", opErr)
+	}
+	if !strings.Contains(err.Error(), "illegal data address") {
+		t.Errorf("Error() = %q, want it to mention the exception name", err.Error())
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
-// 运行此示例前，请安装以下依赖：
-// go get github.com/tarm/serial
+// 连接真实设备时可以使用内置的串口传输代替 MockTransport（仅支持 Linux）：
+//
+//	port, err := modbus.OpenSerial(modbus.SerialConfig{
+//		Address:  "/dev/ttyUSB0",
+//		BaudRate: 9600,
+//		Parity:   modbus.ParityEven,
+//		Timeout:  time.Second,
+//	})
+//	client := modbus.NewClient(port, 1)
 
 import (
 	"fmt"
 	"log"
 	"time"
 
-	// 用于串口通信
 	"github.com/wxlbd/gokit/v2/protocols/modbus"
 )
 
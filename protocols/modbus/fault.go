@@ -0,0 +1,417 @@
+package modbus
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fault.go 实现了向响应帧注入故障的传输包装，用于在没有硬件的情况下测试线路异常
+
+// Fault 是注入到响应帧的故障类型
+type Fault int
+
+const (
+	FaultNone       Fault = iota // 不注入故障
+	FaultDelay                   // 延迟响应，延迟时间由 SetDelay 设置
+	FaultDrop                    // 丢弃响应
+	FaultTruncate                // 截断响应帧
+	FaultCorrupt                 // 翻转一个比特，RTU 帧会 CRC 校验失败
+	FaultWrongSlave              // 修改从站 ID（重新计算 CRC）
+	FaultDuplicate               // 重复发送响应帧
+	FaultException               // 替换为异常响应，异常码由 SetException 设置
+)
+
+// 按概率选择故障时的顺序
+var faultKinds = []Fault{FaultDelay, FaultDrop, FaultTruncate, FaultCorrupt, FaultWrongSlave, FaultDuplicate, FaultException}
+
+// String 返回故障名称
+func (f Fault) String() string {
+	switch f {
+	case FaultNone:
+		return "none"
+	case FaultDelay:
+		return "delay"
+	case FaultDrop:
+		return "drop"
+	case FaultTruncate:
+		return "truncate"
+	case FaultCorrupt:
+		return "corrupt"
+	case FaultWrongSlave:
+		return "wrong slave"
+	case FaultDuplicate:
+		return "duplicate"
+	case FaultException:
+		return "exception"
+	default:
+		return "unknown fault"
+	}
+}
+
+// FaultInjector 决定每个响应帧注入的故障
+//
+// 设置了脚本时按顺序使用脚本中的故障，脚本用完后按各故障的概率随机选择。
+// 同一个 FaultInjector 可以同时用于多个传输，可以被并发使用。
+type FaultInjector struct {
+	mu            sync.Mutex
+	rng           *rand.Rand
+	probabilities map[Fault]float64
+	script        []Fault
+	delay         time.Duration
+	exception     byte
+	injected      map[Fault]int
+}
+
+// NewFaultInjector 创建故障注入器，相同的 seed 产生相同的故障序列
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{
+		rng:           rand.New(rand.NewPCG(uint64(seed), 0)),
+		probabilities: make(map[Fault]float64),
+		delay:         100 * time.Millisecond,
+		exception:     ExcServerDeviceBusy,
+		injected:      make(map[Fault]int),
+	}
+}
+
+// SetProbability 设置每个响应帧注入某种故障的概率
+func (f *FaultInjector) SetProbability(fault Fault, p float64) *FaultInjector {
+	f.mu.Lock()
+	f.probabilities[fault] = p
+	f.mu.Unlock()
+	return f
+}
+
+// SetScript 设置按顺序使用的故障序列，FaultNone 表示对应的响应不注入故障
+func (f *FaultInjector) SetScript(faults ...Fault) *FaultInjector {
+	f.mu.Lock()
+	f.script = append([]Fault(nil), faults...)
+	f.mu.Unlock()
+	return f
+}
+
+// SetDelay 设置 FaultDelay 的延迟时间，默认为 100ms
+func (f *FaultInjector) SetDelay(delay time.Duration) *FaultInjector {
+	f.mu.Lock()
+	f.delay = delay
+	f.mu.Unlock()
+	return f
+}
+
+// SetException 设置 FaultException 使用的异常码，默认为从站忙
+func (f *FaultInjector) SetException(exceptionCode byte) *FaultInjector {
+	f.mu.Lock()
+	f.exception = exceptionCode
+	f.mu.Unlock()
+	return f
+}
+
+// Injected 返回已注入某种故障的次数
+// 只统计实际作用到响应帧上的故障，帧太短无法注入时计为 FaultNone，写入失败的帧不计入
+func (f *FaultInjector) Injected(fault Fault) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.injected[fault]
+}
+
+// 记录一次实际注入的故障
+func (f *FaultInjector) record(fault Fault) {
+	f.mu.Lock()
+	f.injected[fault]++
+	f.mu.Unlock()
+}
+
+// Transport 包装客户端的传输接口，向读取到的响应帧注入故障
+func (f *FaultInjector) Transport(transport io.ReadWriter) *FaultTransport {
+	return &FaultTransport{injector: f, transport: transport}
+}
+
+// ServerTransport 包装服务器端的 RTU 传输接口，向服务器写入的响应帧注入故障
+// 服务器每次写入必须是一个完整的帧
+func (f *FaultInjector) ServerTransport(transport io.ReadWriter) *FaultTransport {
+	return &FaultTransport{injector: f, transport: transport, server: true}
+}
+
+// Listener 包装 Modbus TCP 服务器的监听器，向服务器写入的 MBAP 响应帧注入故障
+// 不能用于设置了 TLSConfig 的服务器，否则故障会破坏 TLS 记录，此时使用 TLSListener
+func (f *FaultInjector) Listener(l net.Listener) net.Listener {
+	return &faultListener{Listener: l, injector: f}
+}
+
+// TLSListener 用 config 把 l 包装为 TLS 监听器，并向解密后的 MBAP 响应帧注入故障
+// 返回的监听器已经使用 TLS，服务器不应再设置 TLSConfig；握手和客户端证书的角色仍由服务器处理
+func (f *FaultInjector) TLSListener(l net.Listener, config *tls.Config) net.Listener {
+	return &faultListener{Listener: tls.NewListener(l, config), injector: f}
+}
+
+// 一个响应帧的故障及其参数，随机值在 next 中与故障一起选择
+type faultAction struct {
+	fault     Fault
+	delay     time.Duration
+	exception byte
+	cut       int  // FaultTruncate 保留的字节数
+	flip      int  // FaultCorrupt 翻转的字节位置
+	bit       byte // FaultCorrupt 翻转的比特
+}
+
+// 单元 ID 和 PDU 在帧中的位置以及帧尾 CRC 的长度
+// mbap 为 true 时帧为 MBAP 格式，否则为 RTU 格式
+func faultLayout(mbap bool) (unit, pdu, trailer int) {
+	if mbap {
+		return 6, mbapHeaderLength, 0
+	}
+	return 0, 1, 2
+}
+
+// 选择下一个响应帧的故障，并在同一次加锁中选择截断长度、翻转位置等随机值
+func (f *FaultInjector) next(frame []byte, mbap bool) faultAction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fault := FaultNone
+	if len(f.script) > 0 {
+		fault, f.script = f.script[0], f.script[1:]
+	} else {
+		x := f.rng.Float64()
+		for _, kind := range faultKinds {
+			if p := f.probabilities[kind]; x < p {
+				fault = kind
+				break
+			} else {
+				x -= p
+			}
+		}
+	}
+	a := faultAction{fault: fault, delay: f.delay, exception: f.exception}
+	_, pdu, trailer := faultLayout(mbap)
+	if n := len(frame); n >= pdu+1+trailer {
+		switch fault {
+		case FaultTruncate:
+			a.cut = 1 + f.rng.IntN(n-1)
+		case FaultCorrupt:
+			// RTU 帧翻转 CRC 中的比特，帧长度不变；MBAP 帧没有 CRC，翻转数据中的比特，表现为数据错误
+			a.flip = n - 1 - f.rng.IntN(2)
+			if mbap && n > pdu+1 {
+				a.flip = pdu + 1 + f.rng.IntN(n-pdu-1)
+			}
+			a.bit = 1 << f.rng.IntN(8)
+		}
+	}
+	return a
+}
+
+// 对一个响应帧注入故障，返回实际发送的帧（nil 表示丢弃）、发送前的延迟和实际注入的故障
+// mbap 为 true 时帧为 MBAP 格式，否则为 RTU 格式。调用方在帧送达后用 record 记录故障
+func (f *FaultInjector) apply(frame []byte, mbap bool) ([][]byte, time.Duration, Fault) {
+	a := f.next(frame, mbap)
+	out := append([]byte(nil), frame...)
+
+	unit, pdu, trailer := faultLayout(mbap)
+	if len(out) < pdu+1+trailer {
+		return [][]byte{out}, 0, FaultNone
+	}
+
+	switch a.fault {
+	case FaultDelay:
+		return [][]byte{out}, a.delay, a.fault
+	case FaultDrop:
+		return nil, 0, a.fault
+	case FaultTruncate:
+		return [][]byte{out[:a.cut]}, 0, a.fault
+	case FaultCorrupt:
+		out[a.flip] ^= a.bit
+	case FaultWrongSlave:
+		out[unit]++
+		if !mbap {
+			out = AppendCRC16(out[:len(out)-trailer])
+		}
+	case FaultDuplicate:
+		return [][]byte{out, append([]byte(nil), frame...)}, 0, a.fault
+	case FaultException:
+		if mbap {
+			out = append(out[:pdu], out[pdu]|0x80, a.exception)
+			binary.BigEndian.PutUint16(out[4:6], 3) // 单元 ID + 功能码 + 异常码
+		} else {
+			out = AppendCRC16([]byte{out[unit], out[pdu] | 0x80, a.exception})
+		}
+	}
+	return [][]byte{out}, 0, a.fault
+}
+
+// 对写入的帧注入故障后写入 w，全部写入成功后才记录故障，空的写入不注入故障
+func (f *FaultInjector) write(w io.Writer, p []byte, mbap bool) (int, error) {
+	if len(p) == 0 {
+		return w.Write(p)
+	}
+	frames, delay, fault := f.apply(p, mbap)
+	time.Sleep(delay)
+	for _, frame := range frames {
+		if _, err := w.Write(frame); err != nil {
+			return 0, err
+		}
+	}
+	f.record(fault)
+	return len(p), nil
+}
+
+// FaultTransport 是注入故障的传输接口包装
+//
+// 客户端模式（Transport）下按功能码读取完整的响应帧后注入故障，丢弃的响应表现为读取超时；
+// 服务器模式（ServerTransport）下对每次写入的帧注入故障。重复的帧会留在接收缓冲区中，
+// 与真实线路一样影响下一次交互。
+type FaultTransport struct {
+	injector  *FaultInjector
+	transport io.ReadWriter
+	server    bool
+
+	mu       sync.Mutex
+	raw      []byte    // 已从传输接口读取、尚未组成帧的数据
+	pending  [][]byte  // 注入故障后等待读取的帧
+	deadline time.Time // 读截止时间
+}
+
+// Write 实现 io.Writer 接口
+func (t *FaultTransport) Write(p []byte) (int, error) {
+	if !t.server {
+		return t.transport.Write(p)
+	}
+	return t.injector.write(t.transport, p, false)
+}
+
+// Read 实现 io.Reader 接口
+func (t *FaultTransport) Read(p []byte) (int, error) {
+	if t.server {
+		return t.transport.Read(p)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) == 0 {
+		frame, err := t.readFrame()
+		if len(frame) == 0 {
+			return 0, err
+		}
+
+		frames, delay, fault := t.injector.apply(frame, false)
+		t.injector.record(fault)
+		if frames == nil {
+			delay = time.Until(t.deadline)
+		}
+		if !t.deadline.IsZero() && time.Now().Add(delay).After(t.deadline) {
+			time.Sleep(time.Until(t.deadline))
+			return 0, ErrTimeout
+		}
+		time.Sleep(delay)
+		if frames == nil {
+			return 0, ErrTimeout
+		}
+		t.pending = frames
+	}
+
+	// 每次最多返回一个帧
+	n := copy(p, t.pending[0])
+	if t.pending[0] = t.pending[0][n:]; len(t.pending[0]) == 0 {
+		t.pending = t.pending[1:]
+	}
+	return n, nil
+}
+
+// SetReadDeadline 设置读截止时间，传输接口支持时同时转发
+func (t *FaultTransport) SetReadDeadline(deadline time.Time) error {
+	t.mu.Lock()
+	t.deadline = deadline
+	t.mu.Unlock()
+	if d, ok := t.transport.(ReadDeadliner); ok {
+		return d.SetReadDeadline(deadline)
+	}
+	return nil
+}
+
+// 从传输接口读取一个完整的响应帧，帧长度无法确定时返回已读取的数据
+func (t *FaultTransport) readFrame() ([]byte, error) {
+	buf := make([]byte, maxRTUFrameLength)
+	n := copy(buf, t.raw)
+	t.raw = nil
+	for {
+		if n > 0 {
+			length, known := rtuResponseLength(buf[:n])
+			switch {
+			case !known:
+				return buf[:n], nil
+			case length > 0 && n >= length:
+				// 超出一帧的数据留给下一次读取
+				t.raw = append(t.raw, buf[length:n]...)
+				return buf[:length], nil
+			case n == len(buf):
+				return buf[:n], nil
+			}
+		}
+
+		m, err := t.transport.Read(buf[n:])
+		n += m
+		if err != nil {
+			return buf[:n], err
+		}
+		if m == 0 {
+			return buf[:n], nil
+		}
+	}
+}
+
+// 注入故障的监听器
+type faultListener struct {
+	net.Listener
+	injector *FaultInjector
+}
+
+func (l *faultListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	fc := &faultConn{Conn: conn, injector: l.injector}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return &faultTLSConn{faultConn: fc, tls: tlsConn}, nil
+	}
+	return fc, nil
+}
+
+// 向写入的 MBAP 帧注入故障的连接
+type faultConn struct {
+	net.Conn
+	injector *FaultInjector
+	closed   atomic.Bool
+}
+
+// Write 在连接关闭后直接返回错误，丢弃故障不会被计入
+func (c *faultConn) Write(p []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	return c.injector.write(c.Conn, p, true)
+}
+
+func (c *faultConn) Close() error {
+	c.closed.Store(true)
+	return c.Conn.Close()
+}
+
+// 包装 TLS 连接的 faultConn，服务器通过它完成握手并取得客户端证书
+type faultTLSConn struct {
+	*faultConn
+	tls *tls.Conn
+}
+
+func (c *faultTLSConn) Handshake() error {
+	return c.tls.Handshake()
+}
+
+func (c *faultTLSConn) ConnectionState() tls.ConnectionState {
+	return c.tls.ConnectionState()
+}
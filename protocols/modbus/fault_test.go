@@ -0,0 +1,174 @@
+package modbus
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFaultTransportScript(t *testing.T) {
+	injector := NewFaultInjector(1).SetDelay(100*time.Millisecond).SetScript(
+		FaultNone, FaultCorrupt, FaultWrongSlave, FaultException, FaultDrop, FaultTruncate, FaultDelay, FaultNone, FaultDuplicate,
+	)
+	client := NewClient(injector.Transport(gatewayBus(t)), 1).
+		SetInterFrameDelay(0).
+		SetTimeout(50 * time.Millisecond)
+
+	wantErrors := []error{nil, ErrCRCMismatch, ErrInvalidSlaveID, ErrServerDeviceBusy, ErrTimeout, ErrResponseTooShort, ErrTimeout, nil}
+	for i, want := range wantErrors {
+		_, err := client.ReadHoldingRegisters(uint16(i), 1)
+		if want == nil && err != nil || want != nil && !errors.Is(err, want) {
+			t.Errorf("request %d with fault %v: error = %v, want %v", i, injector.script, err, want)
+		}
+	}
+
+	// 重复的响应留在接收缓冲区中，下一次请求读到上一次的响应
+	if registers, err := client.ReadHoldingRegisters(100, 1); err != nil || registers[0] != 100 {
+		t.Fatalf("ReadHoldingRegisters(100) = %v, %v", registers, err)
+	}
+	if registers, err := client.ReadHoldingRegisters(200, 1); err != nil || registers[0] != 100 {
+		t.Errorf("ReadHoldingRegisters(200) after duplicate = %v, %v, want stale [100]", registers, err)
+	}
+
+	for _, fault := range []Fault{FaultCorrupt, FaultDrop, FaultDuplicate} {
+		if n := injector.Injected(fault); n != 1 {
+			t.Errorf("Injected(%v) = %d, want 1", fault, n)
+		}
+	}
+}
+
+func TestFaultInjectorProbability(t *testing.T) {
+	injector := NewFaultInjector(42).
+		SetProbability(FaultDrop, 0.2).
+		SetProbability(FaultCorrupt, 0.1)
+	response := frame(0x01, 0x03, 0x02, 0x00, 0x01)
+
+	for i := 0; i < 1000; i++ {
+		injector.write(io.Discard, response, false)
+	}
+	if n := injector.Injected(FaultDrop); n < 150 || n > 250 {
+		t.Errorf("Injected(FaultDrop) = %d, want about 200", n)
+	}
+	if n := injector.Injected(FaultCorrupt); n < 60 || n > 140 {
+		t.Errorf("Injected(FaultCorrupt) = %d, want about 100", n)
+	}
+	if n := injector.Injected(FaultNone); n < 600 || n > 800 {
+		t.Errorf("Injected(FaultNone) = %d, want about 700", n)
+	}
+
+	// 相同的种子产生相同的故障序列
+	a := NewFaultInjector(7).SetProbability(FaultCorrupt, 0.5)
+	b := NewFaultInjector(7).SetProbability(FaultCorrupt, 0.5)
+	for i := 0; i < 100; i++ {
+		x, _, _ := a.apply(response, false)
+		y, _, _ := b.apply(response, false)
+		if !bytes.Equal(x[0], y[0]) {
+			t.Fatalf("response %d: % x and % x differ with the same seed", i, x[0], y[0])
+		}
+	}
+}
+
+// 失败的写入
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, io.ErrClosedPipe }
+
+// 测试只统计实际注入的故障
+func TestFaultInjectorCounts(t *testing.T) {
+	injector := NewFaultInjector(1).SetScript(FaultCorrupt, FaultTruncate, FaultDuplicate)
+	response := frame(0x01, 0x03, 0x02, 0x00, 0x01)
+
+	injector.write(io.Discard, nil, false)           // 空的写入不注入故障，不消耗脚本
+	injector.write(io.Discard, response[:2], false)  // 帧太短，故障无法注入
+	injector.write(failingWriter{}, response, false) // 写入失败
+	injector.write(io.Discard, response, false)      // 实际注入
+	for fault, want := range map[Fault]int{FaultNone: 1, FaultCorrupt: 0, FaultTruncate: 0, FaultDuplicate: 1} {
+		if n := injector.Injected(fault); n != want {
+			t.Errorf("Injected(%v) = %d, want %d", fault, n, want)
+		}
+	}
+
+	// 连接关闭后丢弃的响应不计入
+	injector.SetScript(FaultDrop)
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := &faultConn{Conn: server, injector: injector}
+	conn.Close()
+	if _, err := conn.Write(response); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write() after Close error = %v, want net.ErrClosed", err)
+	}
+	if n := injector.Injected(FaultDrop); n != 0 {
+		t.Errorf("Injected(FaultDrop) = %d after closed connection, want 0", n)
+	}
+}
+
+func TestFaultListener(t *testing.T) {
+	injector := NewFaultInjector(1).SetScript(FaultException, FaultWrongSlave, FaultNone)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewTCPServer(echoAddressHandler)
+	go server.Serve(injector.Listener(l))
+	defer server.Close()
+
+	transport := NewTCPTransport(l.Addr().String())
+	defer transport.Close()
+	client := NewTCPClient(transport, 1)
+
+	if _, err := client.ReadHoldingRegisters(1, 1); !errors.Is(err, ErrServerDeviceBusy) {
+		t.Errorf("first request error = %v, want ErrServerDeviceBusy", err)
+	}
+	if _, err := client.ReadHoldingRegisters(2, 1); !errors.Is(err, ErrInvalidSlaveID) {
+		t.Errorf("second request error = %v, want ErrInvalidSlaveID", err)
+	}
+	if registers, err := client.ReadHoldingRegisters(3, 1); err != nil || registers[0] != 3 {
+		t.Errorf("third request = %v, %v", registers, err)
+	}
+}
+
+// 测试 TLS 监听器上的故障注入作用于 MBAP 帧而不是 TLS 记录，客户端证书的角色仍然生效
+func TestFaultTLSListener(t *testing.T) {
+	ca := newTestCA(t)
+	server := NewTCPServer(echoAddressHandler)
+	server.Authorizer = RuleAuthorizer{{Role: "Operator", StartAddress: 0, Quantity: 100}}
+	injector := NewFaultInjector(1).SetScript(FaultException, FaultWrongSlave, FaultNone)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(injector.TLSListener(l, &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "localhost", "")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	}))
+	defer server.Close()
+
+	transport := NewTCPTransport(l.Addr().String()).SetTLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "operator", "Operator")},
+		RootCAs:      ca.pool,
+		ServerName:   "localhost",
+	})
+	defer transport.Close()
+	client := NewTCPClient(transport, 0x01)
+
+	if _, err := client.ReadHoldingRegisters(1, 1); !errors.Is(err, ErrServerDeviceBusy) {
+		t.Errorf("first request error = %v, want ErrServerDeviceBusy", err)
+	}
+	// 修改的是 MBAP 帧中的单元 ID，TLS 连接不受影响
+	if _, err := client.ReadHoldingRegisters(2, 1); !errors.Is(err, ErrInvalidSlaveID) {
+		t.Errorf("second request error = %v, want ErrInvalidSlaveID", err)
+	}
+	if registers, err := client.ReadHoldingRegisters(3, 1); err != nil || registers[0] != 3 {
+		t.Errorf("third request = %v, %v", registers, err)
+	}
+	if _, err := client.ReadHoldingRegisters(500, 1); !errors.Is(err, ErrIllegalFunction) {
+		t.Errorf("unauthorized request error = %v, want ErrIllegalFunction", err)
+	}
+}
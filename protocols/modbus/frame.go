@@ -0,0 +1,122 @@
+package modbus
+
+// frame.go 根据功能码判断 RTU 帧的长度
+
+// rtuResponseLength 根据已收到的数据计算响应帧的完整长度（含 CRC）
+// 数据不足以判断时 ok 为 true 且 n 为 0；功能码未知时 ok 为 false
+func rtuResponseLength(frame []byte) (n int, ok bool) {
+	if len(frame) < 2 {
+		return 0, true
+	}
+
+	functionCode := frame[1]
+	if IsError(functionCode) {
+		return 5, true // 从站 ID + 功能码 + 异常码 + CRC
+	}
+
+	switch functionCode {
+	case FuncReadCoils, FuncReadDiscreteInputs, FuncReadHoldingRegisters, FuncReadInputRegisters, FuncGetCommEventLog:
+		if len(frame) < 3 {
+			return 0, true
+		}
+		return 3 + int(frame[2]) + 2, true // 从站 ID + 功能码 + 字节数 + 数据 + CRC
+	case FuncReadExceptionStatus:
+		return 5, true // 从站 ID + 功能码 + 状态 + CRC
+	case FuncWriteSingleCoil, FuncWriteSingleRegister, FuncWriteMultipleCoils, FuncWriteMultipleRegisters,
+		FuncDiagnostic, FuncGetCommEventCounter:
+		return 8, true // 从站 ID + 功能码 + 4 字节数据 + CRC
+	default:
+		return 0, false
+	}
+}
+
+// rtuRequestLength 根据已收到的数据计算请求帧的完整长度（含 CRC）
+// 数据不足以判断时 ok 为 true 且 n 为 0；功能码未知时 ok 为 false
+func rtuRequestLength(frame []byte) (n int, ok bool) {
+	if len(frame) < 2 {
+		return 0, true
+	}
+
+	switch frame[1] {
+	case FuncReadCoils, FuncReadDiscreteInputs, FuncReadHoldingRegisters, FuncReadInputRegisters,
+		FuncWriteSingleCoil, FuncWriteSingleRegister, FuncDiagnostic:
+		return 8, true // 从站 ID + 功能码 + 4 字节数据 + CRC
+	case FuncReadExceptionStatus, FuncGetCommEventCounter, FuncGetCommEventLog:
+		return 4, true // 从站 ID + 功能码 + CRC
+	case FuncWriteMultipleCoils, FuncWriteMultipleRegisters:
+		if len(frame) < 7 {
+			return 0, true
+		}
+		return 7 + int(frame[6]) + 2, true // 从站 ID + 功能码 + 地址 + 数量 + 字节数 + 数据 + CRC
+	default:
+		return 0, false
+	}
+}
+
+// 响应帧的接收状态
+type frameStatus int
+
+const (
+	frameIncomplete    frameStatus = iota // 帧长度已知但数据不足
+	frameComplete                         // 已收到完整的帧
+	frameUnknownLength                    // 无法根据功能码判断帧长度
+)
+
+// extractResponse 从接收到的数据中提取响应帧
+//
+// echo 为 true 时先去掉半双工 RS-485 转换器回显的请求；
+// resync 为 true 时在数据中查找第一个从站 ID 和功能码匹配且 CRC 正确的帧，跳过线路噪声产生的多余字节。
+// 返回的帧在状态不是 frameComplete 时为去掉回显后的全部数据。
+func extractResponse(data, request []byte, slaveID, functionCode byte, echo, resync bool) ([]byte, frameStatus) {
+	if echo {
+		n := min(len(data), len(request))
+		if string(data[:n]) == string(request[:n]) {
+			if n < len(request) {
+				return data[:0], frameIncomplete
+			}
+			data = data[n:]
+		}
+	}
+
+	if resync {
+		if frame, ok := findFrame(data, slaveID, functionCode); ok {
+			return frame, frameComplete
+		}
+		return data, frameIncomplete
+	}
+
+	length, known := rtuResponseLength(data)
+	switch {
+	case !known:
+		return data, frameUnknownLength
+	case length > 0 && len(data) >= length:
+		return data[:length], frameComplete
+	default:
+		return data, frameIncomplete
+	}
+}
+
+// findFrame 查找第一个从站 ID 和功能码（包括异常响应）匹配且 CRC 正确的帧
+// 功能码未知时取 CRC 正确的最短帧
+func findFrame(data []byte, slaveID, functionCode byte) ([]byte, bool) {
+	for i := 0; i+4 <= len(data); i++ {
+		candidate := data[i:]
+		if candidate[0] != slaveID || candidate[1]&^0x80 != functionCode {
+			continue
+		}
+
+		length, known := rtuResponseLength(candidate)
+		if known {
+			if length > 0 && len(candidate) >= length && CheckCRC16(candidate[:length]) {
+				return candidate[:length], true
+			}
+			continue
+		}
+		for end := 4; end <= len(candidate); end++ {
+			if CheckCRC16(candidate[:end]) {
+				return candidate[:end], true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,93 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// 测试去掉转换器回显的请求
+func TestClientEchoSuppression(t *testing.T) {
+	request := NewWriteSingleRegisterRequest(0x01, 0x0001, 0x0003)
+	response := frame(0x01, 0x06, 0x00, 0x01, 0x00, 0x03)
+
+	// 回显和响应分多次到达，响应内容与请求相同
+	echoed := append(append([]byte{}, request...), response...)
+	transport := &chunkedTransport{chunks: [][]byte{echoed[:3], echoed[3:10], echoed[10:]}}
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).SetEchoSuppression(true)
+	if err := client.WriteSingleRegister(1, 3); err != nil {
+		t.Fatalf("WriteSingleRegister() error = %v", err)
+	}
+	if len(transport.chunks) != 0 {
+		t.Errorf("%d chunks left unread, want the response to be consumed after the echo", len(transport.chunks))
+	}
+
+	// 回显后的读响应
+	request = NewReadHoldingRegistersRequest(0x01, 0, 1)
+	response = frame(0x01, 0x03, 0x02, 0x00, 0x2A)
+	transport = &chunkedTransport{chunks: [][]byte{append(append([]byte{}, request...), response...)}}
+	client = NewClient(transport, 0x01).SetInterFrameDelay(0).SetEchoSuppression(true)
+	registers, err := client.ReadHoldingRegisters(0, 1)
+	if err != nil || len(registers) != 1 || registers[0] != 0x2A {
+		t.Fatalf("ReadHoldingRegisters() = %v, %v, want [42]", registers, err)
+	}
+
+	// 转换器没有回显时也能正常工作
+	transport = &chunkedTransport{chunks: [][]byte{response}}
+	client = NewClient(transport, 0x01).SetInterFrameDelay(0).SetEchoSuppression(true)
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("ReadHoldingRegisters() without echo error = %v", err)
+	}
+}
+
+// 测试跳过帧前的噪声字节
+func TestClientResync(t *testing.T) {
+	response := frame(0x01, 0x03, 0x02, 0x00, 0x2A)
+	noisy := append([]byte{0x00, 0xFF, 0x01, 0x03}, response...)
+
+	transport := &chunkedTransport{chunks: [][]byte{noisy}}
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0)
+	if _, err := client.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("ReadHoldingRegisters() without resync error = nil, want error")
+	}
+
+	transport = &chunkedTransport{chunks: [][]byte{noisy[:5], noisy[5:]}}
+	client = NewClient(transport, 0x01).SetInterFrameDelay(0).SetResync(true)
+	registers, err := client.ReadHoldingRegisters(0, 1)
+	if err != nil || len(registers) != 1 || registers[0] != 0x2A {
+		t.Fatalf("ReadHoldingRegisters() = %v, %v, want [42]", registers, err)
+	}
+
+	// 异常响应同样可以被找到
+	transport = &chunkedTransport{chunks: [][]byte{append([]byte{0x7F}, frame(0x01, 0x83, ExcIllegalDataAddress)...)}}
+	client = NewClient(transport, 0x01).SetInterFrameDelay(0).SetResync(true)
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("ReadHoldingRegisters() error = %v, want ErrIllegalDataAddress", err)
+	}
+}
+
+// 测试回显和噪声同时存在
+func TestExtractResponseEchoAndNoise(t *testing.T) {
+	request := NewReadInputRegistersRequest(0x02, 0x10, 2)
+	response := frame(0x02, 0x04, 0x04, 0x00, 0x01, 0x00, 0x02)
+	data := append(append(append([]byte{}, request...), 0x00, 0x00), response...)
+
+	got, status := extractResponse(data, request, 0x02, FuncReadInputRegisters, true, true)
+	if status != frameComplete || !bytes.Equal(got, response) {
+		t.Errorf("extractResponse() = % X, %v, want % X", got, status, response)
+	}
+
+	_, status = extractResponse(request[:4], request, 0x02, FuncReadInputRegisters, true, true)
+	if status != frameIncomplete {
+		t.Errorf("extractResponse() with partial echo status = %v, want incomplete", status)
+	}
+}
+
+// 测试在未知功能码的数据中查找帧
+func TestFindFrameUnknownFunction(t *testing.T) {
+	custom := frame(0x01, 0x41, 0xAA, 0xBB, 0xCC)
+	got, ok := findFrame(append([]byte{0x55, 0x01}, custom...), 0x01, 0x41)
+	if !ok || !bytes.Equal(got, custom) {
+		t.Errorf("findFrame() = % X, %v, want % X", got, ok, custom)
+	}
+}
@@ -0,0 +1,95 @@
+package modbus
+
+import (
+	"errors"
+	"sync"
+)
+
+// gateway.go 实现了 Modbus TCP 到 RTU 的网关
+
+// Gateway 把 Modbus TCP 请求转发到 RTU 总线，单元 ID 作为从站 ID
+//
+// Gateway 实现了 Handler 接口，与 TCPServer 一起使用：
+//
+//	server := modbus.NewTCPServer(modbus.NewGateway(client))
+//	server.ListenAndServe(":502")
+//
+// 多个 TCP 主站的请求由客户端串行地发送到总线上。从站没有响应（超时、CRC 错误等）时
+// 返回网关目标设备无响应异常（0x0B）；单元 ID 不在路由范围内、从站已熔断或总线不可用时
+// 返回网关路径不可用异常（0x0A）。从站返回的异常原样转发。
+// 单元 ID 为 0 的请求作为广播发送到总线上，不等待响应，也不向 TCP 主站响应。
+type Gateway struct {
+	client *Client
+
+	mu    sync.RWMutex
+	units map[byte]bool // 允许转发的单元 ID，nil 表示全部转发
+}
+
+// NewGateway 创建一个通过 client 访问 RTU 总线的网关
+// client 的重试策略、熔断器和钩子同样作用于转发的请求
+func NewGateway(client *Client) *Gateway {
+	return &Gateway{client: client}
+}
+
+// SetUnits 设置总线上存在的从站 ID，发往其他单元 ID 的请求直接返回网关路径不可用，
+// 不占用总线等待超时。不设置时转发所有单元 ID，广播（单元 ID 0）总是转发
+func (g *Gateway) SetUnits(unitIDs ...byte) *Gateway {
+	units := make(map[byte]bool, len(unitIDs))
+	for _, id := range unitIDs {
+		units[id] = true
+	}
+
+	g.mu.Lock()
+	g.units = units
+	g.mu.Unlock()
+	return g
+}
+
+// ServeModbus 实现 Handler 接口
+func (g *Gateway) ServeModbus(req *Request) ([]byte, error) {
+	if req.UnitID != 0 && !g.routable(req.UnitID) {
+		return nil, ErrGatewayPathUnavailable
+	}
+
+	pdu := make([]byte, 0, 1+len(req.Data))
+	pdu = append(pdu, req.FunctionCode)
+	pdu = append(pdu, req.Data...)
+
+	if req.UnitID == 0 {
+		// 广播没有响应，总线出错时同样无法通知主站
+		g.client.Broadcast(pdu)
+		return nil, ErrNoResponse
+	}
+
+	response, err := g.client.Send(req.UnitID, pdu)
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+	return response[1:], nil
+}
+
+// 判断单元 ID 是否在路由范围内
+func (g *Gateway) routable(unitID byte) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.units == nil || g.units[unitID]
+}
+
+// 将转发请求的错误映射为返回给 TCP 主站的异常
+func gatewayError(err error) error {
+	var modbusErr *ModbusError
+	switch {
+	case errors.As(err, &modbusErr):
+		return modbusErr
+	case IsTimeout(err),
+		errors.Is(err, ErrCRCMismatch),
+		errors.Is(err, ErrResponseTooShort),
+		errors.Is(err, ErrInvalidSlaveID),
+		errors.Is(err, ErrInvalidFunction):
+		// 从站没有给出有效的响应
+		return ErrGatewayTargetDeviceFailedToRespond
+	default:
+		// 熔断、总线读写错误等
+		return ErrGatewayPathUnavailable
+	}
+}
@@ -0,0 +1,160 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 模拟 RTU 总线的传输接口，handle 根据请求帧生成响应帧，返回 nil 表示从站不响应
+type rtuBus struct {
+	t       *testing.T
+	handle  func(request []byte) []byte
+	mu      sync.Mutex
+	pending []byte
+	busy    bool // 请求已发送，响应尚未读取
+}
+
+func (b *rtuBus) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.busy {
+		b.t.Error("request sent while previous transaction in progress")
+	}
+	b.busy = p[0] != 0 // 广播不读取响应
+	b.pending = b.handle(p)
+	return len(p), nil
+}
+
+func (b *rtuBus) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.busy = false
+	if b.pending == nil {
+		return 0, ErrTimeout
+	}
+	n := copy(p, b.pending)
+	b.pending = nil
+	return n, nil
+}
+
+// 从站 1 返回起始地址作为寄存器值，地址 9999 返回异常，其他从站不响应
+func gatewayBus(t *testing.T) *rtuBus {
+	return &rtuBus{t: t, handle: func(request []byte) []byte {
+		if request[0] != 0x01 {
+			return nil
+		}
+		address := binary.BigEndian.Uint16(request[2:4])
+		if address == 9999 {
+			return frame(0x01, request[1]|0x80, ExcIllegalDataAddress)
+		}
+		time.Sleep(time.Millisecond)
+		return frame(0x01, request[1], 0x02, byte(address>>8), byte(address))
+	}}
+}
+
+func TestGateway(t *testing.T) {
+	serial := NewClient(gatewayBus(t), 0).SetInterFrameDelay(0)
+	gateway := NewGateway(serial).SetUnits(1, 2)
+	addr := startTCPServer(t, NewTCPServer(gateway))
+
+	transport := NewTCPTransport(addr)
+	defer transport.Close()
+
+	registers, err := NewTCPClient(transport, 1).ReadHoldingRegisters(42, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if registers[0] != 42 {
+		t.Errorf("ReadHoldingRegisters() = %v, want [42]", registers)
+	}
+
+	tests := []struct {
+		unit    byte
+		address uint16
+		want    error
+	}{
+		{1, 9999, ErrIllegalDataAddress},
+		{2, 0, ErrGatewayTargetDeviceFailedToRespond},
+		{3, 0, ErrGatewayPathUnavailable},
+	}
+	for _, tt := range tests {
+		_, err := NewTCPClient(transport, tt.unit).ReadHoldingRegisters(tt.address, 1)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("unit %d ReadHoldingRegisters(%d) error = %v, want %v", tt.unit, tt.address, err, tt.want)
+		}
+	}
+}
+
+// 测试广播请求发送到总线后立即返回，不等待响应超时
+func TestGatewayBroadcast(t *testing.T) {
+	var broadcasts [][]byte
+	bus := gatewayBus(t)
+	handle := bus.handle
+	bus.handle = func(request []byte) []byte {
+		if request[0] == 0 {
+			broadcasts = append(broadcasts, append([]byte(nil), request...))
+		}
+		return handle(request)
+	}
+	serial := NewClient(bus, 0).SetInterFrameDelay(0).SetTimeout(time.Second)
+	gateway := NewGateway(serial).SetUnits(1)
+
+	start := time.Now()
+	_, err := gateway.ServeModbus(&Request{UnitID: 0, FunctionCode: FuncWriteSingleRegister, Data: []byte{0x00, 0x05, 0x12, 0x34}})
+	if !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("broadcast error = %v, want ErrNoResponse", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("broadcast took %v, want no response timeout", elapsed)
+	}
+	want := NewWriteSingleRegisterRequest(0, 5, 0x1234)
+	if len(broadcasts) != 1 || string(broadcasts[0]) != string(want) {
+		t.Errorf("broadcast frames = % x, want % x", broadcasts, want)
+	}
+
+	// 广播之后总线可以继续使用
+	if data, err := gateway.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 7, 1)); err != nil || string(data) != "\x02\x00\x07" {
+		t.Errorf("read after broadcast = % x, %v", data, err)
+	}
+}
+
+// 测试多个 TCP 主站的并发请求被串行地发送到总线上
+func TestGatewaySerializesBus(t *testing.T) {
+	serial := NewClient(gatewayBus(t), 0).SetInterFrameDelay(0)
+	addr := startTCPServer(t, NewTCPServer(NewGateway(serial)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		transport := NewTCPTransport(addr)
+		defer transport.Close()
+		client := NewTCPClient(transport, 1)
+
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func(address uint16) {
+				defer wg.Done()
+				registers, err := client.ReadHoldingRegisters(address, 1)
+				if err != nil || registers[0] != address {
+					t.Errorf("ReadHoldingRegisters(%d) = %v, %v", address, registers, err)
+				}
+			}(uint16(i*10 + j))
+		}
+	}
+	wg.Wait()
+}
+
+func TestClientSend(t *testing.T) {
+	transport := &scriptedTransport{responses: [][]byte{frame(0x05, FuncReadExceptionStatus, 0x6D)}}
+	client := NewClient(transport, 1).SetInterFrameDelay(0)
+
+	response, err := client.Send(0x05, []byte{FuncReadExceptionStatus})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if string(response) != string([]byte{FuncReadExceptionStatus, 0x6D}) {
+		t.Errorf("Send() = % x, want 07 6d", response)
+	}
+}
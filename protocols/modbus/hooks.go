@@ -0,0 +1,98 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// hooks.go 定义了客户端交互钩子以及基于 log 和 log/slog 的报文日志实现
+
+// Transaction 描述一次在总线上进行的请求/响应交互
+// Request 和 Response 引用客户端内部缓冲区，钩子如需在调用结束后保留需自行复制
+type Transaction struct {
+	SlaveID      byte          // 从站 ID
+	FunctionCode byte          // 请求的功能码
+	Attempt      int           // 第几次尝试，从 1 开始
+	Request      []byte        // 发送的请求帧
+	Response     []byte        // 收到的响应帧，可能不完整或校验失败
+	Start        time.Time     // 开始时间
+	Latency      time.Duration // 耗时，AfterTransaction 中有效
+	Err          error         // 交互结果，AfterTransaction 中有效
+}
+
+// Hook 是客户端交互钩子，在每次请求发送前后被调用
+// 钩子在客户端的调用协程中同步执行，不应长时间阻塞
+type Hook interface {
+	// BeforeTransaction 在请求发送前调用
+	BeforeTransaction(tx *Transaction)
+	// AfterTransaction 在收到响应或出错后调用
+	AfterTransaction(tx *Transaction)
+}
+
+// HookFuncs 使用函数实现 Hook 接口，未设置的函数会被忽略
+type HookFuncs struct {
+	Before func(tx *Transaction)
+	After  func(tx *Transaction)
+}
+
+// BeforeTransaction 实现 Hook 接口
+func (h HookFuncs) BeforeTransaction(tx *Transaction) {
+	if h.Before != nil {
+		h.Before(tx)
+	}
+}
+
+// AfterTransaction 实现 Hook 接口
+func (h HookFuncs) AfterTransaction(tx *Transaction) {
+	if h.After != nil {
+		h.After(tx)
+	}
+}
+
+// NewLogHook 创建使用标准库 log 记录十六进制报文的钩子
+// logger 为 nil 时使用 log 包的默认 Logger
+func NewLogHook(logger *log.Logger) Hook {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return HookFuncs{
+		After: func(tx *Transaction) {
+			msg := fmt.Sprintf("modbus: slave %d %s attempt %d tx=[% X] rx=[% X] latency=%v",
+				tx.SlaveID, FunctionName(tx.FunctionCode), tx.Attempt, tx.Request, tx.Response, tx.Latency)
+			if tx.Err != nil {
+				msg += " err=" + tx.Err.Error()
+			}
+			logger.Print(msg)
+		},
+	}
+}
+
+// NewSlogHook 创建使用 log/slog 记录十六进制报文的钩子
+// 成功的交互以 Debug 级别记录，失败的交互以 Warn 级别记录
+// logger 为 nil 时使用 slog 的默认 Logger
+func NewSlogHook(logger *slog.Logger) Hook {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return HookFuncs{
+		After: func(tx *Transaction) {
+			level := slog.LevelDebug
+			attrs := []slog.Attr{
+				slog.Int("slave", int(tx.SlaveID)),
+				slog.String("function", FunctionName(tx.FunctionCode)),
+				slog.Int("attempt", tx.Attempt),
+				slog.String("tx", fmt.Sprintf("% X", tx.Request)),
+				slog.String("rx", fmt.Sprintf("% X", tx.Response)),
+				slog.Duration("latency", tx.Latency),
+			}
+			if tx.Err != nil {
+				level = slog.LevelWarn
+				attrs = append(attrs, slog.Any("error", tx.Err))
+			}
+			logger.LogAttrs(context.Background(), level, "modbus transaction", attrs...)
+		},
+	}
+}
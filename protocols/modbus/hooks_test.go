@@ -0,0 +1,92 @@
+package modbus
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试钩子在每次尝试前后被调用并携带报文
+func TestHooks(t *testing.T) {
+	transport := &scriptedTransport{responses: [][]byte{
+		corruptFrame(0x01, 0x03, 0x02, 0x00, 0x2A),
+		frame(0x01, 0x03, 0x02, 0x00, 0x2A),
+	}}
+
+	var before, after []Transaction
+	hook := HookFuncs{
+		Before: func(tx *Transaction) { before = append(before, *tx) },
+		After:  func(tx *Transaction) { after = append(after, *tx) },
+	}
+	policy := NewRetryPolicy(2)
+	policy.InitialBackoff = time.Millisecond
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).SetRetryPolicy(policy).AddHook(hook)
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+
+	if len(before) != 2 || len(after) != 2 {
+		t.Fatalf("hooks called %d/%d times, want 2/2", len(before), len(after))
+	}
+	if after[0].Attempt != 1 || after[0].Err == nil || len(after[0].Response) == 0 {
+		t.Errorf("first transaction = %+v, want attempt 1 with CRC error and raw response", after[0])
+	}
+	if after[1].Attempt != 2 || after[1].Err != nil {
+		t.Errorf("second transaction = %+v, want attempt 2 without error", after[1])
+	}
+	if !bytes.Equal(after[1].Request, NewReadHoldingRegistersRequest(0x01, 0, 1)) {
+		t.Errorf("Request = % X, want read holding registers request", after[1].Request)
+	}
+}
+
+// 测试 log 钩子输出十六进制报文
+func TestLogHook(t *testing.T) {
+	var buf bytes.Buffer
+	transport := &scriptedTransport{responses: [][]byte{frame(0x01, 0x06, 0x00, 0x01, 0x00, 0x03)}}
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).AddHook(NewLogHook(log.New(&buf, "", 0)))
+
+	if err := client.WriteSingleRegister(1, 3); err != nil {
+		t.Fatalf("WriteSingleRegister() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"slave 1", "write single register", "tx=[01 06 00 01 00 03", "rx=[01 06 00 01 00 03"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output %q does not contain %q", out, want)
+		}
+	}
+}
+
+// 测试统计数据的 Prometheus 文本输出
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(0.1, 1)
+	metrics.AfterTransaction(&Transaction{SlaveID: 1, FunctionCode: FuncReadHoldingRegisters, Latency: 20 * time.Millisecond})
+	metrics.AfterTransaction(&Transaction{SlaveID: 1, FunctionCode: FuncReadHoldingRegisters, Latency: 500 * time.Millisecond, Err: ErrCRCMismatch})
+	metrics.AfterTransaction(&Transaction{SlaveID: 1, FunctionCode: FuncReadHoldingRegisters, Latency: 2 * time.Second, Err: ParseError(0x83, ExcIllegalDataAddress)})
+
+	if got := metrics.Requests(1, FuncReadHoldingRegisters, "crc_error"); got != 1 {
+		t.Errorf("Requests(crc_error) = %d, want 1", got)
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`modbus_requests_total{slave="1",function="read_holding_registers",result="ok"} 1`,
+		`modbus_requests_total{slave="1",function="read_holding_registers",result="exception"} 1`,
+		`modbus_request_duration_seconds_bucket{slave="1",function="read_holding_registers",le="0.1"} 1`,
+		`modbus_request_duration_seconds_bucket{slave="1",function="read_holding_registers",le="1"} 2`,
+		`modbus_request_duration_seconds_bucket{slave="1",function="read_holding_registers",le="+Inf"} 3`,
+		`modbus_request_duration_seconds_sum{slave="1",function="read_holding_registers"} 2.52`,
+		`modbus_request_duration_seconds_count{slave="1",function="read_holding_registers"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// mbap.go 实现了 Modbus TCP/UDP 使用的 MBAP 报文头编解码，以及与 RTU 帧之间的转换
+
+const (
+	mbapHeaderLength = 7   // 事务 ID(2) + 协议 ID(2) + 长度(2) + 单元 ID(1)
+	maxPDULength     = 253 // PDU 最大长度
+)
+
+// ErrInvalidMBAP 表示 MBAP 报文头无效
+var ErrInvalidMBAP = errors.New("modbus: invalid MBAP header")
+
+// mbapFrame 表示一个 MBAP 帧
+type mbapFrame struct {
+	TransactionID uint16
+	UnitID        byte
+	PDU           []byte // 功能码 + 数据
+}
+
+// encode 将 MBAP 帧编码为字节序列
+func (f *mbapFrame) encode() []byte {
+	b := make([]byte, mbapHeaderLength+len(f.PDU))
+	binary.BigEndian.PutUint16(b[0:2], f.TransactionID)
+	binary.BigEndian.PutUint16(b[2:4], 0) // Modbus 协议 ID 固定为 0
+	binary.BigEndian.PutUint16(b[4:6], uint16(1+len(f.PDU)))
+	b[6] = f.UnitID
+	copy(b[mbapHeaderLength:], f.PDU)
+	return b
+}
+
+// decodeMBAP 从完整的数据报中解码 MBAP 帧
+func decodeMBAP(b []byte) (*mbapFrame, error) {
+	if len(b) < mbapHeaderLength+1 {
+		return nil, ErrResponseTooShort
+	}
+	length, err := checkMBAPHeader(b[:mbapHeaderLength])
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != mbapHeaderLength-1+length {
+		return nil, fmt.Errorf("%w: length %d, got %d bytes", ErrInvalidMBAP, length, len(b)-mbapHeaderLength+1)
+	}
+	return &mbapFrame{
+		TransactionID: binary.BigEndian.Uint16(b[0:2]),
+		UnitID:        b[6],
+		PDU:           b[mbapHeaderLength:],
+	}, nil
+}
+
+// readMBAP 从数据流中读取一个 MBAP 帧
+func readMBAP(r io.Reader) (*mbapFrame, error) {
+	header := make([]byte, mbapHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length, err := checkMBAPHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(r, pdu); err != nil {
+		return nil, err
+	}
+	return &mbapFrame{
+		TransactionID: binary.BigEndian.Uint16(header[0:2]),
+		UnitID:        header[6],
+		PDU:           pdu,
+	}, nil
+}
+
+// 检查协议 ID 和长度字段，返回长度字段的值（单元 ID + PDU）
+func checkMBAPHeader(header []byte) (int, error) {
+	if protocolID := binary.BigEndian.Uint16(header[2:4]); protocolID != 0 {
+		return 0, fmt.Errorf("%w: protocol ID %d", ErrInvalidMBAP, protocolID)
+	}
+	length := int(binary.BigEndian.Uint16(header[4:6]))
+	if length < 2 || length > maxPDULength+1 {
+		return 0, fmt.Errorf("%w: length %d", ErrInvalidMBAP, length)
+	}
+	return length, nil
+}
+
+// rtuToPDU 从 RTU 帧中取出从站 ID 和 PDU（不校验 CRC）
+func rtuToPDU(frame []byte) (byte, []byte, error) {
+	if len(frame) < 4 {
+		return 0, nil, ErrInvalidLength
+	}
+	return frame[0], frame[1 : len(frame)-2], nil
+}
+
+// pduToRTU 将从站 ID 和 PDU 组装为带 CRC 的 RTU 帧
+func pduToRTU(unitID byte, pdu []byte) []byte {
+	frame := make([]byte, 0, 1+len(pdu)+2)
+	frame = append(frame, unitID)
+	frame = append(frame, pdu...)
+	return AppendCRC16InPlace(frame)
+}
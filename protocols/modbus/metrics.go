@@ -0,0 +1,182 @@
+package modbus
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metrics.go 实现了按从站和功能码统计的请求计数与延迟直方图，并支持导出为 Prometheus 文本格式
+
+// DefaultLatencyBuckets 是延迟直方图的默认分桶上界（秒）
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Metrics 是统计交互次数和延迟的钩子，可通过 AddHook 添加到多个客户端
+// Metrics 同时实现了 http.Handler，可以直接挂载为 Prometheus 抓取端点
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[latencyKey]*histogram
+}
+
+// 请求计数的标签
+type requestKey struct {
+	slaveID      byte
+	functionCode byte
+	result       string
+}
+
+// 延迟直方图的标签
+type latencyKey struct {
+	slaveID      byte
+	functionCode byte
+}
+
+// 延迟直方图
+type histogram struct {
+	counts []uint64 // 每个分桶的计数（非累计）
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics 创建统计钩子，buckets 为空时使用 DefaultLatencyBuckets
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &Metrics{
+		buckets:   buckets,
+		requests:  make(map[requestKey]uint64),
+		latencies: make(map[latencyKey]*histogram),
+	}
+}
+
+// BeforeTransaction 实现 Hook 接口
+func (m *Metrics) BeforeTransaction(tx *Transaction) {}
+
+// AfterTransaction 实现 Hook 接口
+func (m *Metrics) AfterTransaction(tx *Transaction) {
+	seconds := tx.Latency.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{tx.SlaveID, tx.FunctionCode, resultLabel(tx.Err)}]++
+
+	key := latencyKey{tx.SlaveID, tx.FunctionCode}
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latencies[key] = h
+	}
+	if i, _ := slices.BinarySearch(m.buckets, seconds); i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Requests 返回指定从站、功能码和结果的请求次数
+// result 取值为 ok、exception、timeout、crc_error 或 error
+func (m *Metrics) Requests(slaveID, functionCode byte, result string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[requestKey{slaveID, functionCode, result}]
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出统计数据
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		requestKeys = append(requestKeys, k)
+	}
+	slices.SortFunc(requestKeys, func(a, b requestKey) int {
+		if c := compareLabels(a.slaveID, a.functionCode, b.slaveID, b.functionCode); c != 0 {
+			return c
+		}
+		return strings.Compare(a.result, b.result)
+	})
+
+	fmt.Fprintln(bw, "# HELP modbus_requests_total Total number of Modbus transactions.")
+	fmt.Fprintln(bw, "# TYPE modbus_requests_total counter")
+	for _, k := range requestKeys {
+		fmt.Fprintf(bw, "modbus_requests_total{%s,result=%q} %d\n",
+			labels(k.slaveID, k.functionCode), k.result, m.requests[k])
+	}
+
+	latencyKeys := make([]latencyKey, 0, len(m.latencies))
+	for k := range m.latencies {
+		latencyKeys = append(latencyKeys, k)
+	}
+	slices.SortFunc(latencyKeys, func(a, b latencyKey) int {
+		return compareLabels(a.slaveID, a.functionCode, b.slaveID, b.functionCode)
+	})
+
+	fmt.Fprintln(bw, "# HELP modbus_request_duration_seconds Latency of Modbus transactions.")
+	fmt.Fprintln(bw, "# TYPE modbus_request_duration_seconds histogram")
+	for _, k := range latencyKeys {
+		h := m.latencies[k]
+		l := labels(k.slaveID, k.functionCode)
+		var cumulative uint64
+		for i, upper := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(bw, "modbus_request_duration_seconds_bucket{%s,le=%q} %d\n",
+				l, strconv.FormatFloat(upper, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(bw, "modbus_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, h.count)
+		fmt.Fprintf(bw, "modbus_request_duration_seconds_sum{%s} %s\n", l, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "modbus_request_duration_seconds_count{%s} %d\n", l, h.count)
+	}
+
+	return bw.Flush()
+}
+
+// ServeHTTP 实现 http.Handler，输出 Prometheus 文本格式
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// 生成从站和功能码标签
+func labels(slaveID, functionCode byte) string {
+	return fmt.Sprintf("slave=\"%d\",function=%q", slaveID, strings.ReplaceAll(FunctionName(functionCode), " ", "_"))
+}
+
+func compareLabels(slaveA, functionA, slaveB, functionB byte) int {
+	if slaveA != slaveB {
+		return int(slaveA) - int(slaveB)
+	}
+	return int(functionA) - int(functionB)
+}
+
+// 将交互结果归类为统计标签
+func resultLabel(err error) string {
+	var modbusErr *ModbusError
+	switch {
+	case err == nil:
+		return "ok"
+	case IsTimeout(err):
+		return "timeout"
+	case errors.As(err, &modbusErr):
+		return "exception"
+	case errors.Is(err, ErrCRCMismatch):
+		return "crc_error"
+	default:
+		return "error"
+	}
+}
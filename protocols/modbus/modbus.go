@@ -39,7 +39,10 @@ const (
 	ExcGatewayTargetDeviceFailedToRespond byte = 0x0B // 网关目标设备无响应
 )
 
-// ModbusError 表示 Modbus 错误
+// ModbusError 表示从站返回的 Modbus 异常响应
+//
+// 可以使用 errors.Is 与 ErrIllegalDataAddress 等哨兵错误比较异常码，
+// 也可以使用 errors.As 取出功能码和异常码。
 type ModbusError struct {
 	FunctionCode  byte
 	ExceptionCode byte
@@ -47,7 +50,21 @@ type ModbusError struct {
 
 // Error 实现 error 接口
 func (e *ModbusError) Error() string {
-	return fmt.Sprintf("modbus: function code %#.2x exception: %#.2x", e.FunctionCode, e.ExceptionCode)
+	if e.FunctionCode == 0 {
+		return fmt.Sprintf("modbus: %s (exception %#.2x)", ExceptionName(e.ExceptionCode), e.ExceptionCode)
+	}
+	return fmt.Sprintf("modbus: %s (exception %#.2x) in response to %s (function %#.2x)",
+		ExceptionName(e.ExceptionCode), e.ExceptionCode, FunctionName(e.FunctionCode), e.FunctionCode)
+}
+
+// Is 支持 errors.Is 比较
+// 目标的功能码为 0 时（即异常码哨兵错误）只比较异常码
+func (e *ModbusError) Is(target error) bool {
+	t, ok := target.(*ModbusError)
+	if !ok {
+		return false
+	}
+	return t.ExceptionCode == e.ExceptionCode && (t.FunctionCode == 0 || t.FunctionCode == e.FunctionCode)
 }
 
 // IsError 检查响应是否为错误
@@ -84,3 +101,12 @@ var ErrInvalidFunction = errors.New("modbus: invalid function")
 
 // ErrInvalidLength 表示长度无效
 var ErrInvalidLength = errors.New("modbus: invalid length")
+
+// ErrAddressMismatch 表示写响应中的地址与请求不一致
+var ErrAddressMismatch = errors.New("modbus: address mismatch in response")
+
+// ErrValueMismatch 表示写响应中的值与请求不一致
+var ErrValueMismatch = errors.New("modbus: value mismatch in response")
+
+// ErrQuantityMismatch 表示响应中的数量与请求不一致
+var ErrQuantityMismatch = errors.New("modbus: quantity mismatch in response")
@@ -3,6 +3,8 @@ package modbus
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
+	"hash"
 	"testing"
 )
 
@@ -71,6 +73,90 @@ func TestAppendAndCheckCRC16(t *testing.T) {
 	}
 }
 
+// 逐位计算的 CRC-16，作为查表实现的参照
+func bitwiseCRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// 测试查表实现与逐位计算的结果一致
+func TestCRC16Table(t *testing.T) {
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i*131 + i>>3)
+	}
+	for n := range len(data) {
+		for _, start := range []int{0, 1, 3} {
+			if start > n {
+				continue
+			}
+			if got, want := CRC16(data[start:n]), bitwiseCRC16(data[start:n]); got != want {
+				t.Fatalf("CRC16(data[%d:%d]) = %04X, want %04X", start, n, got, want)
+			}
+		}
+	}
+}
+
+// 测试增量计算的哈希
+func TestCRC16Hash(t *testing.T) {
+	frame := []byte{0x01, 0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x0A, 0x01, 0x02, 0x55}
+	var h hash.Hash = NewCRC16()
+	if h.Size() != 2 || h.BlockSize() != 1 {
+		t.Errorf("Size() = %d, BlockSize() = %d", h.Size(), h.BlockSize())
+	}
+
+	// 分段写入与一次计算的结果相同
+	h16 := h.(Hash16)
+	for _, chunk := range [][]byte{frame[:1], frame[1:9], frame[9:]} {
+		h16.Write(chunk)
+	}
+	if h16.Sum16() != CRC16(frame) {
+		t.Errorf("Sum16() = %04X, want %04X", h16.Sum16(), CRC16(frame))
+	}
+	if got := h16.Sum(frame); !bytes.Equal(got, AppendCRC16(frame)) || !CheckCRC16(got) {
+		t.Errorf("Sum(frame) = % X, want % X", got, AppendCRC16(frame))
+	}
+
+	h16.Reset()
+	if h16.Sum16() != 0xFFFF {
+		t.Errorf("Sum16() after Reset = %04X", h16.Sum16())
+	}
+}
+
+// 测试原地追加 CRC
+func TestAppendCRC16InPlace(t *testing.T) {
+	buf := make([]byte, 6, 8)
+	copy(buf, []byte{0x01, 0x03, 0x00, 0x6B, 0x00, 0x01})
+	frame := AppendCRC16InPlace(buf)
+	if !bytes.Equal(frame, AppendCRC16(buf)) {
+		t.Errorf("AppendCRC16InPlace() = % X, want % X", frame, AppendCRC16(buf))
+	}
+	if &frame[0] != &buf[0] {
+		t.Error("AppendCRC16InPlace() copied the frame")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { AppendCRC16InPlace(buf) }); allocs != 0 {
+		t.Errorf("AppendCRC16InPlace() allocated %.1f times", allocs)
+	}
+}
+
+func BenchmarkCRC16(b *testing.B) {
+	data := make([]byte, maxRTUFrameLength-2)
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		CRC16(data)
+	}
+}
+
 // 模拟传输接口
 type mockTransport struct {
 	t          *testing.T
@@ -96,6 +182,24 @@ func (m *mockTransport) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// 测试读位请求的数量总是编码为 2 个字节（取自 Modbus 协议规范的示例帧）
+func TestReadBitsRequestEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		request []byte
+		want    []byte
+	}{
+		{"read coils", NewReadCoilsRequest(0x11, 0x0013, 0x0025), []byte{0x11, 0x01, 0x00, 0x13, 0x00, 0x25, 0x0E, 0x84}},
+		{"read discrete inputs", NewReadDiscreteInputsRequest(0x11, 0x00C4, 0x0016), []byte{0x11, 0x02, 0x00, 0xC4, 0x00, 0x16, 0xBA, 0xA9}},
+		{"read 300 coils", NewReadCoilsRequest(0x01, 0x0000, 300), AppendCRC16([]byte{0x01, 0x01, 0x00, 0x00, 0x01, 0x2C})},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.request, tt.want) {
+			t.Errorf("%s: request = % X, want % X", tt.name, tt.request, tt.want)
+		}
+	}
+}
+
 // 测试读取保持寄存器请求和响应
 func TestReadHoldingRegisters(t *testing.T) {
 	// 模拟请求和响应
@@ -231,11 +335,15 @@ func TestExceptionResponse(t *testing.T) {
 	}
 
 	// 验证错误类型
-	modbusError, ok := err.(*ModbusError)
-	if !ok {
+	var modbusError *ModbusError
+	if !errors.As(err, &modbusError) {
 		t.Fatalf("err is not a ModbusError, got %T", err)
 	}
 
+	if !errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("errors.Is(err, ErrIllegalDataAddress) = false, err = %v", err)
+	}
+
 	if modbusError.FunctionCode != FuncReadHoldingRegisters {
 		t.Errorf("ModbusError.FunctionCode = 0x%02X, want 0x%02X", modbusError.FunctionCode, FuncReadHoldingRegisters)
 	}
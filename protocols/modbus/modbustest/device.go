@@ -0,0 +1,265 @@
+// Package modbustest 提供用于测试 Modbus 客户端的进程内模拟设备
+//
+// Device 通过内存管道连接一个真实的 RTU 从站实现，寄存器保存在 modbus.DataModel 中，
+// 可以按顺序注入异常、延迟或不响应等行为，并检查设备收到的请求：
+//
+//	dev := modbustest.NewDevice(1)
+//	defer dev.Close()
+//
+//	client := dev.Client()
+//	client.WriteSingleRegister(10, 42)
+//	dev.ExpectWrite(t, 10, 42)
+package modbustest
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+// device.go 实现了模拟从站设备以及断言辅助方法
+
+// Behavior 决定设备如何处理一个请求，next 是设备的数据模型
+type Behavior func(req *modbus.Request, next modbus.Handler) ([]byte, error)
+
+// Normal 按数据模型正常处理请求
+func Normal() Behavior {
+	return func(req *modbus.Request, next modbus.Handler) ([]byte, error) {
+		return next.ServeModbus(req)
+	}
+}
+
+// Exception 返回指定异常码的异常响应，不修改数据模型
+func Exception(exceptionCode byte) Behavior {
+	return func(req *modbus.Request, next modbus.Handler) ([]byte, error) {
+		return nil, &modbus.ModbusError{FunctionCode: req.FunctionCode, ExceptionCode: exceptionCode}
+	}
+}
+
+// NoResponse 不发送响应，也不修改数据模型
+func NoResponse() Behavior {
+	return func(req *modbus.Request, next modbus.Handler) ([]byte, error) {
+		return nil, modbus.ErrNoResponse
+	}
+}
+
+// Delay 等待指定时间后按数据模型正常处理请求
+func Delay(delay time.Duration) Behavior {
+	return func(req *modbus.Request, next modbus.Handler) ([]byte, error) {
+		time.Sleep(delay)
+		return next.ServeModbus(req)
+	}
+}
+
+// Respond 返回固定的响应数据（PDU 中功能码之后的部分），不修改数据模型
+func Respond(data []byte) Behavior {
+	return func(req *modbus.Request, next modbus.Handler) ([]byte, error) {
+		return data, nil
+	}
+}
+
+// Write 记录设备收到的一次写请求
+type Write struct {
+	UnitID       byte
+	FunctionCode byte
+	Table        modbus.Table
+	Address      uint16
+	Values       []uint16 // 线圈的值为 0 或 1
+}
+
+// String 返回写请求的描述
+func (w Write) String() string {
+	return fmt.Sprintf("%v %d = %v", w.Table, w.Address, w.Values)
+}
+
+// Device 是通过内存管道连接的模拟 RTU 从站
+//
+// 默认的数据模型覆盖全部 65536 个地址，初始值均为 0。
+// 设备收到的每个请求都会被记录，包括被脚本替换为异常或不响应的请求。
+type Device struct {
+	SlaveID byte
+	Faults  *modbus.FaultInjector // 响应帧的线路故障，默认不注入故障
+
+	client *Conn // 客户端一端
+	server *Conn // 设备一端
+	done   chan struct{}
+
+	mu       sync.Mutex
+	model    *modbus.DataModel
+	script   []Behavior
+	requests []*modbus.Request
+	writes   []Write
+}
+
+// NewDevice 创建并启动模拟设备，使用完毕后需要调用 Close
+func NewDevice(slaveID byte) *Device {
+	d := &Device{
+		SlaveID: slaveID,
+		Faults:  modbus.NewFaultInjector(1),
+		model:   modbus.NewDataModel(65536),
+		done:    make(chan struct{}),
+	}
+	d.client, d.server = Pipe()
+
+	server := modbus.NewRTUServer(modbus.HandlerFunc(d.serve), slaveID)
+	go func() {
+		defer close(d.done)
+		server.Serve(d.Faults.ServerTransport(d.server))
+	}()
+	return d
+}
+
+// SetModel 替换设备的数据模型，例如使用较小的地址空间测试非法地址异常
+func (d *Device) SetModel(model *modbus.DataModel) *Device {
+	d.mu.Lock()
+	d.model = model
+	d.mu.Unlock()
+	return d
+}
+
+// Model 返回设备的数据模型，可以直接读写寄存器
+func (d *Device) Model() *modbus.DataModel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.model
+}
+
+// Transport 返回连接到设备的传输接口
+func (d *Device) Transport() *Conn {
+	return d.client
+}
+
+// Client 返回连接到设备的客户端，超时为 500ms，没有帧间延时
+func (d *Device) Client() *modbus.Client {
+	return modbus.NewClient(d.client, d.SlaveID).
+		SetTimeout(500 * time.Millisecond).
+		SetInterFrameDelay(0)
+}
+
+// Script 追加按顺序使用的行为，每个请求消耗一个，用完后恢复正常处理
+func (d *Device) Script(behaviors ...Behavior) *Device {
+	d.mu.Lock()
+	d.script = append(d.script, behaviors...)
+	d.mu.Unlock()
+	return d
+}
+
+// Close 关闭管道并等待设备退出
+func (d *Device) Close() error {
+	d.client.Close()
+	<-d.done
+	return nil
+}
+
+// Requests 返回设备收到的所有请求
+func (d *Device) Requests() []*modbus.Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.requests)
+}
+
+// Writes 返回设备收到的所有写请求
+func (d *Device) Writes() []Write {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.writes)
+}
+
+// Reset 清空请求记录和未使用的脚本
+func (d *Device) Reset() {
+	d.mu.Lock()
+	d.script = nil
+	d.requests = nil
+	d.writes = nil
+	d.mu.Unlock()
+}
+
+// 处理请求：记录后按脚本或数据模型处理
+func (d *Device) serve(req *modbus.Request) ([]byte, error) {
+	d.mu.Lock()
+	d.requests = append(d.requests, req)
+	if table, write := modbus.FunctionTable(req.FunctionCode); write {
+		address, _, _ := req.AddressRange()
+		values, _ := modbus.RequestValues(req.FunctionCode, req.Data)
+		d.writes = append(d.writes, Write{
+			UnitID:       req.UnitID,
+			FunctionCode: req.FunctionCode,
+			Table:        table,
+			Address:      address,
+			Values:       values,
+		})
+	}
+	behavior := Normal()
+	if len(d.script) > 0 {
+		behavior = d.script[0]
+		d.script = d.script[1:]
+	}
+	model := d.model
+	d.mu.Unlock()
+
+	return behavior(req, model)
+}
+
+// ExpectWrite 断言设备收到过从 address 开始写入 values 的保持寄存器写请求
+func (d *Device) ExpectWrite(t testing.TB, address uint16, values ...uint16) {
+	t.Helper()
+	d.expectWrite(t, Write{Table: modbus.TableHoldingRegisters, Address: address, Values: values})
+}
+
+// ExpectCoilWrite 断言设备收到过从 address 开始写入 values 的线圈写请求
+func (d *Device) ExpectCoilWrite(t testing.TB, address uint16, values ...bool) {
+	t.Helper()
+	w := Write{Table: modbus.TableCoils, Address: address}
+	for _, v := range values {
+		if v {
+			w.Values = append(w.Values, 1)
+		} else {
+			w.Values = append(w.Values, 0)
+		}
+	}
+	d.expectWrite(t, w)
+}
+
+func (d *Device) expectWrite(t testing.TB, want Write) {
+	t.Helper()
+	writes := d.Writes()
+	for _, w := range writes {
+		if w.Table == want.Table && w.Address == want.Address && slices.Equal(w.Values, want.Values) {
+			return
+		}
+	}
+	t.Errorf("modbustest: expected write %v, got %v", want, writes)
+}
+
+// ExpectNoWrites 断言设备没有收到任何写请求
+func (d *Device) ExpectNoWrites(t testing.TB) {
+	t.Helper()
+	if writes := d.Writes(); len(writes) > 0 {
+		t.Errorf("modbustest: expected no writes, got %v", writes)
+	}
+}
+
+// ExpectRequests 断言设备收到的请求数量
+func (d *Device) ExpectRequests(t testing.TB, n int) {
+	t.Helper()
+	if got := len(d.Requests()); got != n {
+		t.Errorf("modbustest: expected %d requests, got %d", n, got)
+	}
+}
+
+// ExpectValues 断言数据模型中从 address 开始的值
+func (d *Device) ExpectValues(t testing.TB, table modbus.Table, address uint16, values ...uint16) {
+	t.Helper()
+	got, err := d.Model().Read(table, address, len(values))
+	if err != nil {
+		t.Errorf("modbustest: read %v %d: %v", table, address, err)
+		return
+	}
+	if !slices.Equal(got, values) {
+		t.Errorf("modbustest: %v %d = %v, want %v", table, address, got, values)
+	}
+}
@@ -0,0 +1,137 @@
+package modbustest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+func TestDeviceReadWrite(t *testing.T) {
+	dev := NewDevice(1)
+	defer dev.Close()
+	client := dev.Client()
+
+	if err := dev.Model().Write(modbus.TableInputRegisters, 100, 7, 8, 9); err != nil {
+		t.Fatal(err)
+	}
+	values, err := client.ReadInputRegisters(100, 3)
+	if err != nil || !slices.Equal(values, []uint16{7, 8, 9}) {
+		t.Fatalf("ReadInputRegisters = %v, %v", values, err)
+	}
+
+	if err := client.WriteMultipleRegisters(10, []uint16{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WriteSingleRegister(20, 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WriteMultipleCoils(3, []bool{true, false, true}); err != nil {
+		t.Fatal(err)
+	}
+	dev.ExpectWrite(t, 10, 1, 2)
+	dev.ExpectWrite(t, 20, 42)
+	dev.ExpectCoilWrite(t, 3, true, false, true)
+	dev.ExpectValues(t, modbus.TableHoldingRegisters, 10, 1, 2)
+	dev.ExpectRequests(t, 4)
+
+	registers, err := client.ReadHoldingRegisters(10, 2)
+	if err != nil || !slices.Equal(registers, []uint16{1, 2}) {
+		t.Fatalf("ReadHoldingRegisters = %v, %v", registers, err)
+	}
+	coils, err := client.ReadCoils(3, 3)
+	if err != nil || !slices.Equal(coils, []bool{true, false, true}) {
+		t.Fatalf("ReadCoils = %v, %v", coils, err)
+	}
+}
+
+func TestDeviceScript(t *testing.T) {
+	dev := NewDevice(1)
+	defer dev.Close()
+	client := dev.Client().SetTimeout(100 * time.Millisecond)
+
+	dev.Script(Exception(modbus.ExcServerDeviceBusy), NoResponse(), Normal())
+
+	err := client.WriteSingleRegister(1, 5)
+	if !errors.Is(err, modbus.ErrServerDeviceBusy) {
+		t.Fatalf("first request: %v, want server device busy", err)
+	}
+	if _, err := client.ReadHoldingRegisters(1, 1); !modbus.IsTimeout(err) {
+		t.Fatalf("second request: %v, want timeout", err)
+	}
+	if err := client.WriteSingleRegister(1, 6); err != nil {
+		t.Fatalf("third request: %v", err)
+	}
+
+	// 被拒绝的写请求也会被记录，但不修改数据模型
+	dev.ExpectWrite(t, 1, 5)
+	dev.ExpectWrite(t, 1, 6)
+	dev.ExpectValues(t, modbus.TableHoldingRegisters, 1, 6)
+}
+
+func TestDeviceIllegalAddress(t *testing.T) {
+	dev := NewDevice(1).SetModel(modbus.NewDataModel(100))
+	defer dev.Close()
+	client := dev.Client()
+
+	if _, err := client.ReadHoldingRegisters(99, 2); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Fatalf("read beyond model: %v, want illegal data address", err)
+	}
+	if _, err := client.Send(1, []byte{modbus.FuncReadHoldingRegisters, 0, 0, 0, 126}); !errors.Is(err, modbus.ErrIllegalDataValue) {
+		t.Fatalf("read 126 registers: %v, want illegal data value", err)
+	}
+	dev.ExpectNoWrites(t)
+}
+
+func TestDeviceIgnoresOtherSlavesAndNoise(t *testing.T) {
+	dev := NewDevice(2)
+	defer dev.Close()
+
+	// 线路噪声和其他从站的请求都不会得到响应
+	dev.Transport().Write([]byte{0x00, 0xFF, 0x13})
+	other := modbus.NewClient(dev.Transport(), 3).SetTimeout(100 * time.Millisecond).SetInterFrameDelay(0)
+	if _, err := other.ReadHoldingRegisters(0, 1); !modbus.IsTimeout(err) {
+		t.Fatalf("request to slave 3: %v, want timeout", err)
+	}
+
+	if _, err := dev.Client().ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("request to slave 2: %v", err)
+	}
+	dev.ExpectRequests(t, 1)
+}
+
+func TestDeviceFaults(t *testing.T) {
+	dev := NewDevice(1)
+	defer dev.Close()
+	dev.Faults.SetScript(modbus.FaultCorrupt)
+
+	client := dev.Client()
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, modbus.ErrCRCMismatch) {
+		t.Fatalf("corrupted response: %v, want CRC mismatch", err)
+	}
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("next request: %v", err)
+	}
+}
+
+func TestPipeReadDeadline(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+
+	a.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := a.Read(make([]byte, 1)); !modbus.IsTimeout(err) {
+		t.Fatalf("Read = %v, want timeout", err)
+	}
+
+	b.Write([]byte{1, 2})
+	b.Close()
+	buf := make([]byte, 4)
+	if n, err := a.Read(buf); n != 2 || err != nil {
+		t.Fatalf("Read = %d, %v", n, err)
+	}
+	if _, err := a.Read(buf); err == nil {
+		t.Fatal("Read after close succeeded")
+	}
+}
@@ -0,0 +1,129 @@
+package modbustest
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/wxlbd/gokit/v2/protocols/modbus"
+)
+
+// pipe.go 实现了模拟串口线路的内存双向字节流
+
+// Conn 是内存管道的一端
+//
+// 与 net.Pipe 不同，写入的数据先进入对端的接收缓冲区，写入从不阻塞，
+// 和串口一样，没有被读取的响应会留在线路上影响下一次交互。
+// Conn 支持 SetReadDeadline，读取超时返回 modbus.ErrTimeout。
+type Conn struct {
+	r, w *buffer
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// Pipe 创建一对相连的内存管道端点，写入一端的数据可以从另一端读取
+func Pipe() (*Conn, *Conn) {
+	a, b := newBuffer(), newBuffer()
+	return &Conn{r: a, w: b}, &Conn{r: b, w: a}
+}
+
+// Read 实现 io.Reader 接口，两端关闭且缓冲区为空后返回 io.EOF
+func (c *Conn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		if n, ok, err := c.r.read(p); ok {
+			return n, err
+		}
+		select {
+		case <-c.r.signal:
+		case <-timeout:
+			return 0, modbus.ErrTimeout
+		}
+	}
+}
+
+// Write 实现 io.Writer 接口，管道关闭后返回 io.ErrClosedPipe
+func (c *Conn) Write(p []byte) (int, error) {
+	return c.w.write(p)
+}
+
+// SetReadDeadline 设置读截止时间，零值表示不超时
+func (c *Conn) SetReadDeadline(deadline time.Time) error {
+	c.mu.Lock()
+	c.deadline = deadline
+	c.mu.Unlock()
+	return nil
+}
+
+// Close 关闭管道的两个方向
+func (c *Conn) Close() error {
+	c.r.close()
+	c.w.close()
+	return nil
+}
+
+// buffer 是单向的接收缓冲区
+type buffer struct {
+	mu     sync.Mutex
+	data   []byte
+	closed bool
+	signal chan struct{} // 有新数据或关闭时通知读取方
+}
+
+func newBuffer() *buffer {
+	return &buffer{signal: make(chan struct{}, 1)}
+}
+
+// 读取缓冲区中的数据，没有数据且未关闭时 ok 为 false
+func (b *buffer) read(p []byte) (n int, ok bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.data) > 0 {
+		n = copy(p, b.data)
+		b.data = b.data[n:]
+		return n, true, nil
+	}
+	if b.closed {
+		return 0, true, io.EOF
+	}
+	return 0, false, nil
+}
+
+func (b *buffer) write(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	b.data = append(b.data, p...)
+	b.mu.Unlock()
+
+	b.notify()
+	return len(p), nil
+}
+
+func (b *buffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.notify()
+}
+
+func (b *buffer) notify() {
+	select {
+	case b.signal <- struct{}{}:
+	default:
+	}
+}
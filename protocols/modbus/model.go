@@ -0,0 +1,146 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// model.go 实现了一个内存中的 Modbus 数据模型，可以直接作为服务器的 Handler 使用
+
+// DataModel 是内存中的 Modbus 数据模型，包含线圈、离散输入、保持寄存器和输入寄存器四张数据表
+//
+// DataModel 实现了 Handler 接口，支持功能码 0x01-0x06、0x0F 和 0x10，
+// 按规范检查数量和地址范围并返回对应的异常。所有单元 ID 共享同一份数据。
+// DataModel 可以被并发使用。
+type DataModel struct {
+	mu     sync.RWMutex
+	tables [4][]uint16 // 按 Table 顺序存放，线圈和离散输入的值为 0 或 1
+}
+
+// NewDataModel 创建数据模型，每张数据表有 size 个地址（0 到 size-1），size 最大为 65536
+func NewDataModel(size int) *DataModel {
+	size = min(max(size, 0), 65536)
+	m := &DataModel{}
+	for i := range m.tables {
+		m.tables[i] = make([]uint16, size)
+	}
+	return m
+}
+
+// 返回数据表的存储，未知的数据表返回 nil
+func (m *DataModel) table(table Table) []uint16 {
+	if table < TableCoils || table > TableInputRegisters {
+		return nil
+	}
+	return m.tables[table-1]
+}
+
+// Read 读取数据表中从 address 开始的 quantity 个值，超出范围时返回 ErrIllegalDataAddress
+func (m *DataModel) Read(table Table, address uint16, quantity int) ([]uint16, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	values := m.table(table)
+	if quantity < 0 || int(address)+quantity > len(values) {
+		return nil, fmt.Errorf("%w: %v %d+%d", ErrIllegalDataAddress, table, address, quantity)
+	}
+	return append([]uint16(nil), values[int(address):int(address)+quantity]...), nil
+}
+
+// Write 写入数据表中从 address 开始的值，线圈和离散输入中非 0 的值被保存为 1
+// 超出范围时返回 ErrIllegalDataAddress
+func (m *DataModel) Write(table Table, address uint16, values ...uint16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.table(table)
+	if int(address)+len(values) > len(stored) {
+		return fmt.Errorf("%w: %v %d+%d", ErrIllegalDataAddress, table, address, len(values))
+	}
+	for i, v := range values {
+		if table.bits() && v != 0 {
+			v = 1
+		}
+		stored[int(address)+i] = v
+	}
+	return nil
+}
+
+// 各功能码允许的最大数量
+var maxQuantity = map[byte]int{
+	FuncReadCoils:              2000,
+	FuncReadDiscreteInputs:     2000,
+	FuncReadHoldingRegisters:   125,
+	FuncReadInputRegisters:     125,
+	FuncWriteMultipleCoils:     1968,
+	FuncWriteMultipleRegisters: 123,
+}
+
+// ServeModbus 实现 Handler 接口
+func (m *DataModel) ServeModbus(req *Request) ([]byte, error) {
+	table, write := FunctionTable(req.FunctionCode)
+	if table == 0 {
+		return nil, ErrIllegalFunction
+	}
+	address, quantity, ok := req.AddressRange()
+	if !ok {
+		return nil, ErrIllegalDataValue
+	}
+
+	if !write {
+		if len(req.Data) != 4 || quantity < 1 || int(quantity) > maxQuantity[req.FunctionCode] {
+			return nil, ErrIllegalDataValue
+		}
+		values, err := m.Read(table, address, int(quantity))
+		if err != nil {
+			return nil, err
+		}
+		return encodeValues(table, values), nil
+	}
+
+	values, ok := RequestValues(req.FunctionCode, req.Data)
+	if !ok {
+		return nil, ErrIllegalDataValue
+	}
+	if limit, limited := maxQuantity[req.FunctionCode]; limited && (quantity < 1 || int(quantity) > limit) {
+		return nil, ErrIllegalDataValue
+	}
+	if err := m.Write(table, address, values...); err != nil {
+		return nil, err
+	}
+	// 写操作的响应回显请求的前 4 个字节（地址 + 数量或值）
+	return req.Data[:4], nil
+}
+
+// RequestValues 从写请求 PDU 的数据部分（功能码之后）解析要写入的值
+// 线圈的值为 0 或 1。不是写请求或者数据格式不正确时返回 ok 为 false
+func RequestValues(functionCode byte, data []byte) (values []uint16, ok bool) {
+	switch functionCode {
+	case FuncWriteSingleCoil:
+		if len(data) != 4 {
+			return nil, false
+		}
+		switch binary.BigEndian.Uint16(data[2:4]) {
+		case 0xFF00:
+			return []uint16{1}, true
+		case 0x0000:
+			return []uint16{0}, true
+		default:
+			return nil, false
+		}
+	case FuncWriteSingleRegister:
+		if len(data) != 4 {
+			return nil, false
+		}
+		return []uint16{binary.BigEndian.Uint16(data[2:4])}, true
+	case FuncWriteMultipleCoils, FuncWriteMultipleRegisters:
+		if len(data) < 5 {
+			return nil, false
+		}
+		table, _ := FunctionTable(functionCode)
+		return decodeValues(table, int(binary.BigEndian.Uint16(data[2:4])), data[4:])
+	default:
+		return nil, false
+	}
+}
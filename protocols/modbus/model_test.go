@@ -0,0 +1,85 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDataModelServeModbus(t *testing.T) {
+	m := NewDataModel(100)
+	m.Write(TableDiscreteInputs, 0, 1, 0, 1, 1, 0, 0, 0, 0, 1)
+
+	tests := []struct {
+		name string
+		fc   byte
+		data []byte
+		want []byte
+		err  error
+	}{
+		{"read discrete inputs", FuncReadDiscreteInputs, []byte{0, 0, 0, 9}, []byte{2, 0x0D, 0x01}, nil},
+		{"write single coil", FuncWriteSingleCoil, []byte{0, 5, 0xFF, 0}, []byte{0, 5, 0xFF, 0}, nil},
+		{"invalid coil value", FuncWriteSingleCoil, []byte{0, 5, 0x12, 0x34}, nil, ErrIllegalDataValue},
+		{"write multiple registers", FuncWriteMultipleRegisters, []byte{0, 98, 0, 2, 4, 0, 1, 0, 2}, []byte{0, 98, 0, 2}, nil},
+		{"byte count mismatch", FuncWriteMultipleRegisters, []byte{0, 0, 0, 2, 3, 0, 1, 0}, nil, ErrIllegalDataValue},
+		{"write beyond model", FuncWriteMultipleRegisters, []byte{0, 99, 0, 2, 4, 0, 1, 0, 2}, nil, ErrIllegalDataAddress},
+		{"zero quantity", FuncReadCoils, []byte{0, 0, 0, 0}, nil, ErrIllegalDataValue},
+		{"too many coils", FuncReadCoils, []byte{0, 0, 0x07, 0xD1}, nil, ErrIllegalDataValue},
+		{"unsupported function", FuncDiagnostic, []byte{0, 0, 0, 0}, nil, ErrIllegalFunction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.ServeModbus(&Request{UnitID: 1, FunctionCode: tt.fc, Data: tt.data})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Fatalf("ServeModbus = % X, %v, want % X", got, err, tt.want)
+			}
+		})
+	}
+
+	if coils, _ := m.Read(TableCoils, 5, 1); !slices.Equal(coils, []uint16{1}) {
+		t.Errorf("coil 5 = %v, want [1]", coils)
+	}
+	if registers, _ := m.Read(TableHoldingRegisters, 98, 2); !slices.Equal(registers, []uint16{1, 2}) {
+		t.Errorf("registers 98-99 = %v, want [1 2]", registers)
+	}
+}
+
+func TestRTUServerResync(t *testing.T) {
+	m := NewDataModel(10)
+	m.Write(TableHoldingRegisters, 0, 0x1234)
+	server := NewRTUServer(m, 1)
+
+	// 噪声 + 其他从站的请求 + CRC 错误的请求 + 广播写 + 正常请求
+	var in bytes.Buffer
+	in.Write([]byte{0x00, 0xFF, 0x13})
+	in.Write(NewReadHoldingRegistersRequest(2, 0, 1))
+	bad := NewReadHoldingRegistersRequest(1, 0, 1)
+	bad[len(bad)-1] ^= 0xFF
+	in.Write(bad)
+	in.Write(NewWriteSingleRegisterRequest(0, 1, 7))
+	in.Write(NewReadHoldingRegistersRequest(1, 0, 2))
+
+	var out bytes.Buffer
+	if err := server.Serve(readWriter{&in, &out}); err != nil {
+		t.Fatal(err)
+	}
+	want := frame(0x01, 0x03, 0x04, 0x12, 0x34, 0x00, 0x07)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("responses = % X, want % X", out.Bytes(), want)
+	}
+}
+
+type readWriter struct {
+	r *bytes.Buffer
+	w *bytes.Buffer
+}
+
+func (rw readWriter) Read(p []byte) (int, error)  { return rw.r.Read(p) }
+func (rw readWriter) Write(p []byte) (int, error) { return rw.w.Write(p) }
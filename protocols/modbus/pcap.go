@@ -0,0 +1,339 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// pcap.go 实现了把 Modbus 通信导出为 pcapng 文件，可以直接用 Wireshark 的 Modbus/TCP 解析器打开
+
+// pcapng 块类型以及合成报文头使用的常量
+const (
+	pcapngSectionHeader     = 0x0A0D0D0A
+	pcapngInterfaceDesc     = 0x00000001
+	pcapngEnhancedPacket    = 0x00000006
+	pcapngByteOrderMagic    = 0x1A2B3C4D
+	pcapLinkTypeEthernet    = 1
+	pcapLinkTypeUser0       = 147
+	pcapInterfaceMBAP       = 0
+	pcapInterfaceRTU        = 1
+	pcapOptionEnd           = 0
+	pcapOptionIfName        = 2
+	pcapRTUInterfaceName    = "modbus-rtu"
+	pcapSnapLen             = 65535
+	pcapDefaultModbusPort   = 502
+	pcapDefaultClientPort   = 49152
+	pcapEthernetHeaderLen   = 14
+	pcapIPv4HeaderLen       = 20
+	pcapTCPHeaderLen        = 20
+	pcapTCPFlagsPushAck     = 0x18
+	pcapIPv4ProtocolTCP     = 6
+	pcapEtherTypeIPv4       = 0x0800
+	pcapIPv4DontFragment    = 0x4000
+	pcapIPv4DefaultTTL      = 64
+	pcapTCPDefaultWindow    = 0xFFFF
+	pcapEthernetAddrClient  = "\x02\x00\x00\x00\x00\x01"
+	pcapEthernetAddrServer  = "\x02\x00\x00\x00\x00\x02"
+	pcapTimestampResolution = time.Microsecond
+)
+
+// 合成报文使用的默认地址：RTU 总线上的主站和从站分别表示为 10.0.0.1 和 10.0.0.2
+var (
+	pcapClientAddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{10, 0, 0, 1}), pcapDefaultClientPort)
+	pcapServerAddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{10, 0, 0, 2}), pcapDefaultModbusPort)
+)
+
+// PcapWriter 把 Modbus 通信写入 pcapng 文件
+//
+// 每个 ADU 被包装在合成的以太网/IPv4/TCP 报文中（服务器端口 502），TCP 序列号按连接连续递增，
+// Wireshark 打开后直接使用 Modbus/TCP 解析器。Wireshark 没有无需配置即可解析 Modbus RTU 的链路类型，
+// 因此 RTU 帧默认被转换为 MBAP 格式：单元 ID 为从站 ID，事务 ID 按请求依次分配，CRC 被去掉。
+//
+// CRC 错误或过短的帧无法转换，原样（包含 CRC）写入链路类型为 USER0（147）的第二个接口 modbus-rtu；
+// 调用 SetRawRTU(true) 后所有 RTU 帧都这样写入。在 Wireshark 的 DLT_USER 设置中把 User 0 (DLT=147)
+// 的载荷协议设为 mbrtu 即可解析这些帧。
+//
+// PcapWriter 可以作为客户端钩子（AddHook）、服务器 Handler 的包装（Handler）使用，
+// 也可以导出 Sniffer 的交互（WriteExchange）和录制文件（WriteCapture）。PcapWriter 可以被并发使用。
+type PcapWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	err    error
+	flows  map[pcapFlow]*pcapFlowState
+	nextID uint16
+	rawRTU bool // 所有 RTU 帧原样写入 RTU 接口
+	rtuIDB bool // 已写入 RTU 接口的描述块
+}
+
+// TCP 连接的两端
+type pcapFlow struct {
+	client, server netip.AddrPort
+}
+
+// TCP 连接两个方向的下一个序列号
+type pcapFlowState struct {
+	clientSeq, serverSeq uint32
+	ipID                 uint16
+}
+
+// NewPcapWriter 创建 pcapng 写入器，并写入节头和接口描述块
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	p := &PcapWriter{w: w, flows: make(map[pcapFlow]*pcapFlowState)}
+
+	// 节头块：字节序标识、版本 1.0、节长度未知
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:4], pcapngByteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:6], 1)
+	binary.LittleEndian.PutUint16(shb[6:8], 0)
+	binary.LittleEndian.PutUint64(shb[8:16], 0xFFFFFFFFFFFFFFFF)
+	p.writeBlock(pcapngSectionHeader, shb)
+
+	// 接口描述块：以太网，默认时间戳精度为微秒
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:2], pcapLinkTypeEthernet)
+	binary.LittleEndian.PutUint32(idb[4:8], pcapSnapLen)
+	p.writeBlock(pcapngInterfaceDesc, idb)
+
+	return p, p.err
+}
+
+// SetRawRTU 设置是否把 RTU 帧原样写入 USER0 链路类型的接口，而不是转换为 Modbus/TCP 报文
+// 不影响 Handler 写入的服务器通信
+func (p *PcapWriter) SetRawRTU(enable bool) *PcapWriter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rawRTU = enable
+	return p
+}
+
+// Err 返回写入时遇到的第一个错误
+func (p *PcapWriter) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// BeforeTransaction 实现 Hook 接口
+func (p *PcapWriter) BeforeTransaction(tx *Transaction) {}
+
+// AfterTransaction 实现 Hook 接口，把客户端的请求和响应帧写入文件
+func (p *PcapWriter) AfterTransaction(tx *Transaction) {
+	p.writeRTUExchange(tx.Start, tx.Request, tx.Start.Add(tx.Latency), tx.Response)
+}
+
+// WriteExchange 写入 Sniffer 解析出的一次交互
+func (p *PcapWriter) WriteExchange(ex *Exchange) error {
+	var reqTime, respTime time.Time
+	var request, response []byte
+	if ex.Request != nil {
+		reqTime, request = ex.Request.Time, ex.Request.Raw
+	}
+	if ex.Response != nil {
+		respTime, response = ex.Response.Time, ex.Response.Raw
+	}
+	p.writeRTUExchange(reqTime, request, respTime, response)
+	return p.Err()
+}
+
+// WriteCapture 写入录制文件中的 RTU 通信，帧的划分和配对由 Sniffer 完成
+func (p *PcapWriter) WriteCapture(records []CaptureRecord) error {
+	sniffer := NewSniffer(func(ex *Exchange) { p.WriteExchange(ex) })
+	for _, record := range records {
+		sniffer.Feed(record.Data, record.Time)
+	}
+	sniffer.Flush()
+	return p.Err()
+}
+
+// Handler 包装服务器的 Handler，把服务器收到的请求和发送的响应写入文件
+// 客户端地址取自 Request.RemoteAddr
+func (p *PcapWriter) Handler(next Handler) Handler {
+	return HandlerFunc(func(req *Request) ([]byte, error) {
+		client := pcapClientAddr
+		if addr, ok := req.RemoteAddr.(*net.TCPAddr); ok {
+			if ap := addr.AddrPort(); ap.Addr().Unmap().Is4() {
+				client = netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+			}
+		}
+		flow := pcapFlow{client: client, server: pcapServerAddr}
+
+		start := time.Now()
+		pdu := handleRequest(next, nil, req)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		id := p.transactionID()
+		requestPDU := append([]byte{req.FunctionCode}, req.Data...)
+		p.writeADU(start, flow, true, &mbapFrame{TransactionID: id, UnitID: req.UnitID, PDU: requestPDU})
+		if pdu == nil {
+			return nil, ErrNoResponse
+		}
+		p.writeADU(time.Now(), flow, false, &mbapFrame{TransactionID: id, UnitID: req.UnitID, PDU: pdu})
+		if IsError(pdu[0]) {
+			return nil, ParseError(pdu[0], pdu[1])
+		}
+		return pdu[1:], nil
+	})
+}
+
+// 把 RTU 请求帧和响应帧转换为 MBAP 格式写入文件，无法转换的帧原样写入 RTU 接口
+func (p *PcapWriter) writeRTUExchange(reqTime time.Time, request []byte, respTime time.Time, response []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	flow := pcapFlow{client: pcapClientAddr, server: pcapServerAddr}
+	id := p.transactionID()
+	for i, frame := range [][]byte{request, response} {
+		if len(frame) == 0 {
+			continue
+		}
+		at := reqTime
+		if i == 1 {
+			at = respTime
+		}
+		if p.rawRTU || len(frame) < 4 || !CheckCRC16(frame) {
+			p.writeRTUFrame(at, frame)
+			continue
+		}
+		p.writeADU(at, flow, i == 0, &mbapFrame{TransactionID: id, UnitID: frame[0], PDU: frame[1 : len(frame)-2]})
+	}
+}
+
+// 把 RTU 帧原样写入 RTU 接口，第一次写入前写入接口描述块，调用时需持有 p.mu
+func (p *PcapWriter) writeRTUFrame(at time.Time, frame []byte) {
+	if !p.rtuIDB {
+		// 接口描述块：USER0，带接口名选项
+		idb := make([]byte, 8, 16+len(pcapRTUInterfaceName))
+		binary.LittleEndian.PutUint16(idb[0:2], pcapLinkTypeUser0)
+		binary.LittleEndian.PutUint32(idb[4:8], pcapSnapLen)
+		idb = binary.LittleEndian.AppendUint16(idb, pcapOptionIfName)
+		idb = binary.LittleEndian.AppendUint16(idb, uint16(len(pcapRTUInterfaceName)))
+		idb = append(idb, pcapRTUInterfaceName...)
+		idb = append(idb, make([]byte, (4-len(pcapRTUInterfaceName)%4)%4)...)
+		idb = binary.LittleEndian.AppendUint32(idb, pcapOptionEnd)
+		p.writeBlock(pcapngInterfaceDesc, idb)
+		p.rtuIDB = true
+	}
+	p.writePacket(at, pcapInterfaceRTU, frame)
+}
+
+// 分配事务 ID，调用时需持有 p.mu
+func (p *PcapWriter) transactionID() uint16 {
+	p.nextID++
+	return p.nextID
+}
+
+// 把 ADU 包装为以太网/IPv4/TCP 报文写入文件，调用时需持有 p.mu
+func (p *PcapWriter) writeADU(at time.Time, flow pcapFlow, fromClient bool, frame *mbapFrame) {
+	state := p.flows[flow]
+	if state == nil {
+		state = &pcapFlowState{clientSeq: 1, serverSeq: 1}
+		p.flows[flow] = state
+	}
+
+	payload := frame.encode()
+	src, dst := flow.client, flow.server
+	seq, ack := &state.clientSeq, state.serverSeq
+	srcMAC, dstMAC := pcapEthernetAddrClient, pcapEthernetAddrServer
+	if !fromClient {
+		src, dst = dst, src
+		seq, ack = &state.serverSeq, state.clientSeq
+		srcMAC, dstMAC = dstMAC, srcMAC
+	}
+	state.ipID++
+
+	packet := make([]byte, 0, pcapEthernetHeaderLen+pcapIPv4HeaderLen+pcapTCPHeaderLen+len(payload))
+
+	// 以太网头
+	packet = append(packet, dstMAC...)
+	packet = append(packet, srcMAC...)
+	packet = binary.BigEndian.AppendUint16(packet, pcapEtherTypeIPv4)
+
+	// IPv4 头
+	ip := len(packet)
+	packet = append(packet, 0x45, 0x00)
+	packet = binary.BigEndian.AppendUint16(packet, uint16(pcapIPv4HeaderLen+pcapTCPHeaderLen+len(payload)))
+	packet = binary.BigEndian.AppendUint16(packet, state.ipID)
+	packet = binary.BigEndian.AppendUint16(packet, pcapIPv4DontFragment)
+	packet = append(packet, pcapIPv4DefaultTTL, pcapIPv4ProtocolTCP, 0x00, 0x00)
+	srcIP, dstIP := src.Addr().As4(), dst.Addr().As4()
+	packet = append(packet, srcIP[:]...)
+	packet = append(packet, dstIP[:]...)
+	binary.BigEndian.PutUint16(packet[ip+10:], internetChecksum(0, packet[ip:]))
+
+	// TCP 头
+	tcp := len(packet)
+	packet = binary.BigEndian.AppendUint16(packet, src.Port())
+	packet = binary.BigEndian.AppendUint16(packet, dst.Port())
+	packet = binary.BigEndian.AppendUint32(packet, *seq)
+	packet = binary.BigEndian.AppendUint32(packet, ack)
+	packet = append(packet, pcapTCPHeaderLen/4<<4, pcapTCPFlagsPushAck)
+	packet = binary.BigEndian.AppendUint16(packet, pcapTCPDefaultWindow)
+	packet = append(packet, 0x00, 0x00, 0x00, 0x00) // 校验和、紧急指针
+	packet = append(packet, payload...)
+
+	// TCP 校验和包含伪首部
+	pseudo := make([]byte, 0, 12)
+	pseudo = append(pseudo, srcIP[:]...)
+	pseudo = append(pseudo, dstIP[:]...)
+	pseudo = append(pseudo, 0x00, pcapIPv4ProtocolTCP)
+	pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(packet)-tcp))
+	binary.BigEndian.PutUint16(packet[tcp+16:], internetChecksum(sum16(0, pseudo), packet[tcp:]))
+
+	*seq += uint32(len(payload))
+	p.writePacket(at, pcapInterfaceMBAP, packet)
+}
+
+// 写入增强分组块，调用时需持有 p.mu
+func (p *PcapWriter) writePacket(at time.Time, iface uint32, packet []byte) {
+	ts := uint64(at.UnixNano() / int64(pcapTimestampResolution))
+	body := make([]byte, 20, 20+len(packet)+3)
+	binary.LittleEndian.PutUint32(body[0:4], iface)
+	binary.LittleEndian.PutUint32(body[4:8], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(packet)))
+	body = append(body, packet...)
+	p.writeBlock(pcapngEnhancedPacket, body)
+}
+
+// 写入一个 pcapng 块，块体按 4 字节对齐
+func (p *PcapWriter) writeBlock(blockType uint32, body []byte) {
+	if p.err != nil {
+		return
+	}
+	padded := (len(body) + 3) &^ 3
+	length := uint32(12 + padded)
+
+	block := make([]byte, 0, length)
+	block = binary.LittleEndian.AppendUint32(block, blockType)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	block = append(block, body...)
+	block = append(block, make([]byte, padded-len(body))...)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	_, p.err = p.w.Write(block)
+}
+
+// 累加 16 位反码和
+func sum16(sum uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
+
+// 计算 IP/TCP 校验和，sum 为已累加的伪首部
+func internetChecksum(sum uint32, b []byte) uint16 {
+	sum = sum16(sum, b)
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return ^uint16(sum)
+}
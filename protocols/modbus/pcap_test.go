@@ -0,0 +1,196 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 从 pcapng 数据中取出所有增强分组块中的报文及其接口 ID，以及各接口的链路类型
+func readPcapPackets(t *testing.T, data []byte) (packets [][]byte, times []time.Time, ifaces []uint32) {
+	t.Helper()
+	var linkTypes []uint16
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("truncated block")
+		}
+		blockType := binary.LittleEndian.Uint32(data[0:4])
+		length := binary.LittleEndian.Uint32(data[4:8])
+		if length%4 != 0 || int(length) > len(data) || binary.LittleEndian.Uint32(data[length-4:]) != length {
+			t.Fatalf("invalid block length %d", length)
+		}
+		if blockType == pcapngInterfaceDesc {
+			linkTypes = append(linkTypes, binary.LittleEndian.Uint16(data[8:10]))
+		}
+		if blockType == pcapngEnhancedPacket {
+			iface := binary.LittleEndian.Uint32(data[8:12])
+			if int(iface) >= len(linkTypes) {
+				t.Fatalf("packet on undeclared interface %d", iface)
+			}
+			ifaces = append(ifaces, iface)
+			ts := uint64(binary.LittleEndian.Uint32(data[12:16]))<<32 | uint64(binary.LittleEndian.Uint32(data[16:20]))
+			n := binary.LittleEndian.Uint32(data[20:24])
+			packets = append(packets, data[28:28+n])
+			times = append(times, time.UnixMicro(int64(ts)))
+		}
+		data = data[length:]
+	}
+	return packets, times, ifaces
+}
+
+// 检查合成报文的校验和并取出 TCP 序列号和 MBAP 帧
+func parsePcapPacket(t *testing.T, packet []byte) (srcPort uint16, seq uint32, frame *mbapFrame) {
+	t.Helper()
+	ip := packet[pcapEthernetHeaderLen:]
+	if internetChecksum(0, ip[:pcapIPv4HeaderLen]) != 0 {
+		t.Error("invalid IPv4 checksum")
+	}
+	tcp := ip[pcapIPv4HeaderLen:]
+	pseudo := append(append([]byte{}, ip[12:20]...), 0x00, pcapIPv4ProtocolTCP)
+	pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(tcp)))
+	if internetChecksum(sum16(0, pseudo), tcp) != 0 {
+		t.Error("invalid TCP checksum")
+	}
+	frame, err := decodeMBAP(tcp[pcapTCPHeaderLen:])
+	if err != nil {
+		t.Fatalf("decodeMBAP() error = %v", err)
+	}
+	return binary.BigEndian.Uint16(tcp[0:2]), binary.BigEndian.Uint32(tcp[4:8]), frame
+}
+
+func TestPcapWriterHook(t *testing.T) {
+	var buf bytes.Buffer
+	pcap, err := NewPcapWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &scriptedTransport{responses: [][]byte{
+		frame(0x01, 0x03, 0x02, 0x00, 0x2A),
+		corruptFrame(0x01, 0x03, 0x02, 0x00, 0x2A),
+	}}
+	client := NewClient(transport, 1).SetInterFrameDelay(0).AddHook(pcap)
+	client.ReadHoldingRegisters(0, 1)
+	client.ReadHoldingRegisters(1, 1) // 响应 CRC 错误，原样写入 RTU 接口
+	if err := pcap.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	packets, _, ifaces := readPcapPackets(t, buf.Bytes())
+	if len(packets) != 4 {
+		t.Fatalf("got %d packets, want 4", len(packets))
+	}
+	if want := []uint32{0, 0, 0, 1}; !slices.Equal(ifaces, want) {
+		t.Errorf("interfaces = %v, want %v", ifaces, want)
+	}
+	if want := corruptFrame(0x01, 0x03, 0x02, 0x00, 0x2A); !bytes.Equal(packets[3], want) {
+		t.Errorf("corrupted frame = % x, want % x", packets[3], want)
+	}
+
+	wantSeq := map[uint16]uint32{pcapDefaultClientPort: 1, pcapDefaultModbusPort: 1}
+	for i, packet := range packets[:3] {
+		port, seq, frame := parsePcapPacket(t, packet)
+		if seq != wantSeq[port] {
+			t.Errorf("packet %d seq = %d, want %d", i, seq, wantSeq[port])
+		}
+		wantSeq[port] += uint32(len(frame.encode()))
+		if frame.UnitID != 1 || frame.PDU[0] != FuncReadHoldingRegisters {
+			t.Errorf("packet %d = %+v", i, frame)
+		}
+	}
+	_, _, request := parsePcapPacket(t, packets[0])
+	_, _, response := parsePcapPacket(t, packets[1])
+	if request.TransactionID != response.TransactionID {
+		t.Errorf("transaction IDs %d and %d differ", request.TransactionID, response.TransactionID)
+	}
+	if string(response.PDU) != "\x03\x02\x00\x2a" {
+		t.Errorf("response PDU = % x", response.PDU)
+	}
+}
+
+func TestPcapWriterCapture(t *testing.T) {
+	var buf bytes.Buffer
+	pcap, _ := NewPcapWriter(&buf)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	response := frame(0x01, 0x03, 0x02, 0x00, 0x07)
+	records := []CaptureRecord{
+		{Time: start, Dir: CaptureTx, Data: NewReadHoldingRegistersRequest(1, 0, 1)},
+		{Time: start.Add(10 * time.Millisecond), Dir: CaptureRx, Data: response[:3]}, // 响应分两次读取
+		{Time: start.Add(11 * time.Millisecond), Dir: CaptureRx, Data: response[3:]},
+	}
+	if err := pcap.WriteCapture(records); err != nil {
+		t.Fatal(err)
+	}
+
+	packets, times, _ := readPcapPackets(t, buf.Bytes())
+	if len(packets) != 2 {
+		t.Fatalf("got %d packets, want 2", len(packets))
+	}
+	if !times[0].Equal(start) {
+		t.Errorf("request time = %v, want %v", times[0], start)
+	}
+	if port, _, _ := parsePcapPacket(t, packets[1]); port != pcapDefaultModbusPort {
+		t.Errorf("response source port = %d, want 502", port)
+	}
+}
+
+func TestPcapWriterHandler(t *testing.T) {
+	var buf bytes.Buffer
+	pcap, _ := NewPcapWriter(&buf)
+	addr := startTCPServer(t, NewTCPServer(pcap.Handler(echoAddressHandler)))
+
+	transport := NewTCPTransport(addr)
+	defer transport.Close()
+	client := NewTCPClient(transport, 1)
+	client.ReadHoldingRegisters(5, 1)
+	client.ReadHoldingRegisters(5000, 1)
+
+	packets, _, _ := readPcapPackets(t, buf.Bytes())
+	if len(packets) != 4 {
+		t.Fatalf("got %d packets, want 4", len(packets))
+	}
+	_, _, exception := parsePcapPacket(t, packets[3])
+	if string(exception.PDU) != "\x83\x02" {
+		t.Errorf("exception PDU = % x, want 83 02", exception.PDU)
+	}
+
+	// 包装后的 Handler 返回的异常使用请求的功能码
+	_, err := pcap.Handler(echoAddressHandler).ServeModbus(readRequest(1, FuncReadHoldingRegisters, 5000, 1))
+	want := &ModbusError{FunctionCode: FuncReadHoldingRegisters, ExceptionCode: ExcIllegalDataAddress}
+	if !errors.Is(err, want) {
+		t.Errorf("Handler error = %v, want %v", err, want)
+	}
+}
+
+func TestPcapWriterRawRTU(t *testing.T) {
+	var buf bytes.Buffer
+	pcap, _ := NewPcapWriter(&buf)
+	pcap.SetRawRTU(true)
+
+	request := NewReadHoldingRegistersRequest(1, 0, 1)
+	response := frame(0x01, 0x03, 0x02, 0x00, 0x07)
+	transport := &scriptedTransport{responses: [][]byte{response}}
+	NewClient(transport, 1).SetInterFrameDelay(0).AddHook(pcap).ReadHoldingRegisters(0, 1)
+
+	data := buf.Bytes()
+	packets, _, ifaces := readPcapPackets(t, data)
+	if len(packets) != 2 || !bytes.Equal(packets[0], request) || !bytes.Equal(packets[1], response) {
+		t.Fatalf("packets = % x, want raw request and response", packets)
+	}
+	if !slices.Equal(ifaces, []uint32{pcapInterfaceRTU, pcapInterfaceRTU}) {
+		t.Errorf("interfaces = %v", ifaces)
+	}
+
+	// 第二个接口描述块紧跟在第一个之后，链路类型为 USER0，带接口名
+	idb := data[28+20:]
+	if binary.LittleEndian.Uint32(idb[0:4]) != pcapngInterfaceDesc || binary.LittleEndian.Uint16(idb[8:10]) != pcapLinkTypeUser0 {
+		t.Fatalf("second block = % x, want USER0 interface", idb[:16])
+	}
+	if !bytes.Contains(idb[:binary.LittleEndian.Uint32(idb[4:8])], []byte(pcapRTUInterfaceName)) {
+		t.Error("RTU interface has no name")
+	}
+}
@@ -0,0 +1,120 @@
+package modbus
+
+import "encoding/binary"
+
+// pdu.go 提供了从请求 PDU 中解析地址范围等通用字段的辅助函数
+
+// Table 表示 Modbus 数据模型中的数据表
+type Table int
+
+const (
+	TableCoils            Table = iota + 1 // 线圈
+	TableDiscreteInputs                    // 离散输入
+	TableHoldingRegisters                  // 保持寄存器
+	TableInputRegisters                    // 输入寄存器
+)
+
+// String 返回数据表名称
+func (t Table) String() string {
+	switch t {
+	case TableCoils:
+		return "coils"
+	case TableDiscreteInputs:
+		return "discrete inputs"
+	case TableHoldingRegisters:
+		return "holding registers"
+	case TableInputRegisters:
+		return "input registers"
+	default:
+		return "unknown table"
+	}
+}
+
+// FunctionTable 返回功能码访问的数据表，以及是否为写操作
+// 不访问数据表的功能码返回 0
+func FunctionTable(functionCode byte) (table Table, write bool) {
+	switch functionCode {
+	case FuncReadCoils:
+		return TableCoils, false
+	case FuncWriteSingleCoil, FuncWriteMultipleCoils:
+		return TableCoils, true
+	case FuncReadDiscreteInputs:
+		return TableDiscreteInputs, false
+	case FuncReadHoldingRegisters:
+		return TableHoldingRegisters, false
+	case FuncWriteSingleRegister, FuncWriteMultipleRegisters:
+		return TableHoldingRegisters, true
+	case FuncReadInputRegisters:
+		return TableInputRegisters, false
+	default:
+		return 0, false
+	}
+}
+
+// RequestAddressRange 从请求 PDU 的数据部分（功能码之后）解析起始地址和数量
+// 单个线圈/寄存器的写操作数量为 1，不访问数据表的功能码返回 ok 为 false
+func RequestAddressRange(functionCode byte, data []byte) (address, quantity uint16, ok bool) {
+	switch functionCode {
+	case FuncReadCoils, FuncReadDiscreteInputs, FuncReadHoldingRegisters, FuncReadInputRegisters,
+		FuncWriteMultipleCoils, FuncWriteMultipleRegisters:
+		if len(data) < 4 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint16(data[0:2]), binary.BigEndian.Uint16(data[2:4]), true
+	case FuncWriteSingleCoil, FuncWriteSingleRegister:
+		if len(data) < 4 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint16(data[0:2]), 1, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// 是否为位数据表（线圈、离散输入）
+func (t Table) bits() bool {
+	return t == TableCoils || t == TableDiscreteInputs
+}
+
+// encodeValues 将数据表的值编码为字节数 + 数据，用于读响应和写多个线圈/寄存器请求
+// 线圈和离散输入的值为 0 或 1
+func encodeValues(table Table, values []uint16) []byte {
+	if table.bits() {
+		data := make([]byte, 1+(len(values)+7)/8)
+		data[0] = byte(len(data) - 1)
+		for i, v := range values {
+			if v != 0 {
+				data[1+i/8] |= 1 << (i % 8)
+			}
+		}
+		return data
+	}
+
+	data := make([]byte, 1, 1+2*len(values))
+	data[0] = byte(2 * len(values))
+	for _, v := range values {
+		data = binary.BigEndian.AppendUint16(data, v)
+	}
+	return data
+}
+
+// decodeValues 解析字节数 + 数据形式的 quantity 个值，字节数与数量不符时返回 false
+func decodeValues(table Table, quantity int, data []byte) ([]uint16, bool) {
+	size := 2 * quantity
+	if table.bits() {
+		size = (quantity + 7) / 8
+	}
+	if len(data) != 1+size || int(data[0]) != size {
+		return nil, false
+	}
+
+	values := make([]uint16, quantity)
+	for i := range values {
+		if table.bits() {
+			values[i] = uint16(data[1+i/8]>>(i%8)) & 1
+		} else {
+			values[i] = binary.BigEndian.Uint16(data[1+2*i:])
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,192 @@
+package modbus
+
+import (
+	"sync"
+	"time"
+)
+
+// proxy.go 实现了多个 Modbus 主站共享同一个上游客户端的缓存代理
+
+// Proxy 让多个 TCP 主站共享一个上游客户端（通常是低速的 RTU 总线）
+//
+// Proxy 实现了 Handler 接口，与 TCPServer 一起使用。读请求在缓存有效期内直接由缓存响应，
+// 缓存未命中时，相同的并发读请求只向上游发送一次；写请求直接转发，并使被写入地址的缓存失效。
+// 其他功能码不经过缓存直接转发。上游错误按 Gateway 的规则映射为异常。
+// 过期的缓存在查找时删除，并且每个有效期内最多清理一次全部过期项，
+// 因此扫描整个地址空间的主站不会使缓存无限增长。
+type Proxy struct {
+	upstream *Client
+	ttl      time.Duration
+	now      func() time.Time // 用于测试
+
+	mu         sync.Mutex
+	cache      map[proxyKey]proxyEntry
+	inflight   map[string]*proxyCall // 按请求 PDU 合并的进行中的读请求
+	generation uint64                // 每次写入后递增，写入前发起的读请求不更新缓存
+	lastSweep  time.Time             // 上一次清理过期缓存的时间
+}
+
+// 缓存的一个线圈、离散输入或寄存器
+type proxyKey struct {
+	unitID  byte
+	table   Table
+	address uint16
+}
+
+type proxyEntry struct {
+	value   uint16 // 线圈和离散输入为 0 或 1
+	fetched time.Time
+}
+
+// 进行中的上游读请求
+type proxyCall struct {
+	done     chan struct{}
+	response []byte
+	err      error
+}
+
+// NewProxy 创建缓存代理，ttl 为缓存有效期，0 表示不缓存（仍然合并相同的并发读请求）
+func NewProxy(upstream *Client, ttl time.Duration) *Proxy {
+	return &Proxy{
+		upstream: upstream,
+		ttl:      ttl,
+		now:      time.Now,
+		cache:    make(map[proxyKey]proxyEntry),
+		inflight: make(map[string]*proxyCall),
+	}
+}
+
+// Invalidate 清空缓存
+func (p *Proxy) Invalidate() {
+	p.mu.Lock()
+	p.cache = make(map[proxyKey]proxyEntry)
+	p.generation++
+	p.mu.Unlock()
+}
+
+// ServeModbus 实现 Handler 接口
+func (p *Proxy) ServeModbus(req *Request) ([]byte, error) {
+	pdu := make([]byte, 0, 1+len(req.Data))
+	pdu = append(pdu, req.FunctionCode)
+	pdu = append(pdu, req.Data...)
+
+	table, write := FunctionTable(req.FunctionCode)
+	address, quantity, ok := req.AddressRange()
+	switch {
+	case table == 0 || !ok || write && quantity == 0:
+		return p.forward(req.UnitID, pdu)
+	case write:
+		defer p.invalidate(req.UnitID, table, address, quantity)
+		return p.forward(req.UnitID, pdu)
+	}
+
+	// 与 DataModel 一样拒绝超出规范的读数量，否则缓存组装的响应字节数会溢出
+	if quantity < 1 || int(quantity) > maxQuantity[req.FunctionCode] {
+		return nil, ErrIllegalDataValue
+	}
+	if data, ok := p.cached(req.UnitID, table, address, quantity); ok {
+		return data, nil
+	}
+	return p.read(req.UnitID, table, address, quantity, pdu)
+}
+
+// 直接转发请求，返回响应 PDU 中功能码之后的数据
+func (p *Proxy) forward(unitID byte, pdu []byte) ([]byte, error) {
+	response, err := p.upstream.Send(unitID, pdu)
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+	return response[1:], nil
+}
+
+// 向上游发送读请求，相同的并发请求共享同一个结果
+func (p *Proxy) read(unitID byte, table Table, address, quantity uint16, pdu []byte) ([]byte, error) {
+	key := string(append([]byte{unitID}, pdu...))
+
+	p.mu.Lock()
+	if call, ok := p.inflight[key]; ok {
+		p.mu.Unlock()
+		<-call.done
+		return call.response, call.err
+	}
+	call := &proxyCall{done: make(chan struct{})}
+	p.inflight[key] = call
+	generation := p.generation
+	p.mu.Unlock()
+
+	call.response, call.err = p.forward(unitID, pdu)
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	if call.err == nil && p.ttl > 0 && p.generation == generation {
+		p.store(unitID, table, address, quantity, call.response)
+	}
+	p.mu.Unlock()
+
+	close(call.done)
+	return call.response, call.err
+}
+
+// 从缓存中组装读响应的数据部分，有任意一项缺失或过期时返回 false
+func (p *Proxy) cached(unitID byte, table Table, address, quantity uint16) ([]byte, bool) {
+	if p.ttl <= 0 {
+		return nil, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	values := make([]uint16, quantity)
+	for i := range values {
+		key := proxyKey{unitID, table, address + uint16(i)}
+		entry, ok := p.cache[key]
+		if ok && now.Sub(entry.fetched) >= p.ttl {
+			delete(p.cache, key)
+			ok = false
+		}
+		if !ok {
+			return nil, false
+		}
+		values[i] = entry.value
+	}
+	return encodeValues(table, values), true
+}
+
+// 把读响应的数据部分存入缓存，调用时需持有 p.mu
+func (p *Proxy) store(unitID byte, table Table, address, quantity uint16, data []byte) {
+	values, ok := decodeValues(table, int(quantity), data)
+	if !ok {
+		return
+	}
+
+	now := p.now()
+	p.sweep(now)
+	for i, value := range values {
+		p.cache[proxyKey{unitID, table, address + uint16(i)}] = proxyEntry{value: value, fetched: now}
+	}
+}
+
+// 距上一次清理超过有效期时删除所有过期的缓存，调用时需持有 p.mu
+func (p *Proxy) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < p.ttl {
+		return
+	}
+	p.lastSweep = now
+	for key, entry := range p.cache {
+		if now.Sub(entry.fetched) >= p.ttl {
+			delete(p.cache, key)
+		}
+	}
+}
+
+// 使写入地址范围的缓存失效
+func (p *Proxy) invalidate(unitID byte, table Table, address, quantity uint16) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.generation++
+	for i := 0; i < int(quantity); i++ {
+		delete(p.cache, proxyKey{unitID, table, address + uint16(i)})
+	}
+}
@@ -0,0 +1,208 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 返回 RTU 总线的寄存器和线圈值的测试总线，统计收到的请求数
+type proxyBus struct {
+	rtuBus
+	registers [100]uint16
+	requests  atomic.Int32
+	delay     time.Duration
+}
+
+func newProxyBus(t *testing.T) *proxyBus {
+	b := &proxyBus{}
+	b.t = t
+	b.handle = func(request []byte) []byte {
+		b.requests.Add(1)
+		time.Sleep(b.delay)
+		address := binary.BigEndian.Uint16(request[2:4])
+		switch request[1] {
+		case FuncReadHoldingRegisters:
+			quantity := binary.BigEndian.Uint16(request[4:6])
+			response := []byte{request[0], request[1], byte(2 * quantity)}
+			for _, v := range b.registers[address : address+quantity] {
+				response = binary.BigEndian.AppendUint16(response, v)
+			}
+			return frame(response...)
+		case FuncReadCoils:
+			// 线圈值为地址的奇偶性
+			quantity := binary.BigEndian.Uint16(request[4:6])
+			data := make([]byte, (quantity+7)/8)
+			for i := uint16(0); i < quantity; i++ {
+				if (address+i)%2 == 1 {
+					data[i/8] |= 1 << (i % 8)
+				}
+			}
+			return frame(append([]byte{request[0], request[1], byte(len(data))}, data...)...)
+		case FuncWriteSingleRegister:
+			b.registers[address] = binary.BigEndian.Uint16(request[4:6])
+			return frame(request[:6]...)
+		}
+		return frame(request[0], request[1]|0x80, ExcIllegalFunction)
+	}
+	return b
+}
+
+func readRequest(unitID, functionCode byte, address, quantity uint16) *Request {
+	return &Request{
+		UnitID:       unitID,
+		FunctionCode: functionCode,
+		Data:         binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, address), quantity),
+	}
+}
+
+func TestProxyCache(t *testing.T) {
+	bus := newProxyBus(t)
+	bus.registers[10] = 0x1234
+	proxy := NewProxy(NewClient(bus, 0).SetInterFrameDelay(0), time.Second)
+	now := time.Now()
+	proxy.now = func() time.Time { return now }
+
+	want := []byte{0x04, 0x12, 0x34, 0x00, 0x00}
+	for i := 0; i < 3; i++ {
+		data, err := proxy.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 10, 2))
+		if err != nil || string(data) != string(want) {
+			t.Fatalf("ServeModbus() = % x, %v, want % x", data, err, want)
+		}
+	}
+	// 已缓存范围内的子集同样由缓存响应
+	if data, err := proxy.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 11, 1)); err != nil || string(data) != "\x02\x00\x00" {
+		t.Errorf("ServeModbus(11) = % x, %v", data, err)
+	}
+	if n := bus.requests.Load(); n != 1 {
+		t.Errorf("upstream requests = %d, want 1", n)
+	}
+
+	// 其他从站和数据表不共享缓存
+	proxy.ServeModbus(readRequest(2, FuncReadHoldingRegisters, 10, 2))
+	proxy.ServeModbus(readRequest(1, FuncReadCoils, 10, 2))
+	if n := bus.requests.Load(); n != 3 {
+		t.Errorf("upstream requests = %d, want 3", n)
+	}
+
+	// 过期后重新读取
+	now = now.Add(time.Second)
+	proxy.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 10, 2))
+	if n := bus.requests.Load(); n != 4 {
+		t.Errorf("upstream requests after expiry = %d, want 4", n)
+	}
+}
+
+// 测试扫描地址空间时过期的缓存被清理
+func TestProxyCacheEviction(t *testing.T) {
+	bus := newProxyBus(t)
+	proxy := NewProxy(NewClient(bus, 0).SetInterFrameDelay(0), time.Second)
+	now := time.Now()
+	proxy.now = func() time.Time { return now }
+
+	// 每次读取新的地址范围，缓存中最多保留两个有效期内读取的值
+	for i := range 30 {
+		if _, err := proxy.ServeModbus(readRequest(1, FuncReadCoils, uint16(i*2000), 2000)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(100 * time.Millisecond)
+		if n := len(proxy.cache); n > 20*2000 {
+			t.Fatalf("after %d scans cache holds %d entries", i+1, n)
+		}
+	}
+
+	// 不再被读取的过期值同样被清理
+	proxy.cache = map[proxyKey]proxyEntry{{1, TableHoldingRegisters, 10}: {fetched: now.Add(-time.Second)}}
+	proxy.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 20, 1))
+	if _, ok := proxy.cache[proxyKey{1, TableHoldingRegisters, 10}]; ok {
+		t.Error("expired entry not evicted")
+	}
+}
+
+func TestProxyCoilCache(t *testing.T) {
+	bus := newProxyBus(t)
+	proxy := NewProxy(NewClient(bus, 0).SetInterFrameDelay(0), time.Minute)
+
+	first, err := proxy.ServeModbus(readRequest(1, FuncReadCoils, 3, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := proxy.ServeModbus(readRequest(1, FuncReadCoils, 3, 10))
+	if err != nil || string(second) != string(first) {
+		t.Errorf("cached ServeModbus() = % x, %v, want % x", second, err, first)
+	}
+	if n := bus.requests.Load(); n != 1 {
+		t.Errorf("upstream requests = %d, want 1", n)
+	}
+}
+
+// 测试超出规范数量的读请求即使全部命中缓存也返回异常
+func TestProxyRejectsOversizedReads(t *testing.T) {
+	bus := newProxyBus(t)
+	proxy := NewProxy(NewClient(bus, 0).SetInterFrameDelay(0), time.Minute)
+
+	if _, err := proxy.ServeModbus(readRequest(1, FuncReadCoils, 0, 2000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proxy.ServeModbus(readRequest(1, FuncReadCoils, 2000, 100)); err != nil {
+		t.Fatal(err)
+	}
+	requests := bus.requests.Load()
+
+	for _, req := range []*Request{
+		readRequest(1, FuncReadCoils, 0, 2001),
+		readRequest(1, FuncReadCoils, 0, 0),
+		readRequest(1, FuncReadHoldingRegisters, 0, 126),
+	} {
+		if _, err := proxy.ServeModbus(req); !errors.Is(err, ErrIllegalDataValue) {
+			t.Errorf("read % x: error = %v, want ErrIllegalDataValue", req.Data, err)
+		}
+	}
+	if n := bus.requests.Load(); n != requests {
+		t.Errorf("oversized reads sent %d upstream requests", n-requests)
+	}
+}
+
+func TestProxyWriteInvalidates(t *testing.T) {
+	bus := newProxyBus(t)
+	proxy := NewProxy(NewClient(bus, 0).SetInterFrameDelay(0), time.Minute)
+
+	proxy.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 0, 4))
+	write := &Request{UnitID: 1, FunctionCode: FuncWriteSingleRegister, Data: []byte{0x00, 0x02, 0xAB, 0xCD}}
+	if data, err := proxy.ServeModbus(write); err != nil || string(data) != string(write.Data) {
+		t.Fatalf("write ServeModbus() = % x, %v", data, err)
+	}
+
+	data, err := proxy.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 0, 4))
+	if err != nil || data[5] != 0xAB || data[6] != 0xCD {
+		t.Errorf("ServeModbus() after write = % x, %v, want register 2 = abcd", data, err)
+	}
+	if n := bus.requests.Load(); n != 3 {
+		t.Errorf("upstream requests = %d, want 3", n)
+	}
+}
+
+// 测试相同的并发读请求只向上游发送一次
+func TestProxyCoalescesReads(t *testing.T) {
+	bus := newProxyBus(t)
+	bus.delay = 20 * time.Millisecond
+	proxy := NewProxy(NewClient(bus, 0).SetInterFrameDelay(0), 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := proxy.ServeModbus(readRequest(1, FuncReadHoldingRegisters, 0, 10)); err != nil {
+				t.Errorf("ServeModbus() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := bus.requests.Load(); n != 1 {
+		t.Errorf("upstream requests = %d, want 1", n)
+	}
+}
@@ -0,0 +1,212 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// remap.go 实现了服务器端的地址映射，把虚拟设备的地址布局映射到真实设备
+
+// ErrInvalidMapping 表示地址映射无效，例如虚拟地址范围重叠
+var ErrInvalidMapping = errors.New("modbus: invalid mapping")
+
+// Mapping 把虚拟设备一个数据表中的一段地址映射到真实设备
+type Mapping struct {
+	UnitID        byte    // 虚拟单元 ID
+	Table         Table   // 数据表，真实设备使用相同的数据表
+	Address       uint16  // 虚拟起始地址
+	Quantity      uint16  // 数量
+	TargetUnitID  byte    // 真实设备的单元 ID
+	TargetAddress uint16  // 真实设备上的起始地址
+	Target        Handler // 处理映射后请求的上游，nil 表示使用 Remapper 的默认上游
+}
+
+// 虚拟地址范围的结束地址（不含）
+func (m *Mapping) end() int {
+	return int(m.Address) + int(m.Quantity)
+}
+
+// Remapper 按地址映射转换请求后交给上游处理，用于向现有主站提供旧的寄存器布局
+//
+// 多个真实设备可以合并为一个虚拟单元。跨越多个映射的请求会被拆分为多个上游请求，
+// 因此跨设备的写操作不是原子的。对于有映射的虚拟单元，访问未映射的地址返回非法数据地址异常，
+// 不访问数据表的功能码返回非法功能异常；没有任何映射的单元 ID 的请求原样交给默认上游。
+type Remapper struct {
+	upstream Handler
+	mappings []Mapping // 按单元 ID、数据表和地址排序
+	units    map[byte]bool
+}
+
+// NewRemapper 创建地址映射层，upstream 为默认上游（例如 Gateway 或 Proxy）
+// 映射的数量为 0、地址超出范围或同一虚拟单元和数据表中的地址范围重叠时返回 ErrInvalidMapping
+func NewRemapper(upstream Handler, mappings ...Mapping) (*Remapper, error) {
+	r := &Remapper{
+		upstream: upstream,
+		mappings: slices.Clone(mappings),
+		units:    make(map[byte]bool),
+	}
+	slices.SortFunc(r.mappings, func(a, b Mapping) int {
+		if a.UnitID != b.UnitID {
+			return int(a.UnitID) - int(b.UnitID)
+		}
+		if a.Table != b.Table {
+			return int(a.Table) - int(b.Table)
+		}
+		return int(a.Address) - int(b.Address)
+	})
+
+	for i := range r.mappings {
+		m := &r.mappings[i]
+		switch {
+		case m.Table < TableCoils || m.Table > TableInputRegisters:
+			return nil, fmt.Errorf("%w: unit %d: unknown table %d", ErrInvalidMapping, m.UnitID, m.Table)
+		case m.Quantity == 0:
+			return nil, fmt.Errorf("%w: unit %d %s %d: zero quantity", ErrInvalidMapping, m.UnitID, m.Table, m.Address)
+		case m.end() > 0x10000 || int(m.TargetAddress)+int(m.Quantity) > 0x10000:
+			return nil, fmt.Errorf("%w: unit %d %s %d: range exceeds address space", ErrInvalidMapping, m.UnitID, m.Table, m.Address)
+		case m.Target == nil && upstream == nil:
+			return nil, fmt.Errorf("%w: unit %d %s %d: no upstream", ErrInvalidMapping, m.UnitID, m.Table, m.Address)
+		}
+		if i > 0 {
+			prev := &r.mappings[i-1]
+			if prev.UnitID == m.UnitID && prev.Table == m.Table && prev.end() > int(m.Address) {
+				return nil, fmt.Errorf("%w: unit %d %s %d-%d overlaps %d-%d", ErrInvalidMapping,
+					m.UnitID, m.Table, m.Address, m.end()-1, prev.Address, prev.end()-1)
+			}
+		}
+		r.units[m.UnitID] = true
+	}
+	return r, nil
+}
+
+// 请求中由同一个映射处理的一段
+type remapSegment struct {
+	mapping  *Mapping
+	offset   int    // 在请求中的偏移
+	address  uint16 // 真实设备上的起始地址
+	quantity uint16
+}
+
+// ServeModbus 实现 Handler 接口
+func (r *Remapper) ServeModbus(req *Request) ([]byte, error) {
+	if !r.units[req.UnitID] {
+		if r.upstream == nil {
+			return nil, ErrGatewayPathUnavailable
+		}
+		return r.upstream.ServeModbus(req)
+	}
+
+	table, _ := FunctionTable(req.FunctionCode)
+	address, quantity, ok := req.AddressRange()
+	if table == 0 || !ok {
+		return nil, ErrIllegalFunction
+	}
+	// 与 DataModel 一样按规范限制数量，跨多个段组装的响应同样不能超出一个 PDU
+	if limit, limited := maxQuantity[req.FunctionCode]; quantity == 0 || limited && int(quantity) > limit {
+		return nil, ErrIllegalDataValue
+	}
+	segments, err := r.split(req.UnitID, table, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.FunctionCode {
+	case FuncWriteSingleCoil, FuncWriteSingleRegister:
+		seg := segments[0]
+		data := binary.BigEndian.AppendUint16(nil, seg.address)
+		data = append(data, req.Data[2:]...)
+		if _, err := r.forward(req, seg, data); err != nil {
+			return nil, err
+		}
+		return req.Data, nil
+
+	case FuncWriteMultipleCoils, FuncWriteMultipleRegisters:
+		values, ok := decodeValues(table, int(quantity), req.Data[4:])
+		if !ok {
+			return nil, ErrIllegalDataValue
+		}
+		for _, seg := range segments {
+			data := binary.BigEndian.AppendUint16(nil, seg.address)
+			data = binary.BigEndian.AppendUint16(data, seg.quantity)
+			data = append(data, encodeValues(table, values[seg.offset:seg.offset+int(seg.quantity)])...)
+			if _, err := r.forward(req, seg, data); err != nil {
+				return nil, err
+			}
+		}
+		return req.Data[:4], nil
+
+	default:
+		values := make([]uint16, quantity)
+		for _, seg := range segments {
+			data := binary.BigEndian.AppendUint16(nil, seg.address)
+			data = binary.BigEndian.AppendUint16(data, seg.quantity)
+			response, err := r.forward(req, seg, data)
+			if err != nil {
+				return nil, err
+			}
+			segValues, ok := decodeValues(table, int(seg.quantity), response)
+			if !ok {
+				return nil, ErrServerDeviceFailure
+			}
+			copy(values[seg.offset:], segValues)
+		}
+		return encodeValues(table, values), nil
+	}
+}
+
+// 把虚拟地址范围拆分为由各个映射处理的段，存在未映射的地址时返回非法数据地址
+func (r *Remapper) split(unitID byte, table Table, address, quantity uint16) ([]remapSegment, error) {
+	var segments []remapSegment
+	start, end := int(address), int(address)+int(quantity)
+	for a := start; a < end; {
+		i, _ := slices.BinarySearchFunc(r.mappings, a, func(m Mapping, a int) int {
+			switch {
+			case m.UnitID != unitID:
+				return int(m.UnitID) - int(unitID)
+			case m.Table != table:
+				return int(m.Table) - int(table)
+			case m.end() <= a:
+				return -1
+			case int(m.Address) > a:
+				return 1
+			default:
+				return 0
+			}
+		})
+		if i == len(r.mappings) {
+			return nil, ErrIllegalDataAddress
+		}
+		m := &r.mappings[i]
+		if m.UnitID != unitID || m.Table != table || int(m.Address) > a || m.end() <= a {
+			return nil, ErrIllegalDataAddress
+		}
+
+		n := min(end, m.end()) - a
+		segments = append(segments, remapSegment{
+			mapping:  m,
+			offset:   a - start,
+			address:  m.TargetAddress + uint16(a-int(m.Address)),
+			quantity: uint16(n),
+		})
+		a += n
+	}
+	return segments, nil
+}
+
+// 把映射后的请求交给上游处理
+func (r *Remapper) forward(req *Request, seg remapSegment, data []byte) ([]byte, error) {
+	target := seg.mapping.Target
+	if target == nil {
+		target = r.upstream
+	}
+	return target.ServeModbus(&Request{
+		UnitID:       seg.mapping.TargetUnitID,
+		FunctionCode: req.FunctionCode,
+		Data:         data,
+		RemoteAddr:   req.RemoteAddr,
+		TLS:          req.TLS,
+		Role:         req.Role,
+	})
+}
@@ -0,0 +1,238 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// 内存中的测试设备，按单元 ID 保存线圈和保持寄存器
+type memoryDevices struct {
+	mu        sync.Mutex
+	coils     map[byte]*[1000]uint16
+	registers map[byte]*[1000]uint16
+}
+
+func newMemoryDevices(unitIDs ...byte) *memoryDevices {
+	d := &memoryDevices{coils: make(map[byte]*[1000]uint16), registers: make(map[byte]*[1000]uint16)}
+	for _, id := range unitIDs {
+		d.coils[id] = new([1000]uint16)
+		d.registers[id] = new([1000]uint16)
+		for i := range d.registers[id] {
+			d.registers[id][i] = uint16(id)<<12 | uint16(i) // 单元 ID 和地址
+		}
+	}
+	return d
+}
+
+func (d *memoryDevices) ServeModbus(req *Request) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	table, _ := FunctionTable(req.FunctionCode)
+	store := d.registers[req.UnitID]
+	if table.bits() {
+		store = d.coils[req.UnitID]
+	}
+	if store == nil {
+		return nil, ErrGatewayTargetDeviceFailedToRespond
+	}
+	address, quantity, _ := req.AddressRange()
+	if int(address)+int(quantity) > len(store) {
+		return nil, ErrIllegalDataAddress
+	}
+
+	switch req.FunctionCode {
+	case FuncReadCoils, FuncReadHoldingRegisters:
+		return encodeValues(table, store[address:address+quantity]), nil
+	case FuncWriteSingleRegister:
+		store[address] = binary.BigEndian.Uint16(req.Data[2:4])
+		return req.Data, nil
+	case FuncWriteMultipleCoils, FuncWriteMultipleRegisters:
+		values, ok := decodeValues(table, int(quantity), req.Data[4:])
+		if !ok {
+			return nil, ErrIllegalDataValue
+		}
+		copy(store[address:], values)
+		return req.Data[:4], nil
+	}
+	return nil, ErrIllegalFunction
+}
+
+// 虚拟单元 10 的保持寄存器 0-9 来自设备 1 的 100-109，10-19 来自设备 2 的 0-9
+func newTestRemapper(t *testing.T, devices *memoryDevices) *Remapper {
+	t.Helper()
+	r, err := NewRemapper(devices,
+		Mapping{UnitID: 10, Table: TableHoldingRegisters, Address: 10, Quantity: 10, TargetUnitID: 2},
+		Mapping{UnitID: 10, Table: TableHoldingRegisters, Address: 0, Quantity: 10, TargetUnitID: 1, TargetAddress: 100},
+		Mapping{UnitID: 10, Table: TableCoils, Address: 0, Quantity: 4, TargetUnitID: 1, TargetAddress: 6},
+		Mapping{UnitID: 10, Table: TableCoils, Address: 4, Quantity: 4, TargetUnitID: 2},
+	)
+	if err != nil {
+		t.Fatalf("NewRemapper() error = %v", err)
+	}
+	return r
+}
+
+func TestRemapperMergesDevices(t *testing.T) {
+	devices := newMemoryDevices(1, 2)
+	r := newTestRemapper(t, devices)
+
+	data, err := r.ServeModbus(readRequest(10, FuncReadHoldingRegisters, 8, 4))
+	if err != nil {
+		t.Fatalf("ServeModbus() error = %v", err)
+	}
+	values, _ := decodeValues(TableHoldingRegisters, 4, data)
+	want := []uint16{0x1000 | 108, 0x1000 | 109, 0x2000, 0x2001}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Fatalf("read across devices = %04x, want %04x", values, want)
+		}
+	}
+
+	// 跨设备写多个寄存器
+	write := &Request{UnitID: 10, FunctionCode: FuncWriteMultipleRegisters,
+		Data: append([]byte{0x00, 0x09, 0x00, 0x02}, encodeValues(TableHoldingRegisters, []uint16{0xAAAA, 0xBBBB})...)}
+	if data, err := r.ServeModbus(write); err != nil || string(data) != string(write.Data[:4]) {
+		t.Fatalf("write ServeModbus() = % x, %v", data, err)
+	}
+	if devices.registers[1][109] != 0xAAAA || devices.registers[2][0] != 0xBBBB {
+		t.Errorf("registers after write = %04x, %04x", devices.registers[1][109], devices.registers[2][0])
+	}
+
+	single := &Request{UnitID: 10, FunctionCode: FuncWriteSingleRegister, Data: []byte{0x00, 0x0B, 0x12, 0x34}}
+	if _, err := r.ServeModbus(single); err != nil || devices.registers[2][1] != 0x1234 {
+		t.Errorf("write single register: %v, device 2 register 1 = %04x", err, devices.registers[2][1])
+	}
+}
+
+func TestRemapperCoils(t *testing.T) {
+	devices := newMemoryDevices(1, 2)
+	r := newTestRemapper(t, devices)
+
+	write := &Request{UnitID: 10, FunctionCode: FuncWriteMultipleCoils,
+		Data: append([]byte{0x00, 0x02, 0x00, 0x04}, encodeValues(TableCoils, []uint16{1, 0, 1, 1})...)}
+	if _, err := r.ServeModbus(write); err != nil {
+		t.Fatalf("write coils error = %v", err)
+	}
+	if devices.coils[1][8] != 1 || devices.coils[1][9] != 0 || devices.coils[2][0] != 1 || devices.coils[2][1] != 1 {
+		t.Errorf("coils after write = %v %v", devices.coils[1][6:10], devices.coils[2][0:4])
+	}
+
+	data, err := r.ServeModbus(readRequest(10, FuncReadCoils, 0, 8))
+	if err != nil || string(data) != "\x01\x34" {
+		t.Errorf("read coils = % x, %v, want 01 34", data, err)
+	}
+}
+
+func TestRemapperErrors(t *testing.T) {
+	r := newTestRemapper(t, newMemoryDevices(1, 2))
+
+	tests := []struct {
+		name string
+		req  *Request
+		want error
+	}{
+		{"unmapped address", readRequest(10, FuncReadHoldingRegisters, 18, 3), ErrIllegalDataAddress},
+		{"unmapped table", readRequest(10, FuncReadInputRegisters, 0, 1), ErrIllegalDataAddress},
+		{"zero quantity", readRequest(10, FuncReadHoldingRegisters, 0, 0), ErrIllegalDataValue},
+		{"non-table function", &Request{UnitID: 10, FunctionCode: FuncReadExceptionStatus}, ErrIllegalFunction},
+	}
+	for _, tt := range tests {
+		if _, err := r.ServeModbus(tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// 没有映射的单元 ID 原样转发
+	data, err := r.ServeModbus(readRequest(2, FuncReadHoldingRegisters, 5, 1))
+	if err != nil || string(data) != "\x02\x20\x05" {
+		t.Errorf("pass-through read = % x, %v", data, err)
+	}
+}
+
+// 测试跨多个段的请求同样按规范限制数量
+func TestRemapperQuantityLimits(t *testing.T) {
+	devices := newMemoryDevices(1, 2, 3)
+	var mappings []Mapping
+	for i, id := range []byte{1, 2, 3} {
+		mappings = append(mappings,
+			Mapping{UnitID: 20, Table: TableHoldingRegisters, Address: uint16(i * 1000), Quantity: 1000, TargetUnitID: id},
+			Mapping{UnitID: 20, Table: TableCoils, Address: uint16(i * 1000), Quantity: 1000, TargetUnitID: id})
+	}
+	r, err := NewRemapper(devices, mappings...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.ServeModbus(readRequest(20, FuncReadHoldingRegisters, 950, 125)); err != nil {
+		t.Errorf("read 125 registers across devices: %v", err)
+	}
+	writeRegisters := func(n int) *Request {
+		data := binary.BigEndian.AppendUint16([]byte{0x03, 0xE0}, uint16(n)) // 地址 992
+		return &Request{UnitID: 20, FunctionCode: FuncWriteMultipleRegisters,
+			Data: append(append(data, byte(2*n)), encodeValues(TableHoldingRegisters, make([]uint16, n))[1:]...)}
+	}
+	writeCoils := func(n int) *Request {
+		values := make([]uint16, n)
+		for i := range values {
+			values[i] = 1
+		}
+		data := binary.BigEndian.AppendUint16([]byte{0x03, 0xE0}, uint16(n))
+		return &Request{UnitID: 20, FunctionCode: FuncWriteMultipleCoils, Data: append(data, encodeValues(TableCoils, values)...)}
+	}
+	if _, err := r.ServeModbus(writeRegisters(123)); err != nil {
+		t.Errorf("write 123 registers across devices: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  *Request
+	}{
+		{"read 200 registers", readRequest(20, FuncReadHoldingRegisters, 900, 200)},
+		{"read 2001 coils", readRequest(20, FuncReadCoils, 500, 2001)},
+		{"write 124 registers", writeRegisters(124)},
+		{"write 1969 coils", writeCoils(1969)},
+	}
+	for _, tt := range tests {
+		if _, err := r.ServeModbus(tt.req); !errors.Is(err, ErrIllegalDataValue) {
+			t.Errorf("%s: error = %v, want ErrIllegalDataValue", tt.name, err)
+		}
+	}
+	// 123 个寄存器写到设备 2 的地址 114 为止
+	if devices.registers[2][115] != 0x2000|115 || devices.coils[2][0] != 0 {
+		t.Errorf("oversized write reached the devices")
+	}
+}
+
+func TestNewRemapperValidation(t *testing.T) {
+	devices := newMemoryDevices(1)
+	tests := []struct {
+		name     string
+		mappings []Mapping
+	}{
+		{"overlap", []Mapping{
+			{UnitID: 1, Table: TableHoldingRegisters, Address: 0, Quantity: 10},
+			{UnitID: 1, Table: TableHoldingRegisters, Address: 9, Quantity: 10},
+		}},
+		{"zero quantity", []Mapping{{UnitID: 1, Table: TableCoils, Address: 0}}},
+		{"address overflow", []Mapping{{UnitID: 1, Table: TableCoils, Address: 0xFFFF, Quantity: 2}}},
+		{"unknown table", []Mapping{{UnitID: 1, Address: 0, Quantity: 1}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewRemapper(devices, tt.mappings...); !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("%s: error = %v, want ErrInvalidMapping", tt.name, err)
+		}
+	}
+
+	// 不同数据表或不同单元 ID 的相同地址范围不算重叠
+	_, err := NewRemapper(devices,
+		Mapping{UnitID: 1, Table: TableHoldingRegisters, Address: 0, Quantity: 10},
+		Mapping{UnitID: 1, Table: TableInputRegisters, Address: 0, Quantity: 10},
+		Mapping{UnitID: 2, Table: TableHoldingRegisters, Address: 0, Quantity: 10},
+	)
+	if err != nil {
+		t.Errorf("NewRemapper() error = %v", err)
+	}
+}
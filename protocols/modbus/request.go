@@ -4,65 +4,80 @@ import (
 	"encoding/binary"
 )
 
+// 请求帧生成器
+//
+// NewXxxRequest 每次分配一个新的请求帧；AppendXxxRequest 把请求帧追加到调用方提供的切片，
+// 容量足够时不分配内存，适合高频轮询时复用缓冲区：
+//
+//	buf := make([]byte, 0, 256)
+//	buf = modbus.AppendReadHoldingRegistersRequest(buf[:0], 1, 0, 10)
+
+// 请求帧除数据外的长度：从站 ID + 功能码 + CRC
+const requestOverhead = 4
+
+// 追加从站 ID、功能码、地址和数量（或值）组成的 4 字节数据请求
+func appendAddressRequest(dst []byte, slaveID, functionCode byte, address, value uint16) []byte {
+	start := len(dst)
+	dst = append(dst, slaveID, functionCode)
+	dst = binary.BigEndian.AppendUint16(dst, address)
+	dst = binary.BigEndian.AppendUint16(dst, value)
+	return appendCRC(dst, start)
+}
+
 // 请求帧生成器 - 位操作相关功能
 
 // NewReadCoilsRequest 创建读取线圈状态请求
 func NewReadCoilsRequest(slaveID byte, startAddress uint16, quantity uint16) []byte {
+	return AppendReadCoilsRequest(make([]byte, 0, requestOverhead+4), slaveID, startAddress, quantity)
+}
+
+// AppendReadCoilsRequest 把读取线圈状态请求追加到 dst
+// 数量超出 1~2000 时读取一个线圈
+func AppendReadCoilsRequest(dst []byte, slaveID byte, startAddress uint16, quantity uint16) []byte {
 	if quantity < 1 || quantity > 2000 {
 		quantity = 1 // 默认读取一个线圈
 	}
-
-	data := make([]byte, 5)
-	data[0] = slaveID
-	data[1] = FuncReadCoils
-	binary.BigEndian.PutUint16(data[2:4], startAddress)
-	data[4] = byte(quantity)
-	if quantity > 255 {
-		data = append(data[:4], byte(quantity>>8), byte(quantity))
-	}
-
-	return AppendCRC16(data)
+	return appendAddressRequest(dst, slaveID, FuncReadCoils, startAddress, quantity)
 }
 
 // NewReadDiscreteInputsRequest 创建读取离散输入状态请求
 func NewReadDiscreteInputsRequest(slaveID byte, startAddress uint16, quantity uint16) []byte {
+	return AppendReadDiscreteInputsRequest(make([]byte, 0, requestOverhead+4), slaveID, startAddress, quantity)
+}
+
+// AppendReadDiscreteInputsRequest 把读取离散输入状态请求追加到 dst
+// 数量超出 1~2000 时读取一个输入
+func AppendReadDiscreteInputsRequest(dst []byte, slaveID byte, startAddress uint16, quantity uint16) []byte {
 	if quantity < 1 || quantity > 2000 {
 		quantity = 1 // 默认读取一个输入
 	}
-
-	data := make([]byte, 5)
-	data[0] = slaveID
-	data[1] = FuncReadDiscreteInputs
-	binary.BigEndian.PutUint16(data[2:4], startAddress)
-	data[4] = byte(quantity)
-	if quantity > 255 {
-		data = append(data[:4], byte(quantity>>8), byte(quantity))
-	}
-
-	return AppendCRC16(data)
+	return appendAddressRequest(dst, slaveID, FuncReadDiscreteInputs, startAddress, quantity)
 }
 
 // NewWriteSingleCoilRequest 创建写单个线圈请求
 // 线圈状态: true = ON (0xFF00), false = OFF (0x0000)
 func NewWriteSingleCoilRequest(slaveID byte, address uint16, value bool) []byte {
-	data := make([]byte, 6)
-	data[0] = slaveID
-	data[1] = FuncWriteSingleCoil
-	binary.BigEndian.PutUint16(data[2:4], address)
+	return AppendWriteSingleCoilRequest(make([]byte, 0, requestOverhead+4), slaveID, address, value)
+}
 
+// AppendWriteSingleCoilRequest 把写单个线圈请求追加到 dst
+func AppendWriteSingleCoilRequest(dst []byte, slaveID byte, address uint16, value bool) []byte {
+	var state uint16
 	if value {
-		data[4] = 0xFF
-		data[5] = 0x00
-	} else {
-		data[4] = 0x00
-		data[5] = 0x00
+		state = 0xFF00
 	}
-
-	return AppendCRC16(data)
+	return appendAddressRequest(dst, slaveID, FuncWriteSingleCoil, address, state)
 }
 
 // NewWriteMultipleCoilsRequest 创建写多个线圈请求
 func NewWriteMultipleCoilsRequest(slaveID byte, startAddress uint16, values []bool) []byte {
+	buf := make([]byte, 0, requestOverhead+5+(len(values)+7)/8)
+	return AppendWriteMultipleCoilsRequest(buf, slaveID, startAddress, values)
+}
+
+// AppendWriteMultipleCoilsRequest 把写多个线圈请求追加到 dst
+// 线圈数量超出 1~1968 时返回 nil
+func AppendWriteMultipleCoilsRequest(dst []byte, slaveID byte, startAddress uint16, values []bool) []byte {
 	if len(values) < 1 || len(values) > 1968 {
 		return nil // 无效的线圈数量
 	}
@@ -71,91 +86,90 @@ func NewWriteMultipleCoilsRequest(slaveID byte, startAddress uint16, values []bo
 	byteCount := (len(values) + 7) / 8
 
 	// 请求头: slaveID + 功能码 + 起始地址(2字节) + 线圈数量(2字节) + 字节数
-	data := make([]byte, 6+1+byteCount)
-	data[0] = slaveID
-	data[1] = FuncWriteMultipleCoils
-	binary.BigEndian.PutUint16(data[2:4], startAddress)
-	binary.BigEndian.PutUint16(data[4:6], uint16(len(values)))
-	data[6] = byte(byteCount)
+	start := len(dst)
+	dst = append(dst, slaveID, FuncWriteMultipleCoils)
+	dst = binary.BigEndian.AppendUint16(dst, startAddress)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(values)))
+	dst = append(dst, byte(byteCount))
 
 	// 填充线圈状态
-	for i, value := range values {
-		byteIdx := 7 + i/8
-		bitIdx := uint(i % 8)
-
-		if value {
-			data[byteIdx] |= 1 << bitIdx
+	for i := 0; i < byteCount; i++ {
+		var b byte
+		for j, value := range values[i*8 : min(i*8+8, len(values))] {
+			if value {
+				b |= 1 << uint(j)
+			}
 		}
+		dst = append(dst, b)
 	}
 
-	return AppendCRC16(data)
+	return appendCRC(dst, start)
 }
 
 // 请求帧生成器 - 字操作相关功能
 
 // NewReadHoldingRegistersRequest 创建读取保持寄存器请求
 func NewReadHoldingRegistersRequest(slaveID byte, startAddress uint16, quantity uint16) []byte {
+	return AppendReadHoldingRegistersRequest(make([]byte, 0, requestOverhead+4), slaveID, startAddress, quantity)
+}
+
+// AppendReadHoldingRegistersRequest 把读取保持寄存器请求追加到 dst
+// 数量超出 1~125 时读取一个寄存器
+func AppendReadHoldingRegistersRequest(dst []byte, slaveID byte, startAddress uint16, quantity uint16) []byte {
 	if quantity < 1 || quantity > 125 {
 		quantity = 1 // 默认读取一个寄存器
 	}
-
-	data := make([]byte, 6)
-	data[0] = slaveID
-	data[1] = FuncReadHoldingRegisters
-	binary.BigEndian.PutUint16(data[2:4], startAddress)
-	binary.BigEndian.PutUint16(data[4:6], quantity)
-
-	return AppendCRC16(data)
+	return appendAddressRequest(dst, slaveID, FuncReadHoldingRegisters, startAddress, quantity)
 }
 
 // NewReadInputRegistersRequest 创建读取输入寄存器请求
 func NewReadInputRegistersRequest(slaveID byte, startAddress uint16, quantity uint16) []byte {
+	return AppendReadInputRegistersRequest(make([]byte, 0, requestOverhead+4), slaveID, startAddress, quantity)
+}
+
+// AppendReadInputRegistersRequest 把读取输入寄存器请求追加到 dst
+// 数量超出 1~125 时读取一个寄存器
+func AppendReadInputRegistersRequest(dst []byte, slaveID byte, startAddress uint16, quantity uint16) []byte {
 	if quantity < 1 || quantity > 125 {
 		quantity = 1 // 默认读取一个寄存器
 	}
-
-	data := make([]byte, 6)
-	data[0] = slaveID
-	data[1] = FuncReadInputRegisters
-	binary.BigEndian.PutUint16(data[2:4], startAddress)
-	binary.BigEndian.PutUint16(data[4:6], quantity)
-
-	return AppendCRC16(data)
+	return appendAddressRequest(dst, slaveID, FuncReadInputRegisters, startAddress, quantity)
 }
 
 // NewWriteSingleRegisterRequest 创建写单个寄存器请求
 func NewWriteSingleRegisterRequest(slaveID byte, address uint16, value uint16) []byte {
-	data := make([]byte, 6)
-	data[0] = slaveID
-	data[1] = FuncWriteSingleRegister
-	binary.BigEndian.PutUint16(data[2:4], address)
-	binary.BigEndian.PutUint16(data[4:6], value)
+	return AppendWriteSingleRegisterRequest(make([]byte, 0, requestOverhead+4), slaveID, address, value)
+}
 
-	return AppendCRC16(data)
+// AppendWriteSingleRegisterRequest 把写单个寄存器请求追加到 dst
+func AppendWriteSingleRegisterRequest(dst []byte, slaveID byte, address uint16, value uint16) []byte {
+	return appendAddressRequest(dst, slaveID, FuncWriteSingleRegister, address, value)
 }
 
 // NewWriteMultipleRegistersRequest 创建写多个寄存器请求
 func NewWriteMultipleRegistersRequest(slaveID byte, startAddress uint16, values []uint16) []byte {
+	buf := make([]byte, 0, requestOverhead+5+len(values)*2)
+	return AppendWriteMultipleRegistersRequest(buf, slaveID, startAddress, values)
+}
+
+// AppendWriteMultipleRegistersRequest 把写多个寄存器请求追加到 dst
+// 寄存器数量超出 1~123 时返回 nil
+func AppendWriteMultipleRegistersRequest(dst []byte, slaveID byte, startAddress uint16, values []uint16) []byte {
 	if len(values) < 1 || len(values) > 123 {
 		return nil // 无效的寄存器数量
 	}
 
-	// 字节数 = 寄存器数量 * 2
-	byteCount := len(values) * 2
-
 	// 请求头: slaveID + 功能码 + 起始地址(2字节) + 寄存器数量(2字节) + 字节数
-	data := make([]byte, 6+1+byteCount)
-	data[0] = slaveID
-	data[1] = FuncWriteMultipleRegisters
-	binary.BigEndian.PutUint16(data[2:4], startAddress)
-	binary.BigEndian.PutUint16(data[4:6], uint16(len(values)))
-	data[6] = byte(byteCount)
+	start := len(dst)
+	dst = append(dst, slaveID, FuncWriteMultipleRegisters)
+	dst = binary.BigEndian.AppendUint16(dst, startAddress)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(values)))
+	dst = append(dst, byte(len(values)*2))
 
 	// 填充寄存器值
-	for i, value := range values {
-		offset := 7 + i*2
-		binary.BigEndian.PutUint16(data[offset:offset+2], value)
+	for _, value := range values {
+		dst = binary.BigEndian.AppendUint16(dst, value)
 	}
 
-	return AppendCRC16(data)
+	return appendCRC(dst, start)
 }
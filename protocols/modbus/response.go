@@ -2,7 +2,7 @@ package modbus
 
 import (
 	"encoding/binary"
-	"errors"
+	"slices"
 )
 
 // 响应帧解析器 - 通用功能
@@ -47,10 +47,17 @@ func ValidateResponse(response []byte, expectedSlaveID, expectedFunctionCode byt
 // ParseReadBitsResponse 解析读取位状态（线圈或离散输入）的响应
 // 适用于功能码 0x01 和 0x02
 func ParseReadBitsResponse(response []byte, expectedSlaveID, expectedFunctionCode byte) ([]bool, error) {
+	// 由于 Modbus 协议中未指定总位数，所以我们返回所有位
+	// 调用者需要根据请求的位数量来取用适当数量的位
+	return appendReadBits(nil, response, expectedSlaveID, expectedFunctionCode, len(response)*8)
+}
+
+// 解析读取位状态的响应，把最多 quantity 个位追加到 dst
+func appendReadBits(dst []bool, response []byte, expectedSlaveID, expectedFunctionCode byte, quantity int) ([]bool, error) {
 	// 验证响应
 	err := ValidateResponse(response, expectedSlaveID, expectedFunctionCode)
 	if err != nil {
-		return nil, err
+		return dst, err
 	}
 
 	// 提取响应内容（不含 CRC）
@@ -58,38 +65,36 @@ func ParseReadBitsResponse(response []byte, expectedSlaveID, expectedFunctionCod
 
 	// 检查字节计数
 	if len(frameData) < 3 { // 从站 ID + 功能码 + 字节计数
-		return nil, ErrResponseTooShort
+		return dst, ErrResponseTooShort
 	}
 
 	byteCount := int(frameData[2])
 	if len(frameData) < 3+byteCount {
-		return nil, ErrResponseTooShort
+		return dst, ErrResponseTooShort
 	}
 
 	// 将字节转换为位状态
 	bitData := frameData[3 : 3+byteCount]
-
-	// 由于 Modbus 协议中未指定总位数，所以我们返回所有位
-	// 调用者需要根据请求的位数量来取用适当数量的位
-	result := make([]bool, byteCount*8)
-
-	for i := 0; i < byteCount; i++ {
-		for j := 0; j < 8; j++ {
-			idx := i*8 + j
-			result[idx] = (bitData[i] & (1 << uint(j))) != 0
-		}
+	dst = slices.Grow(dst, min(quantity, byteCount*8))
+	for i := 0; i < byteCount*8 && i < quantity; i++ {
+		dst = append(dst, bitData[i/8]&(1<<uint(i%8)) != 0)
 	}
 
-	return result, nil
+	return dst, nil
 }
 
 // ParseReadRegistersResponse 解析读取寄存器（保持寄存器或输入寄存器）的响应
 // 适用于功能码 0x03 和 0x04
 func ParseReadRegistersResponse(response []byte, expectedSlaveID, expectedFunctionCode byte) ([]uint16, error) {
+	return appendReadRegisters(nil, response, expectedSlaveID, expectedFunctionCode)
+}
+
+// 解析读取寄存器的响应，把寄存器值追加到 dst
+func appendReadRegisters(dst []uint16, response []byte, expectedSlaveID, expectedFunctionCode byte) ([]uint16, error) {
 	// 验证响应
 	err := ValidateResponse(response, expectedSlaveID, expectedFunctionCode)
 	if err != nil {
-		return nil, err
+		return dst, err
 	}
 
 	// 提取响应内容（不含 CRC）
@@ -97,30 +102,27 @@ func ParseReadRegistersResponse(response []byte, expectedSlaveID, expectedFuncti
 
 	// 检查字节计数
 	if len(frameData) < 3 { // 从站 ID + 功能码 + 字节计数
-		return nil, ErrResponseTooShort
+		return dst, ErrResponseTooShort
 	}
 
 	byteCount := int(frameData[2])
 	if len(frameData) < 3+byteCount {
-		return nil, ErrResponseTooShort
+		return dst, ErrResponseTooShort
 	}
 
 	// 字节计数应该是偶数（每个寄存器 2 字节）
 	if byteCount%2 != 0 {
-		return nil, ErrInvalidLength
+		return dst, ErrInvalidLength
 	}
 
 	// 解析寄存器值
-	registerCount := byteCount / 2
 	registerData := frameData[3 : 3+byteCount]
-	result := make([]uint16, registerCount)
-
-	for i := 0; i < registerCount; i++ {
-		offset := i * 2
-		result[i] = binary.BigEndian.Uint16(registerData[offset : offset+2])
+	dst = slices.Grow(dst, byteCount/2)
+	for offset := 0; offset < byteCount; offset += 2 {
+		dst = append(dst, binary.BigEndian.Uint16(registerData[offset:offset+2]))
 	}
 
-	return result, nil
+	return dst, nil
 }
 
 // ParseWriteSingleCoilResponse 解析写单个线圈的响应
@@ -142,7 +144,7 @@ func ParseWriteSingleCoilResponse(response []byte, expectedSlaveID byte, expecte
 	// 检查地址是否匹配
 	address := binary.BigEndian.Uint16(frameData[2:4])
 	if address != expectedAddress {
-		return errors.New("modbus: address mismatch in response")
+		return ErrAddressMismatch
 	}
 
 	// 检查值是否匹配
@@ -150,7 +152,7 @@ func ParseWriteSingleCoilResponse(response []byte, expectedSlaveID byte, expecte
 	expectedOff := frameData[4] == 0x00 && frameData[5] == 0x00
 
 	if value != expectedValue && !(expectedValue == false && expectedOff) {
-		return errors.New("modbus: value mismatch in response")
+		return ErrValueMismatch
 	}
 
 	return nil
@@ -175,13 +177,13 @@ func ParseWriteSingleRegisterResponse(response []byte, expectedSlaveID byte, exp
 	// 检查地址是否匹配
 	address := binary.BigEndian.Uint16(frameData[2:4])
 	if address != expectedAddress {
-		return errors.New("modbus: address mismatch in response")
+		return ErrAddressMismatch
 	}
 
 	// 检查值是否匹配
 	value := binary.BigEndian.Uint16(frameData[4:6])
 	if value != expectedValue {
-		return errors.New("modbus: value mismatch in response")
+		return ErrValueMismatch
 	}
 
 	return nil
@@ -206,13 +208,13 @@ func ParseWriteMultipleCoilsResponse(response []byte, expectedSlaveID byte, expe
 	// 检查地址是否匹配
 	address := binary.BigEndian.Uint16(frameData[2:4])
 	if address != expectedAddress {
-		return errors.New("modbus: address mismatch in response")
+		return ErrAddressMismatch
 	}
 
 	// 检查数量是否匹配
 	quantity := binary.BigEndian.Uint16(frameData[4:6])
 	if quantity != expectedQuantity {
-		return errors.New("modbus: quantity mismatch in response")
+		return ErrQuantityMismatch
 	}
 
 	return nil
@@ -237,13 +239,13 @@ func ParseWriteMultipleRegistersResponse(response []byte, expectedSlaveID byte,
 	// 检查地址是否匹配
 	address := binary.BigEndian.Uint16(frameData[2:4])
 	if address != expectedAddress {
-		return errors.New("modbus: address mismatch in response")
+		return ErrAddressMismatch
 	}
 
 	// 检查数量是否匹配
 	quantity := binary.BigEndian.Uint16(frameData[4:6])
 	if quantity != expectedQuantity {
-		return errors.New("modbus: quantity mismatch in response")
+		return ErrQuantityMismatch
 	}
 
 	return nil
@@ -0,0 +1,70 @@
+package modbus
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// retry.go 实现了客户端请求的重试策略
+
+// RetryPolicy 描述客户端请求失败后的重试策略
+//
+// 默认只对读操作重试。写操作不一定是幂等的（例如写入命令寄存器会触发设备动作），
+// 只有设置 RetryWrites 后才会对写操作重试。
+type RetryPolicy struct {
+	MaxAttempts    int                  // 最大尝试次数（包括第一次），小于等于 1 时不重试
+	InitialBackoff time.Duration        // 第一次重试前的等待时间
+	MaxBackoff     time.Duration        // 等待时间上限，0 表示不限制
+	Multiplier     float64              // 每次重试等待时间的增长倍数，小于 1 时按 1 处理
+	Jitter         float64              // 等待时间的随机抖动比例，取值 0~1
+	Retryable      func(err error) bool // 判断错误是否可重试，nil 时使用 IsRetryable
+	RetryWrites    bool                 // 是否对写操作重试
+}
+
+// NewRetryPolicy 创建一个带有指数退避和抖动的重试策略
+// 首次重试等待 50ms，之后每次翻倍，最长 1s，抖动比例 20%
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// attempts 返回对指定操作允许的最大尝试次数
+func (p *RetryPolicy) attempts(write bool) int {
+	if p == nil || p.MaxAttempts <= 1 || (write && !p.RetryWrites) {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryable 判断错误是否可重试
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// Backoff 返回第 retry 次重试（从 1 开始）前应等待的时间
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff)
+	multiplier := max(p.Multiplier, 1)
+	for i := 1; i < retry; i++ {
+		d *= multiplier
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		d += d * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,154 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 按顺序返回预设响应的传输接口
+type scriptedTransport struct {
+	responses [][]byte
+	writes    int
+	reads     int
+}
+
+func (s *scriptedTransport) Write(p []byte) (n int, err error) {
+	s.writes++
+	return len(p), nil
+}
+
+func (s *scriptedTransport) Read(p []byte) (n int, err error) {
+	if s.reads >= len(s.responses) {
+		return 0, errors.New("no more responses")
+	}
+	n = copy(p, s.responses[s.reads])
+	s.reads++
+	return n, nil
+}
+
+// 生成带 CRC 的帧
+func frame(data ...byte) []byte {
+	return AppendCRC16(data)
+}
+
+// 生成 CRC 错误的帧
+func corruptFrame(data ...byte) []byte {
+	f := AppendCRC16(data)
+	f[len(f)-1] ^= 0xFF
+	return f
+}
+
+func newRetryPolicy(maxAttempts int) *RetryPolicy {
+	p := NewRetryPolicy(maxAttempts)
+	p.InitialBackoff = time.Millisecond
+	return p
+}
+
+// 测试读操作在 CRC 错误和从站忙之后重试成功
+func TestRetryRead(t *testing.T) {
+	transport := &scriptedTransport{responses: [][]byte{
+		corruptFrame(0x01, 0x03, 0x02, 0x00, 0x2A),
+		frame(0x01, 0x83, ExcServerDeviceBusy),
+		frame(0x01, 0x03, 0x02, 0x00, 0x2A),
+	}}
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).SetRetryPolicy(newRetryPolicy(3))
+
+	registers, err := client.ReadHoldingRegisters(0, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if len(registers) != 1 || registers[0] != 0x2A {
+		t.Errorf("ReadHoldingRegisters() = %v, want [42]", registers)
+	}
+	if transport.writes != 3 {
+		t.Errorf("request sent %d times, want 3", transport.writes)
+	}
+}
+
+// 测试达到最大尝试次数后返回最后一次的错误
+func TestRetryExhausted(t *testing.T) {
+	transport := &scriptedTransport{responses: [][]byte{
+		corruptFrame(0x01, 0x03, 0x02, 0x00, 0x2A),
+		corruptFrame(0x01, 0x03, 0x02, 0x00, 0x2A),
+		frame(0x01, 0x03, 0x02, 0x00, 0x2A),
+	}}
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).SetRetryPolicy(newRetryPolicy(2))
+
+	_, err := client.ReadHoldingRegisters(0, 1)
+	if !errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want ErrCRCMismatch", err)
+	}
+	if transport.writes != 2 {
+		t.Errorf("request sent %d times, want 2", transport.writes)
+	}
+}
+
+// 测试不可重试的错误不会重试
+func TestRetryNonRetryable(t *testing.T) {
+	transport := &scriptedTransport{responses: [][]byte{
+		frame(0x01, 0x83, ExcIllegalDataAddress),
+		frame(0x01, 0x03, 0x02, 0x00, 0x2A),
+	}}
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).SetRetryPolicy(newRetryPolicy(3))
+
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want ErrIllegalDataAddress", err)
+	}
+	if transport.writes != 1 {
+		t.Errorf("request sent %d times, want 1", transport.writes)
+	}
+}
+
+// 测试写操作默认不重试，设置 RetryWrites 后才重试
+func TestRetryWrites(t *testing.T) {
+	responses := func() [][]byte {
+		return [][]byte{
+			frame(0x01, 0x86, ExcServerDeviceBusy),
+			frame(0x01, 0x06, 0x00, 0x01, 0x00, 0x03),
+		}
+	}
+
+	transport := &scriptedTransport{responses: responses()}
+	policy := newRetryPolicy(3)
+	client := NewClient(transport, 0x01).SetInterFrameDelay(0).SetRetryPolicy(policy)
+	if err := client.WriteSingleRegister(1, 3); !errors.Is(err, ErrServerDeviceBusy) {
+		t.Errorf("WriteSingleRegister() error = %v, want ErrServerDeviceBusy", err)
+	}
+	if transport.writes != 1 {
+		t.Errorf("request sent %d times, want 1", transport.writes)
+	}
+
+	transport = &scriptedTransport{responses: responses()}
+	policy.RetryWrites = true
+	client = NewClient(transport, 0x01).SetInterFrameDelay(0).SetRetryPolicy(policy)
+	if err := client.WriteSingleRegister(1, 3); err != nil {
+		t.Errorf("WriteSingleRegister() error = %v", err)
+	}
+	if transport.writes != 2 {
+		t.Errorf("request sent %d times, want 2", transport.writes)
+	}
+}
+
+// 测试退避时间的增长和上限
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(1); got < 5*time.Millisecond || got > 15*time.Millisecond {
+			t.Fatalf("Backoff(1) with jitter = %v, want within [5ms, 15ms]", got)
+		}
+	}
+}
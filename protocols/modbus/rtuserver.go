@@ -0,0 +1,121 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"log"
+)
+
+// rtuserver.go 实现了在串口等字节流上工作的 Modbus RTU 从站
+
+// RTUServer 是 Modbus RTU 服务器（从站），在 io.ReadWriter 上接收请求帧并返回响应
+//
+// 请求帧按功能码确定长度，CRC 错误或无法识别的数据会被逐字节丢弃直到重新同步。
+// 广播请求（从站 ID 为 0）会被处理但不发送响应。
+type RTUServer struct {
+	Handler    Handler     // 请求处理器
+	Authorizer Authorizer  // 授权检查，nil 表示不检查
+	SlaveID    byte        // 响应的从站 ID，0 表示响应所有从站 ID
+	ErrorLog   *log.Logger // 写入错误日志，nil 时不记录
+}
+
+// NewRTUServer 创建只响应指定从站 ID 的 Modbus RTU 服务器
+func NewRTUServer(handler Handler, slaveID byte) *RTUServer {
+	return &RTUServer{Handler: handler, SlaveID: slaveID}
+}
+
+// Serve 在 rw 上处理请求，直到读取出错，rw 到达末尾时返回 nil
+//
+// rw 的读取超时（例如串口配置的 Timeout）被当作帧间静默，未完成的帧会被丢弃。
+func (s *RTUServer) Serve(rw io.ReadWriter) error {
+	buf := make([]byte, 0, maxRTUFrameLength)
+	chunk := make([]byte, maxRTUFrameLength)
+	for {
+		n, err := rw.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		for {
+			frame, rest, complete := nextRequestFrame(buf)
+			if complete {
+				s.serveFrame(rw, frame)
+			}
+			buf = append(buf[:0], rest...)
+			if !complete {
+				break
+			}
+		}
+		// 超长的无效数据全部丢弃
+		if len(buf) >= maxRTUFrameLength {
+			buf = buf[:0]
+		}
+
+		if err != nil {
+			switch {
+			case IsTimeout(err):
+				buf = buf[:0]
+			case errors.Is(err, io.EOF):
+				return nil
+			default:
+				return err
+			}
+		}
+	}
+}
+
+// nextRequestFrame 从缓冲区开头取出一个 CRC 正确的请求帧
+// 开头的数据无法构成有效帧时逐字节丢弃，数据不足时 complete 为 false
+func nextRequestFrame(buf []byte) (frame, rest []byte, complete bool) {
+	for len(buf) > 0 {
+		n, ok := rtuRequestLength(buf)
+		if ok && n == 0 {
+			return nil, buf, false
+		}
+		if !ok {
+			// 未知功能码的帧只有在整个缓冲区恰好是一个有效帧时才接受，
+			// 后面出现已知长度的有效帧时说明开头是噪声，丢弃到该帧为止
+			if len(buf) >= 4 && CheckCRC16(buf) {
+				return buf, nil, true
+			}
+			if i := findRequestFrame(buf[1:]); i >= 0 {
+				buf = buf[1+i:]
+				continue
+			}
+			return nil, buf, false
+		}
+		if len(buf) < n {
+			return nil, buf, false
+		}
+		if CheckCRC16(buf[:n]) {
+			return buf[:n], buf[n:], true
+		}
+		buf = buf[1:]
+	}
+	return nil, buf, false
+}
+
+// findRequestFrame 返回 buf 中第一个已知功能码且 CRC 正确的完整请求帧的位置，没有时返回 -1
+func findRequestFrame(buf []byte) int {
+	for i := range buf {
+		if n, ok := rtuRequestLength(buf[i:]); ok && n > 0 && len(buf)-i >= n && CheckCRC16(buf[i:i+n]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 处理一个请求帧并写入响应
+func (s *RTUServer) serveFrame(w io.Writer, frame []byte) {
+	unitID := frame[0]
+	if s.SlaveID != 0 && unitID != s.SlaveID && unitID != 0 {
+		return
+	}
+
+	req := &Request{UnitID: unitID, FunctionCode: frame[1], Data: append([]byte(nil), frame[2:len(frame)-2]...)}
+	pdu := handleRequest(s.Handler, s.Authorizer, req)
+	if pdu == nil || unitID == 0 {
+		return
+	}
+	if _, err := w.Write(pduToRTU(unitID, pdu)); err != nil && s.ErrorLog != nil {
+		s.ErrorLog.Printf("modbus: write response: %v", err)
+	}
+}
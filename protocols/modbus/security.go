@@ -0,0 +1,87 @@
+package modbus
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"slices"
+)
+
+// security.go 实现了 Modbus/TCP Security 规范中基于证书角色的授权
+
+// RoleOID 是 Modbus/TCP Security 规范中证书角色扩展的 OID，扩展值为 ASN.1 UTF8String
+var RoleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// CertificateRole 返回证书中的 Modbus 角色，证书没有角色扩展时返回空字符串
+func CertificateRole(cert *x509.Certificate) (string, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(RoleOID) {
+			continue
+		}
+		var role string
+		rest, err := asn1.UnmarshalWithParams(ext.Value, &role, "utf8")
+		if err != nil {
+			return "", fmt.Errorf("modbus: invalid role extension: %w", err)
+		}
+		if len(rest) != 0 {
+			return "", fmt.Errorf("modbus: invalid role extension: trailing data")
+		}
+		return role, nil
+	}
+	return "", nil
+}
+
+// Authorizer 决定是否允许执行请求，拒绝时服务器返回非法功能异常
+type Authorizer interface {
+	Authorize(req *Request) bool
+}
+
+// AuthorizerFunc 使用函数实现 Authorizer 接口
+type AuthorizerFunc func(req *Request) bool
+
+// Authorize 实现 Authorizer 接口
+func (f AuthorizerFunc) Authorize(req *Request) bool {
+	return f(req)
+}
+
+// AccessRule 描述一个角色可以使用的功能码和地址范围
+type AccessRule struct {
+	Role          string // 角色
+	FunctionCodes []byte // 允许的功能码，为空表示所有功能码
+	StartAddress  uint16 // 允许访问的起始地址
+	Quantity      int    // 允许访问的地址数量，0 表示不限制地址
+}
+
+// allows 判断规则是否允许请求
+func (r *AccessRule) allows(req *Request) bool {
+	if r.Role != req.Role {
+		return false
+	}
+	if len(r.FunctionCodes) > 0 && !slices.Contains(r.FunctionCodes, req.FunctionCode) {
+		return false
+	}
+	if r.Quantity == 0 {
+		return true
+	}
+
+	// 限制了地址范围时，只允许访问数据表的请求
+	address, quantity, ok := req.AddressRange()
+	if !ok || quantity == 0 {
+		return false
+	}
+	start := int(r.StartAddress)
+	return int(address) >= start && int(address)+int(quantity) <= start+r.Quantity
+}
+
+// RuleAuthorizer 是基于规则列表的授权器，任意一条规则允许即放行
+type RuleAuthorizer []AccessRule
+
+// Authorize 实现 Authorizer 接口
+func (rules RuleAuthorizer) Authorize(req *Request) bool {
+	for i := range rules {
+		if rules[i].allows(req) {
+			return true
+		}
+	}
+	return false
+}
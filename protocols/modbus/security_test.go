@@ -0,0 +1,168 @@
+package modbus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// 测试用的证书颁发机构
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "modbus test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// 签发证书，role 不为空时添加角色扩展
+func (ca *testCA) issue(t *testing.T, name, role string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if role != "" {
+		value, err := asn1.MarshalWithParams(role, "utf8")
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: RoleOID, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCertificateRole(t *testing.T) {
+	ca := newTestCA(t)
+	for _, role := range []string{"Operator", ""} {
+		cert, err := x509.ParseCertificate(ca.issue(t, "client", role).Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CertificateRole(cert)
+		if err != nil || got != role {
+			t.Errorf("CertificateRole() = %q, %v, want %q", got, err, role)
+		}
+	}
+}
+
+func TestRuleAuthorizer(t *testing.T) {
+	authorizer := RuleAuthorizer{
+		{Role: "Engineer"},
+		{Role: "Operator", FunctionCodes: []byte{FuncReadHoldingRegisters}},
+		{Role: "Operator", FunctionCodes: []byte{FuncWriteSingleRegister}, StartAddress: 100, Quantity: 10},
+	}
+	tests := []struct {
+		role    string
+		fc      byte
+		address uint16
+		want    bool
+	}{
+		{"Engineer", FuncWriteMultipleCoils, 0, true},
+		{"Operator", FuncReadHoldingRegisters, 5000, true},
+		{"Operator", FuncWriteSingleRegister, 105, true},
+		{"Operator", FuncWriteSingleRegister, 110, false},
+		{"Operator", FuncWriteMultipleCoils, 100, false},
+		{"", FuncReadHoldingRegisters, 0, false},
+	}
+	for _, tt := range tests {
+		req := &Request{Role: tt.role, FunctionCode: tt.fc, Data: []byte{byte(tt.address >> 8), byte(tt.address), 0x00, 0x01}}
+		if got := authorizer.Authorize(req); got != tt.want {
+			t.Errorf("Authorize(%q, %#x, %d) = %v, want %v", tt.role, tt.fc, tt.address, got, tt.want)
+		}
+	}
+}
+
+// 测试双向认证的 Modbus/TCP Security 连接以及基于角色的授权
+func TestTLSServer(t *testing.T) {
+	ca := newTestCA(t)
+	server := NewTCPServer(echoAddressHandler)
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "localhost", "")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	}
+	server.Authorizer = RuleAuthorizer{{Role: "Operator", StartAddress: 0, Quantity: 100}}
+	addr := startTCPServer(t, server)
+
+	newClient := func(certs ...tls.Certificate) *Client {
+		transport := NewTCPTransport(addr).SetTLSConfig(&tls.Config{
+			Certificates: certs,
+			RootCAs:      ca.pool,
+			ServerName:   "localhost",
+		})
+		t.Cleanup(func() { transport.Close() })
+		return NewTCPClient(transport, 0x01)
+	}
+
+	operator := newClient(ca.issue(t, "operator", "Operator"))
+	registers, err := operator.ReadHoldingRegisters(10, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if registers[0] != 10 {
+		t.Errorf("ReadHoldingRegisters() = %v, want [10]", registers)
+	}
+	if _, err := operator.ReadHoldingRegisters(500, 1); !errors.Is(err, ErrIllegalFunction) {
+		t.Errorf("ReadHoldingRegisters(500) error = %v, want ErrIllegalFunction", err)
+	}
+
+	guest := newClient(ca.issue(t, "guest", "Guest"))
+	if _, err := guest.ReadHoldingRegisters(10, 1); !errors.Is(err, ErrIllegalFunction) {
+		t.Errorf("guest ReadHoldingRegisters() error = %v, want ErrIllegalFunction", err)
+	}
+
+	anonymous := newClient()
+	if _, err := anonymous.ReadHoldingRegisters(10, 1); err == nil {
+		t.Error("ReadHoldingRegisters() without client certificate succeeded")
+	}
+}
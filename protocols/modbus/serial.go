@@ -0,0 +1,75 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// serial.go 定义了串口传输的线路参数，具体实现见 serial_linux.go
+
+// ErrSerialUnsupported 表示当前平台不支持内置串口传输
+var ErrSerialUnsupported = errors.New("modbus: serial port not supported on this platform")
+
+// Parity 表示串口校验方式
+type Parity int
+
+const (
+	ParityNone Parity = iota // 无校验
+	ParityOdd                // 奇校验
+	ParityEven               // 偶校验
+)
+
+// String 返回校验方式的简写（N、O、E）
+func (p Parity) String() string {
+	switch p {
+	case ParityNone:
+		return "N"
+	case ParityOdd:
+		return "O"
+	case ParityEven:
+		return "E"
+	default:
+		return fmt.Sprintf("Parity(%d)", int(p))
+	}
+}
+
+// SerialConfig 描述串口线路参数
+type SerialConfig struct {
+	Address  string        // 设备路径，例如 /dev/ttyUSB0
+	BaudRate int           // 波特率，默认 9600
+	DataBits int           // 数据位 5~8，默认 8
+	Parity   Parity        // 校验方式，默认无校验
+	StopBits int           // 停止位 1 或 2，默认 1
+	Timeout  time.Duration // 未设置读截止时间时单次读取的超时时间，0 表示一直阻塞
+}
+
+// String 返回形如 /dev/ttyUSB0 9600 8N1 的描述
+func (c SerialConfig) String() string {
+	return fmt.Sprintf("%s %d %d%s%d", c.Address, c.BaudRate, c.DataBits, c.Parity, c.StopBits)
+}
+
+// 填充默认值并检查参数
+func (c SerialConfig) normalize() (SerialConfig, error) {
+	if c.BaudRate == 0 {
+		c.BaudRate = 9600
+	}
+	if c.DataBits == 0 {
+		c.DataBits = 8
+	}
+	if c.StopBits == 0 {
+		c.StopBits = 1
+	}
+
+	switch {
+	case c.BaudRate < 0:
+		return c, fmt.Errorf("modbus: invalid baud rate %d", c.BaudRate)
+	case c.DataBits < 5 || c.DataBits > 8:
+		return c, fmt.Errorf("modbus: invalid data bits %d", c.DataBits)
+	case c.StopBits != 1 && c.StopBits != 2:
+		return c, fmt.Errorf("modbus: invalid stop bits %d", c.StopBits)
+	case c.Parity < ParityNone || c.Parity > ParityEven:
+		return c, fmt.Errorf("modbus: invalid parity %v", c.Parity)
+	}
+	return c, nil
+}
@@ -0,0 +1,312 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || riscv64 || s390x)
+
+package modbus
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// serial_linux.go 基于 termios 实现了 Linux 串口传输
+// ioctl 编号和标志位取自内核的通用定义，适用于 x86、ARM、RISC-V 等架构
+
+const (
+	ioctlTCGETS2 = 0x802C542A
+	ioctlTCSETS2 = 0x402C542B
+	ioctlTCFLSH  = 0x540B
+
+	ioctlTIOCGPTN   = 0x80045430
+	ioctlTIOCSPTLCK = 0x40045431
+
+	tcIFlush = 0
+
+	// c_iflag
+	tIGNBRK = 0x1
+	tBRKINT = 0x2
+	tPARMRK = 0x8
+	tINPCK  = 0x10
+	tISTRIP = 0x20
+	tINLCR  = 0x40
+	tIGNCR  = 0x80
+	tICRNL  = 0x100
+	tIXON   = 0x400
+	tIXOFF  = 0x1000
+
+	// c_oflag
+	tOPOST = 0x1
+
+	// c_lflag
+	tISIG   = 0x1
+	tICANON = 0x2
+	tECHO   = 0x8
+	tECHONL = 0x40
+	tIEXTEN = 0x8000
+
+	// c_cflag
+	tCBAUD   = 0x100F
+	tCSIZE   = 0x30
+	tCSTOPB  = 0x40
+	tCREAD   = 0x80
+	tPARENB  = 0x100
+	tPARODD  = 0x200
+	tCLOCAL  = 0x800
+	tBOTHER  = 0x1000
+	tCRTSCTS = 0x80000000
+
+	// c_cc 下标
+	tVTIME = 5
+	tVMIN  = 6
+)
+
+// 内核的 struct termios2，支持任意波特率
+type termios2 struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Line   uint8
+	Cc     [19]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+// SerialPort 是 Linux 串口传输，实现了 Transport 及其所有可选接口，可以直接传给 NewClient
+type SerialPort struct {
+	config SerialConfig
+
+	mu       sync.Mutex
+	file     *os.File  // Reconnect 时替换
+	closed   bool      // 已调用 Close
+	deadline time.Time // 通过 SetReadDeadline 设置的读截止时间
+}
+
+// OpenSerial 打开串口并按配置设置波特率、数据位、校验位和停止位
+// 串口被设置为原始模式，不做任何字符转换和流控
+func OpenSerial(config SerialConfig) (*SerialPort, error) {
+	config, err := config.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := openSerialFile(config)
+	if err != nil {
+		return nil, err
+	}
+	return &SerialPort{file: file, config: config}, nil
+}
+
+// 打开串口设备并设置线路参数
+func openSerialFile(config SerialConfig) (*os.File, error) {
+	fd, err := syscall.Open(config.Address, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: config.Address, Err: err}
+	}
+
+	if err := configureTermios(fd, config); err != nil {
+		syscall.Close(fd)
+		return nil, &os.PathError{Op: "configure", Path: config.Address, Err: err}
+	}
+
+	// 非阻塞的文件描述符会注册到运行时的网络轮询器，从而支持读截止时间
+	return os.NewFile(uintptr(fd), config.Address), nil
+}
+
+// 设置 termios 参数
+func configureTermios(fd int, config SerialConfig) error {
+	var t termios2
+	if err := ioctl(fd, ioctlTCGETS2, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+
+	// 原始模式
+	t.Iflag &^= tIGNBRK | tBRKINT | tPARMRK | tISTRIP | tINLCR | tIGNCR | tICRNL | tIXON | tIXOFF | tINPCK
+	t.Oflag &^= tOPOST
+	t.Lflag &^= tECHO | tECHONL | tICANON | tISIG | tIEXTEN
+
+	t.Cflag &^= tCBAUD | tCSIZE | tCSTOPB | tPARENB | tPARODD | tCRTSCTS
+	t.Cflag |= tCREAD | tCLOCAL | tBOTHER
+	t.Cflag |= uint32(config.DataBits-5) << 4 // CS5~CS8
+	if config.StopBits == 2 {
+		t.Cflag |= tCSTOPB
+	}
+	switch config.Parity {
+	case ParityOdd:
+		t.Cflag |= tPARENB | tPARODD
+		t.Iflag |= tINPCK
+	case ParityEven:
+		t.Cflag |= tPARENB
+		t.Iflag |= tINPCK
+	}
+
+	t.Ispeed = uint32(config.BaudRate)
+	t.Ospeed = uint32(config.BaudRate)
+
+	// 至少收到一个字节才返回，超时由轮询器的截止时间控制
+	t.Cc[tVMIN] = 1
+	t.Cc[tVTIME] = 0
+
+	return ioctl(fd, ioctlTCSETS2, unsafe.Pointer(&t))
+}
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Read 从串口读取数据
+// 设置了读截止时间时以截止时间为准，否则使用配置中的 Timeout
+// 超时返回的错误满足 errors.Is(err, os.ErrDeadlineExceeded)
+func (p *SerialPort) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	file, deadline := p.file, p.deadline
+	p.mu.Unlock()
+
+	if deadline.IsZero() && p.config.Timeout > 0 {
+		deadline = time.Now().Add(p.config.Timeout)
+	}
+	if err := file.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	return file.Read(b)
+}
+
+// Write 向串口写入数据
+func (p *SerialPort) Write(b []byte) (int, error) {
+	return p.current().Write(b)
+}
+
+// 当前打开的串口设备
+func (p *SerialPort) current() *os.File {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.file
+}
+
+// SetReadDeadline 设置读截止时间，零值表示恢复使用配置中的 Timeout
+func (p *SerialPort) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	p.deadline = t
+	p.mu.Unlock()
+	return nil
+}
+
+// Flush 丢弃接收缓冲区中尚未读取的数据
+func (p *SerialPort) Flush() error {
+	raw, err := p.current().SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ioctlErr error
+	if err := raw.Control(func(fd uintptr) {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlTCFLSH, tcIFlush); errno != 0 {
+			ioctlErr = errno
+		}
+	}); err != nil {
+		return err
+	}
+	if ioctlErr != nil {
+		return fmt.Errorf("modbus: flush %s: %w", p.config.Address, ioctlErr)
+	}
+	return nil
+}
+
+// Config 返回串口的线路参数
+func (p *SerialPort) Config() SerialConfig {
+	return p.config
+}
+
+// Reconnect 关闭并按原来的配置重新打开串口，用于 USB 转串口适配器拔插后恢复通讯
+// 客户端在读写出错后的下一次请求前自动调用；串口已关闭时返回 os.ErrClosed
+func (p *SerialPort) Reconnect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return os.ErrClosed
+	}
+
+	// 先关闭旧的设备，部分驱动不允许同一设备同时打开两次
+	p.file.Close()
+	file, err := openSerialFile(p.config)
+	if err != nil {
+		return err
+	}
+	p.file = file
+	return nil
+}
+
+// Close 关闭串口
+func (p *SerialPort) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return p.file.Close()
+}
+
+// PTY 是一对伪终端，主设备一端实现了 io.ReadWriter，从设备可以像真实串口一样打开，
+// 用于在没有硬件时把模拟的 RTU 从站提供给其他程序
+type PTY struct {
+	master *os.File
+	slave  *SerialPort // 保持从设备打开并处于原始模式，读取方关闭后主设备不会报错
+}
+
+// OpenPTY 打开一对伪终端，从设备被设置为原始模式
+func OpenPTY() (*PTY, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var n uint32
+	raw, err := master.SyscallConn()
+	if err == nil {
+		err = raw.Control(func(fd uintptr) {
+			var unlock int32
+			if err = ioctl(int(fd), ioctlTIOCSPTLCK, unsafe.Pointer(&unlock)); err == nil {
+				err = ioctl(int(fd), ioctlTIOCGPTN, unsafe.Pointer(&n))
+			}
+		})
+	}
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("modbus: open pseudo-terminal: %w", err)
+	}
+
+	slave, err := OpenSerial(SerialConfig{Address: fmt.Sprintf("/dev/pts/%d", n)})
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	return &PTY{master: master, slave: slave}, nil
+}
+
+// Path 返回从设备路径，例如 /dev/pts/3
+func (p *PTY) Path() string {
+	return p.slave.config.Address
+}
+
+// Read 从主设备读取其他程序写入从设备的数据
+func (p *PTY) Read(b []byte) (int, error) {
+	return p.master.Read(b)
+}
+
+// Write 向主设备写入数据，其他程序可以从从设备读取
+func (p *PTY) Write(b []byte) (int, error) {
+	return p.master.Write(b)
+}
+
+// SetReadDeadline 设置读截止时间
+func (p *PTY) SetReadDeadline(t time.Time) error {
+	return p.master.SetReadDeadline(t)
+}
+
+// Close 关闭伪终端
+func (p *PTY) Close() error {
+	p.slave.Close()
+	return p.master.Close()
+}
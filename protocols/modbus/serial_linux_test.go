@@ -0,0 +1,198 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || riscv64 || s390x)
+
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// 打开一对伪终端，返回主设备和从设备路径
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminal not available: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	var unlock int32
+	if err := ioctl(int(master.Fd()), ioctlTIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		t.Skipf("unlock pseudo-terminal: %v", err)
+	}
+	var n uint32
+	if err := ioctl(int(master.Fd()), ioctlTIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		t.Skipf("get pseudo-terminal number: %v", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func openTestSerial(t *testing.T, path string) *SerialPort {
+	t.Helper()
+	port, err := OpenSerial(SerialConfig{
+		Address:  path,
+		BaudRate: 19200,
+		Parity:   ParityEven,
+		Timeout:  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Skipf("open %s: %v", path, err)
+	}
+	t.Cleanup(func() { port.Close() })
+	return port
+}
+
+// 测试通过伪终端收发数据
+func TestSerialPortReadWrite(t *testing.T) {
+	master, path := openPTY(t)
+	port := openTestSerial(t, path)
+
+	if got := port.Config().String(); got != path+" 19200 8E1" {
+		t.Errorf("Config().String() = %q", got)
+	}
+
+	if _, err := port.Write([]byte{0x01, 0x03, 0x00}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf := make([]byte, 16)
+	n, err := master.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], []byte{0x01, 0x03, 0x00}) {
+		t.Fatalf("master.Read() = % X, %v", buf[:n], err)
+	}
+
+	if _, err := master.Write([]byte{0x0A, 0x0D, 0xFF}); err != nil {
+		t.Fatalf("master.Write() error = %v", err)
+	}
+	n, err = port.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], []byte{0x0A, 0x0D, 0xFF}) {
+		t.Fatalf("Read() = % X, %v, want raw bytes without translation", buf[:n], err)
+	}
+}
+
+// 测试读取超时和读截止时间
+func TestSerialPortTimeout(t *testing.T) {
+	_, path := openPTY(t)
+	port := openTestSerial(t, path)
+
+	start := time.Now()
+	_, err := port.Read(make([]byte, 16))
+	if !errors.Is(err, os.ErrDeadlineExceeded) || !IsTimeout(err) {
+		t.Fatalf("Read() error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Read() returned after %v, want about 200ms", elapsed)
+	}
+
+	port.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	start = time.Now()
+	if _, err := port.Read(make([]byte, 16)); !IsTimeout(err) {
+		t.Fatalf("Read() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Read() with deadline returned after %v, want about 20ms", elapsed)
+	}
+}
+
+// 测试丢弃接收缓冲区中的过期数据
+func TestSerialPortFlush(t *testing.T) {
+	master, path := openPTY(t)
+	port := openTestSerial(t, path)
+
+	master.Write([]byte{0xDE, 0xAD})
+	time.Sleep(20 * time.Millisecond)
+	if err := port.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	master.Write([]byte{0x01})
+	buf := make([]byte, 16)
+	n, err := port.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], []byte{0x01}) {
+		t.Fatalf("Read() after Flush() = % X, %v, want 01", buf[:n], err)
+	}
+}
+
+// 测试串口与客户端配合使用
+func TestSerialPortClient(t *testing.T) {
+	master, path := openPTY(t)
+	port := openTestSerial(t, path)
+
+	go func() {
+		buf := make([]byte, 256)
+		master.Read(buf)
+		master.Write(frame(0x01, 0x03, 0x02, 0x12, 0x34))
+	}()
+
+	client := NewClient(port, 0x01).SetInterFrameDelay(20 * time.Millisecond)
+	registers, err := client.ReadHoldingRegisters(0, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if len(registers) != 1 || registers[0] != 0x1234 {
+		t.Errorf("ReadHoldingRegisters() = %v, want [0x1234]", registers)
+	}
+}
+
+// 测试无效的线路参数
+func TestOpenSerialInvalidConfig(t *testing.T) {
+	for _, config := range []SerialConfig{
+		{Address: "/dev/null", DataBits: 9},
+		{Address: "/dev/null", StopBits: 3},
+		{Address: "/dev/null", Parity: Parity(7)},
+	} {
+		if _, err := OpenSerial(config); err == nil {
+			t.Errorf("OpenSerial(%+v) error = nil, want error", config)
+		}
+	}
+}
+
+// 测试在伪终端上提供 RTU 从站
+func TestPTYServer(t *testing.T) {
+	pty, err := OpenPTY()
+	if err != nil {
+		t.Skipf("pseudo-terminal not available: %v", err)
+	}
+	defer pty.Close()
+
+	model := NewDataModel(10)
+	model.Write(TableHoldingRegisters, 2, 0xBEEF)
+	go NewRTUServer(model, 1).Serve(pty)
+
+	port := openTestSerial(t, pty.Path())
+	client := NewClient(port, 1).SetTimeout(time.Second)
+	values, err := client.ReadHoldingRegisters(2, 1)
+	if err != nil || len(values) != 1 || values[0] != 0xBEEF {
+		t.Fatalf("ReadHoldingRegisters = %v, %v", values, err)
+	}
+}
+
+// 测试重新打开串口
+func TestSerialPortReconnect(t *testing.T) {
+	pty, err := OpenPTY()
+	if err != nil {
+		t.Skipf("pseudo-terminal not available: %v", err)
+	}
+	defer pty.Close()
+	go NewRTUServer(NewDataModel(10), 1).Serve(pty)
+
+	port := openTestSerial(t, pty.Path())
+	if err := port.Reconnect(); err != nil {
+		t.Fatalf("Reconnect() error = %v", err)
+	}
+	client := NewClient(port, 1).SetTimeout(time.Second)
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("ReadHoldingRegisters() after Reconnect() error = %v", err)
+	}
+
+	port.Close()
+	if err := port.Reconnect(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Reconnect() after Close() error = %v, want os.ErrClosed", err)
+	}
+}
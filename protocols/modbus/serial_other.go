@@ -0,0 +1,59 @@
+//go:build !(linux && (386 || amd64 || arm || arm64 || loong64 || riscv64 || s390x))
+
+package modbus
+
+import "time"
+
+// SerialPort 在当前平台上不可用，OpenSerial 总是返回 ErrSerialUnsupported
+type SerialPort struct {
+	config SerialConfig
+}
+
+// OpenSerial 在当前平台上不可用
+func OpenSerial(config SerialConfig) (*SerialPort, error) {
+	return nil, ErrSerialUnsupported
+}
+
+// Read 实现 io.Reader
+func (p *SerialPort) Read(b []byte) (int, error) { return 0, ErrSerialUnsupported }
+
+// Write 实现 io.Writer
+func (p *SerialPort) Write(b []byte) (int, error) { return 0, ErrSerialUnsupported }
+
+// SetReadDeadline 设置读截止时间
+func (p *SerialPort) SetReadDeadline(t time.Time) error { return ErrSerialUnsupported }
+
+// Flush 丢弃接收缓冲区中尚未读取的数据
+func (p *SerialPort) Flush() error { return ErrSerialUnsupported }
+
+// Config 返回串口的线路参数
+func (p *SerialPort) Config() SerialConfig { return p.config }
+
+// Reconnect 重新打开串口
+func (p *SerialPort) Reconnect() error { return ErrSerialUnsupported }
+
+// Close 关闭串口
+func (p *SerialPort) Close() error { return ErrSerialUnsupported }
+
+// PTY 在当前平台上不可用
+type PTY struct{}
+
+// OpenPTY 在当前平台上不可用
+func OpenPTY() (*PTY, error) {
+	return nil, ErrSerialUnsupported
+}
+
+// Path 返回从设备路径
+func (p *PTY) Path() string { return "" }
+
+// Read 实现 io.Reader
+func (p *PTY) Read(b []byte) (int, error) { return 0, ErrSerialUnsupported }
+
+// Write 实现 io.Writer
+func (p *PTY) Write(b []byte) (int, error) { return 0, ErrSerialUnsupported }
+
+// SetReadDeadline 设置读截止时间
+func (p *PTY) SetReadDeadline(t time.Time) error { return ErrSerialUnsupported }
+
+// Close 关闭伪终端
+func (p *PTY) Close() error { return ErrSerialUnsupported }
@@ -0,0 +1,280 @@
+package modbus
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// server.go 实现了 Modbus 服务器端的请求处理接口和 Modbus TCP 服务器
+
+// ErrNoResponse 由 Handler 返回时，服务器不发送任何响应（例如广播请求）
+var ErrNoResponse = errors.New("modbus: no response")
+
+// ErrServerClosed 表示服务器已关闭
+var ErrServerClosed = errors.New("modbus: server closed")
+
+// Request 表示服务器收到的一个请求
+type Request struct {
+	UnitID       byte                 // 单元 ID（RTU 中的从站 ID）
+	FunctionCode byte                 // 功能码
+	Data         []byte               // PDU 中功能码之后的数据
+	RemoteAddr   net.Addr             // 客户端地址，串口上为 nil
+	TLS          *tls.ConnectionState // TLS 连接状态，未使用 TLS 时为 nil
+	Role         string               // 客户端证书中的角色，见 CertificateRole
+}
+
+// AddressRange 返回请求访问的起始地址和数量，不访问数据表的请求返回 ok 为 false
+func (r *Request) AddressRange() (address, quantity uint16, ok bool) {
+	return RequestAddressRange(r.FunctionCode, r.Data)
+}
+
+// Handler 处理 Modbus 请求
+//
+// ServeModbus 返回响应 PDU 中功能码之后的数据。返回 *ModbusError（例如 ErrIllegalDataAddress）
+// 时服务器发送对应的异常响应，返回 ErrNoResponse 时不发送响应，其他错误按从站设备故障处理。
+// ServeModbus 可能被并发调用。
+type Handler interface {
+	ServeModbus(req *Request) ([]byte, error)
+}
+
+// HandlerFunc 使用函数实现 Handler 接口
+type HandlerFunc func(req *Request) ([]byte, error)
+
+// ServeModbus 实现 Handler 接口
+func (f HandlerFunc) ServeModbus(req *Request) ([]byte, error) {
+	return f(req)
+}
+
+// handleRequest 经过授权检查后调用 Handler，返回响应 PDU，不需要响应时返回 nil
+func handleRequest(handler Handler, authorizer Authorizer, req *Request) []byte {
+	if authorizer != nil && !authorizer.Authorize(req) {
+		return []byte{req.FunctionCode | 0x80, ExcIllegalFunction}
+	}
+	if handler == nil {
+		return []byte{req.FunctionCode | 0x80, ExcIllegalFunction}
+	}
+
+	data, err := handler.ServeModbus(req)
+	if err != nil {
+		var modbusErr *ModbusError
+		switch {
+		case errors.Is(err, ErrNoResponse):
+			return nil
+		case errors.As(err, &modbusErr):
+			return []byte{req.FunctionCode | 0x80, modbusErr.ExceptionCode}
+		default:
+			return []byte{req.FunctionCode | 0x80, ExcServerDeviceFailure}
+		}
+	}
+
+	pdu := make([]byte, 0, 1+len(data))
+	pdu = append(pdu, req.FunctionCode)
+	return append(pdu, data...)
+}
+
+// TCPServer 是 Modbus TCP 服务器
+//
+// 同一连接上的多个请求会被并发处理，响应按完成顺序返回，由客户端按事务 ID 匹配。
+// 设置 TLSConfig 后服务器按 Modbus/TCP Security 规范工作（默认端口 802），
+// 客户端证书中的角色会填入 Request.Role，供 Authorizer 判断。
+type TCPServer struct {
+	Handler     Handler       // 请求处理器
+	Authorizer  Authorizer    // 授权检查，nil 表示不检查；拒绝时返回非法功能异常
+	TLSConfig   *tls.Config   // TLS 配置，需要双向认证时设置 ClientAuth 为 tls.RequireAndVerifyClientCert
+	IdleTimeout time.Duration // 连接空闲超时，0 表示不限制
+	ErrorLog    *log.Logger   // 连接错误日志，nil 时不记录
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// 每个连接上同时处理的最大请求数
+const maxConcurrentRequests = 16
+
+// NewTCPServer 创建 Modbus TCP 服务器
+func NewTCPServer(handler Handler) *TCPServer {
+	return &TCPServer{Handler: handler}
+}
+
+// ListenAndServe 在指定地址上监听并处理连接
+func (s *TCPServer) ListenAndServe(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在监听器上接受连接并处理，直到 Close 被调用
+// 设置了 TLSConfig 时监听器会被包装为 TLS 监听器
+func (s *TCPServer) Serve(l net.Listener) error {
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
+	if !s.track(l, nil) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l, nil)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !s.track(nil, conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close 关闭所有监听器和连接
+func (s *TCPServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+// TLS 连接，*tls.Conn 以及包装了它并转发这两个方法的连接
+type tlsConnection interface {
+	net.Conn
+	Handshake() error
+	ConnectionState() tls.ConnectionState
+}
+
+// 处理一个连接上的请求
+func (s *TCPServer) serveConn(conn net.Conn) {
+	defer s.untrack(nil, conn)
+	defer conn.Close()
+
+	base := Request{RemoteAddr: conn.RemoteAddr()}
+	if tlsConn, ok := conn.(tlsConnection); ok {
+		if s.IdleTimeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			s.logf("modbus: TLS handshake with %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+
+		state := tlsConn.ConnectionState()
+		base.TLS = &state
+		if len(state.PeerCertificates) > 0 {
+			role, err := CertificateRole(state.PeerCertificates[0])
+			if err != nil {
+				s.logf("modbus: client %v: %v", conn.RemoteAddr(), err)
+				return
+			}
+			base.Role = role
+		}
+	}
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxConcurrentRequests)
+	)
+	defer wg.Wait()
+
+	for {
+		if s.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		frame, err := readMBAP(conn)
+		if err != nil {
+			return
+		}
+
+		req := base
+		req.UnitID = frame.UnitID
+		req.FunctionCode = frame.PDU[0]
+		req.Data = frame.PDU[1:]
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(transactionID uint16) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			pdu := handleRequest(s.Handler, s.Authorizer, &req)
+			if pdu == nil {
+				return
+			}
+			response := (&mbapFrame{TransactionID: transactionID, UnitID: req.UnitID, PDU: pdu}).encode()
+
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if _, err := conn.Write(response); err != nil {
+				s.logf("modbus: write to %v: %v", conn.RemoteAddr(), err)
+			}
+		}(frame.TransactionID)
+	}
+}
+
+// 记录监听器或连接，服务器已关闭时返回 false
+func (s *TCPServer) track(l net.Listener, conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if l != nil {
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	}
+	if conn != nil {
+		if s.conns == nil {
+			s.conns = make(map[net.Conn]struct{})
+		}
+		s.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (s *TCPServer) untrack(l net.Listener, conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, l)
+	delete(s.conns, conn)
+}
+
+func (s *TCPServer) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *TCPServer) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	}
+}
@@ -0,0 +1,94 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+// 返回读保持寄存器请求的起始地址作为寄存器值的处理器
+var echoAddressHandler = HandlerFunc(func(req *Request) ([]byte, error) {
+	if req.FunctionCode != FuncReadHoldingRegisters {
+		return nil, ErrIllegalFunction
+	}
+	address := binary.BigEndian.Uint16(req.Data[0:2])
+	if address >= 1000 {
+		return nil, ErrIllegalDataAddress
+	}
+	return []byte{0x02, byte(address >> 8), byte(address)}, nil
+})
+
+// 启动测试用的 TCP 服务器，返回监听地址
+func startTCPServer(t *testing.T, server *TCPServer) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return l.Addr().String()
+}
+
+func TestTCPServer(t *testing.T) {
+	addr := startTCPServer(t, NewTCPServer(echoAddressHandler))
+	client := NewTCPClient(NewTCPTransport(addr), 0x01)
+
+	registers, err := client.ReadHoldingRegisters(100, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if registers[0] != 100 {
+		t.Errorf("ReadHoldingRegisters() = %v, want [100]", registers)
+	}
+
+	if _, err := client.ReadHoldingRegisters(2000, 1); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("ReadHoldingRegisters(2000) error = %v, want ErrIllegalDataAddress", err)
+	}
+	if _, err := client.ReadInputRegisters(0, 1); !errors.Is(err, ErrIllegalFunction) {
+		t.Errorf("ReadInputRegisters() error = %v, want ErrIllegalFunction", err)
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []byte
+	}{
+		{"ok", nil, []byte{FuncReadHoldingRegisters, 0x02, 0x00, 0x01}},
+		{"exception", ErrServerDeviceBusy, []byte{FuncReadHoldingRegisters | 0x80, ExcServerDeviceBusy}},
+		{"other error", errors.New("boom"), []byte{FuncReadHoldingRegisters | 0x80, ExcServerDeviceFailure}},
+		{"no response", ErrNoResponse, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := HandlerFunc(func(req *Request) ([]byte, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return []byte{0x02, 0x00, 0x01}, nil
+			})
+			got := handleRequest(handler, nil, &Request{FunctionCode: FuncReadHoldingRegisters})
+			if string(got) != string(tt.want) {
+				t.Errorf("handleRequest() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	server := NewTCPServer(echoAddressHandler)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(l) }()
+
+	server.Close()
+	if err := <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve() error = %v, want ErrServerClosed", err)
+	}
+}
@@ -0,0 +1,427 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// sniffer.go 实现了被动监听 RTU 总线的帧解析器，把线路上的字节流还原为请求/响应交互
+
+// 线路上一个 RTU 帧的最大长度
+const maxRTUFrameLength = 256
+
+// SniffedFrame 是从线路上解析出的一个 RTU 帧
+type SniffedFrame struct {
+	Time         time.Time // 帧开始的时间（近似为收到第一个字节的时间）
+	Raw          []byte    // 完整的帧，包含从站 ID 和 CRC
+	Response     bool      // 是否为响应帧
+	SlaveID      byte      // 从站 ID
+	FunctionCode byte      // 功能码，异常响应已去掉最高位
+	Exception    byte      // 异常码，非异常响应为 0
+	Address      uint16    // 起始地址，功能码不访问数据表时为 0
+	Quantity     uint16    // 数量
+	Values       []uint16  // 寄存器值或线圈状态（0 或 1）：读操作的响应、写操作的请求
+	Data         []byte    // PDU 中功能码之后的数据
+}
+
+// Exchange 是一次请求/响应交互
+// 广播请求和没有响应的请求 Response 为 nil，没有对应请求的响应 Request 为 nil
+type Exchange struct {
+	Request  *SniffedFrame
+	Response *SniffedFrame
+	Latency  time.Duration // 从请求开始到响应开始的时间
+}
+
+// Sniffer 从线路上的原始字节流中解析 RTU 帧，并将请求与响应配对
+//
+// 帧边界由功能码决定的帧长度和 CRC 确定，无法确定时以超过 t3.5 的静默作为帧结束（需要设置线路参数）。
+// 无法解析的字节会被丢弃，直到重新找到有效的帧。Sniffer 不能被并发使用。
+type Sniffer struct {
+	handler         func(*Exchange)
+	timing          *RTUTiming
+	responseTimeout time.Duration
+
+	buf       []byte
+	bufStart  time.Time // 缓冲区中第一个字节到达的时间
+	last      time.Time // 最后一个字节到达的时间
+	pending   *SniffedFrame
+	discarded int
+}
+
+// NewSniffer 创建帧解析器，每解析出一次交互就调用一次 handler，例如 NewTextLog 或 NewJSONLog
+func NewSniffer(handler func(*Exchange)) *Sniffer {
+	return &Sniffer{
+		handler:         handler,
+		responseTimeout: time.Second,
+	}
+}
+
+// SetSerialConfig 根据线路参数计算 RTU 时间参数，用于按静默间隔划分帧
+func (s *Sniffer) SetSerialConfig(config SerialConfig) *Sniffer {
+	return s.SetRTUTiming(NewRTUTiming(config))
+}
+
+// SetRTUTiming 设置 RTU 时间参数
+func (s *Sniffer) SetRTUTiming(timing RTUTiming) *Sniffer {
+	s.timing = &timing
+	return s
+}
+
+// SetResponseTimeout 设置请求之后等待响应的时间，超过后认为从站没有响应，默认为 1s
+func (s *Sniffer) SetResponseTimeout(timeout time.Duration) *Sniffer {
+	s.responseTimeout = timeout
+	return s
+}
+
+// Discarded 返回因无法解析而丢弃的字节数
+func (s *Sniffer) Discarded() int {
+	return s.discarded
+}
+
+// Run 从 r 中读取数据直到 io.EOF，以读取完成的时间作为数据到达的时间
+// 读取超时（例如设置了 Timeout 的 *SerialPort）被视为线路空闲
+func (s *Sniffer) Run(r io.Reader) error {
+	buf := make([]byte, maxRTUFrameLength)
+	for {
+		n, err := r.Read(buf)
+		s.Feed(buf[:n], time.Now())
+		if err != nil {
+			if IsTimeout(err) {
+				continue
+			}
+			s.Flush()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Feed 处理在 at 时刻到达的数据，data 为空时只检查静默间隔和响应超时
+func (s *Sniffer) Feed(data []byte, at time.Time) {
+	if len(s.buf) > 0 && s.timing != nil && at.Sub(s.last) > s.timing.T35 {
+		s.parse(true)
+		s.buf = s.buf[:0]
+	}
+	if s.pending != nil && at.Sub(s.pending.Time) > s.responseTimeout && len(s.buf) == 0 {
+		s.emit(s.pending, nil)
+		s.pending = nil
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	if len(s.buf) == 0 {
+		s.bufStart = at
+	}
+	s.buf = append(s.buf, data...)
+	s.last = at
+	s.parse(false)
+}
+
+// Flush 处理缓冲区中剩余的数据，并输出等待响应的请求，在数据流结束时调用
+func (s *Sniffer) Flush() {
+	s.parse(true)
+	s.buf = s.buf[:0]
+	if s.pending != nil {
+		s.emit(s.pending, nil)
+		s.pending = nil
+	}
+}
+
+// 从缓冲区中解析完整的帧，final 为 true 表示缓冲区之后是静默间隔或数据流结束
+func (s *Sniffer) parse(final bool) {
+	for len(s.buf) > 0 {
+		n, response, wait := s.nextFrame(final)
+		if wait {
+			return
+		}
+		if n == 0 {
+			// 无法解析出有效的帧，丢弃一个字节后重新同步
+			s.buf = s.buf[1:]
+			s.discarded++
+			continue
+		}
+		s.frame(s.buf[:n], response)
+		s.buf = s.buf[n:]
+		s.bufStart = s.last
+	}
+}
+
+// 在缓冲区开头查找帧，返回帧长度和是否为响应；需要等待更多数据时 wait 为 true，
+// 返回的长度为 0 时表示开头的字节不属于任何有效帧
+func (s *Sniffer) nextFrame(final bool) (n int, response, wait bool) {
+	buf := s.buf
+	expectResponse := s.pending != nil && len(buf) >= 2 &&
+		buf[0] == s.pending.SlaveID && buf[1]&^0x80 == s.pending.FunctionCode
+
+	// 功能码 0 不存在，这样的字节只能是噪声
+	if len(buf) >= 2 && buf[1]&^0x80 == 0 {
+		return 0, false, false
+	}
+
+	undetermined := false // 存在数据不足或功能码未知的解释
+	for _, resp := range []bool{expectResponse, !expectResponse} {
+		var length int
+		var known bool
+		if resp {
+			length, known = rtuResponseLength(buf)
+		} else {
+			length, known = rtuRequestLength(buf)
+		}
+		switch {
+		case !known || length == 0 || len(buf) < length:
+			undetermined = true
+		case CheckCRC16(buf[:length]):
+			return length, resp, false
+		}
+	}
+
+	switch {
+	case !undetermined:
+		return 0, false, false
+	case !final && len(buf) < maxRTUFrameLength:
+		return 0, false, true
+	case final && len(buf) >= 4 && len(buf) <= maxRTUFrameLength && CheckCRC16(buf):
+		// 功能码未知时以静默间隔之前的全部数据作为一帧
+		return len(buf), expectResponse, false
+	default:
+		return 0, false, false
+	}
+}
+
+// 处理一个完整的帧
+func (s *Sniffer) frame(raw []byte, response bool) {
+	f := decodeSniffedFrame(append([]byte(nil), raw...), response, s.bufStart)
+	if !response {
+		if s.pending != nil {
+			s.emit(s.pending, nil)
+		}
+		s.pending = nil
+		if f.SlaveID == 0 {
+			s.emit(f, nil) // 广播请求没有响应
+			return
+		}
+		s.pending = f
+		return
+	}
+
+	request := s.pending
+	if request == nil || request.SlaveID != f.SlaveID || request.FunctionCode != f.FunctionCode {
+		s.emit(nil, f)
+		return
+	}
+	s.pending = nil
+
+	// 读操作的响应中没有地址和数量，从请求中补全
+	if table, write := FunctionTable(f.FunctionCode); table != 0 && !write {
+		f.Address = request.Address
+		f.Quantity = request.Quantity
+		if table.bits() && len(f.Values) > int(f.Quantity) {
+			f.Values = f.Values[:f.Quantity]
+		}
+	}
+	s.emit(request, f)
+}
+
+func (s *Sniffer) emit(request, response *SniffedFrame) {
+	ex := &Exchange{Request: request, Response: response}
+	if request != nil && response != nil {
+		ex.Latency = response.Time.Sub(request.Time)
+	}
+	if s.handler != nil {
+		s.handler(ex)
+	}
+}
+
+// 解码 RTU 帧中的字段
+func decodeSniffedFrame(raw []byte, response bool, at time.Time) *SniffedFrame {
+	f := &SniffedFrame{
+		Time:         at,
+		Raw:          raw,
+		Response:     response,
+		SlaveID:      raw[0],
+		FunctionCode: raw[1] &^ 0x80,
+		Data:         raw[2 : len(raw)-2],
+	}
+	data := f.Data
+	if response && IsError(raw[1]) {
+		if len(data) > 0 {
+			f.Exception = data[0]
+		}
+		return f
+	}
+
+	table, write := FunctionTable(f.FunctionCode)
+	switch {
+	case table == 0:
+	case response && !write:
+		// 读响应：字节数 + 数据，线圈和离散输入在配对时按请求的数量截断
+		if len(data) > 0 && len(data) == 1+int(data[0]) {
+			quantity := int(data[0]) / 2
+			if table.bits() {
+				quantity = int(data[0]) * 8
+			}
+			f.Values, _ = decodeValues(table, quantity, data)
+		}
+	case len(data) >= 4:
+		f.Address = binary.BigEndian.Uint16(data[0:2])
+		f.Quantity = binary.BigEndian.Uint16(data[2:4])
+		switch f.FunctionCode {
+		case FuncWriteSingleCoil:
+			f.Values = []uint16{0}
+			if f.Quantity == 0xFF00 {
+				f.Values[0] = 1
+			}
+			f.Quantity = 1
+		case FuncWriteSingleRegister:
+			f.Values = []uint16{f.Quantity}
+			f.Quantity = 1
+		case FuncWriteMultipleCoils, FuncWriteMultipleRegisters:
+			if !response {
+				f.Values, _ = decodeValues(table, int(f.Quantity), data[4:])
+			}
+		}
+	}
+	return f
+}
+
+// 交互的状态
+func (ex *Exchange) status() string {
+	switch {
+	case ex.Request == nil:
+		return "unsolicited"
+	case ex.Response == nil && ex.Request.SlaveID == 0:
+		return "broadcast"
+	case ex.Response == nil:
+		return "no_response"
+	case ex.Response.Exception != 0:
+		return "exception"
+	default:
+		return "ok"
+	}
+}
+
+// 交互的主要帧：有请求时为请求，否则为响应
+func (ex *Exchange) frame() *SniffedFrame {
+	if ex.Request != nil {
+		return ex.Request
+	}
+	return ex.Response
+}
+
+// 交互涉及的值：读操作取自响应，写操作取自请求
+func (ex *Exchange) values() []uint16 {
+	if ex.Response != nil && ex.Response.Values != nil && ex.Response.Exception == 0 {
+		if _, write := FunctionTable(ex.Response.FunctionCode); !write {
+			return ex.Response.Values
+		}
+	}
+	if ex.Request != nil {
+		return ex.Request.Values
+	}
+	return nil
+}
+
+// String 返回交互的可读描述，例如 "slave 1 read holding registers addresses 100-101 = [1 2] (12ms)"
+func (ex *Exchange) String() string {
+	f := ex.frame()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "slave %d %s", f.SlaveID, FunctionName(f.FunctionCode))
+	if table, _ := FunctionTable(f.FunctionCode); table != 0 && (ex.Request != nil || f.Quantity > 0) {
+		if f.Quantity > 1 {
+			fmt.Fprintf(&b, " addresses %d-%d", f.Address, uint32(f.Address)+uint32(f.Quantity)-1)
+		} else {
+			fmt.Fprintf(&b, " address %d", f.Address)
+		}
+	}
+
+	switch ex.status() {
+	case "unsolicited":
+		b.WriteString(" response without request")
+	case "broadcast":
+		b.WriteString(" (broadcast)")
+	case "no_response":
+		b.WriteString(": no response")
+		return b.String()
+	case "exception":
+		fmt.Fprintf(&b, ": %s", ExceptionName(ex.Response.Exception))
+	}
+
+	if values := ex.values(); values != nil {
+		fmt.Fprintf(&b, " = %v", values)
+	} else if len(f.Data) > 0 && ex.status() != "exception" {
+		data := f.Data
+		if ex.Response != nil {
+			data = ex.Response.Data
+		}
+		fmt.Fprintf(&b, " data=[% x]", data)
+	}
+	if ex.Latency > 0 {
+		fmt.Fprintf(&b, " (%v)", ex.Latency)
+	}
+	return b.String()
+}
+
+// NewTextLog 返回把交互以可读文本逐行写入 w 的 handler
+func NewTextLog(w io.Writer) func(*Exchange) {
+	return func(ex *Exchange) {
+		fmt.Fprintf(w, "%s %s\n", ex.frame().Time.Format("2006-01-02 15:04:05.000"), ex)
+	}
+}
+
+// JSON 日志中的一条交互记录
+type exchangeRecord struct {
+	Time         time.Time `json:"time"`
+	Status       string    `json:"status"` // ok、exception、no_response、broadcast、unsolicited
+	SlaveID      byte      `json:"slave"`
+	FunctionCode byte      `json:"function_code"`
+	Function     string    `json:"function"`
+	Address      *uint16   `json:"address,omitempty"`
+	Quantity     uint16    `json:"quantity,omitempty"`
+	Values       []uint16  `json:"values,omitempty"`
+	Exception    string    `json:"exception,omitempty"`
+	Request      string    `json:"request,omitempty"`  // 十六进制
+	Response     string    `json:"response,omitempty"` // 十六进制
+	LatencyMS    float64   `json:"latency_ms,omitempty"`
+}
+
+// NewJSONLog 返回把交互以 JSON Lines 格式写入 w 的 handler
+func NewJSONLog(w io.Writer) func(*Exchange) {
+	enc := json.NewEncoder(w)
+	return func(ex *Exchange) {
+		f := ex.frame()
+		record := exchangeRecord{
+			Time:         f.Time,
+			Status:       ex.status(),
+			SlaveID:      f.SlaveID,
+			FunctionCode: f.FunctionCode,
+			Function:     FunctionName(f.FunctionCode),
+			Values:       ex.values(),
+			LatencyMS:    float64(ex.Latency) / float64(time.Millisecond),
+		}
+		if table, _ := FunctionTable(f.FunctionCode); table != 0 && (ex.Request != nil || f.Quantity > 0) {
+			address := f.Address
+			record.Address = &address
+			record.Quantity = f.Quantity
+		}
+		if ex.Request != nil {
+			record.Request = hex.EncodeToString(ex.Request.Raw)
+		}
+		if ex.Response != nil {
+			record.Response = hex.EncodeToString(ex.Response.Raw)
+			if ex.Response.Exception != 0 {
+				record.Exception = ExceptionName(ex.Response.Exception)
+			}
+		}
+		enc.Encode(record)
+	}
+}
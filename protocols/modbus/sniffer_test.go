@@ -0,0 +1,124 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 把数据按固定大小分段送入解析器，模拟串口读取
+func feedChunks(s *Sniffer, data []byte, at time.Time, size int) {
+	for len(data) > 0 {
+		n := min(size, len(data))
+		s.Feed(data[:n], at)
+		data = data[n:]
+		at = at.Add(time.Millisecond)
+	}
+}
+
+func TestSniffer(t *testing.T) {
+	var exchanges []*Exchange
+	s := NewSniffer(func(ex *Exchange) { exchanges = append(exchanges, ex) })
+
+	var stream []byte
+	stream = append(stream, NewReadHoldingRegistersRequest(1, 100, 2)...)
+	stream = append(stream, frame(0x01, 0x03, 0x04, 0x00, 0x0A, 0x00, 0x0B)...)
+	stream = append(stream, 0xFF, 0x00) // 线路噪声
+	stream = append(stream, frame(0x02, FuncReadCoils, 0x00, 0x00, 0x00, 0x03)...)
+	stream = append(stream, frame(0x02, 0x01, 0x01, 0x05)...)
+	stream = append(stream, NewWriteSingleRegisterRequest(1, 7, 0x1234)...)
+	stream = append(stream, NewWriteSingleRegisterRequest(1, 7, 0x1234)...) // 写操作的响应与请求相同
+	stream = append(stream, NewReadInputRegistersRequest(3, 0, 1)...)
+	stream = append(stream, frame(0x03, 0x84, ExcIllegalDataAddress)...)
+	stream = append(stream, NewWriteMultipleRegistersRequest(4, 10, []uint16{1, 2})...) // 没有响应
+	stream = append(stream, NewWriteSingleCoilRequest(0, 3, true)...)                   // 广播
+
+	feedChunks(s, stream, time.Now(), 5)
+	s.Flush()
+
+	want := []string{
+		"slave 1 read holding registers addresses 100-101 = [10 11]",
+		"slave 2 read coils addresses 0-2 = [1 0 1]",
+		"slave 1 write single register address 7 = [4660]",
+		"slave 3 read input registers address 0: illegal data address",
+		"slave 4 write multiple registers addresses 10-11: no response",
+		"slave 0 write single coil address 3 (broadcast) = [1]",
+	}
+	if len(exchanges) != len(want) {
+		for _, ex := range exchanges {
+			t.Log(ex)
+		}
+		t.Fatalf("got %d exchanges, want %d", len(exchanges), len(want))
+	}
+	for i, ex := range exchanges {
+		got := ex.String()
+		if i := strings.Index(got, " ("); i > 0 && !strings.Contains(got, "(broadcast)") {
+			got = got[:i] // 去掉耗时
+		}
+		if got != want[i] {
+			t.Errorf("exchange %d = %q, want %q", i, got, want[i])
+		}
+	}
+	if s.Discarded() != 2 {
+		t.Errorf("Discarded() = %d, want 2", s.Discarded())
+	}
+}
+
+// 测试功能码未知的帧按静默间隔划分
+func TestSnifferSilenceSplitsUnknownFrames(t *testing.T) {
+	var exchanges []*Exchange
+	s := NewSniffer(func(ex *Exchange) { exchanges = append(exchanges, ex) }).
+		SetSerialConfig(SerialConfig{BaudRate: 9600, DataBits: 8, StopBits: 1})
+
+	at := time.Now()
+	s.Feed(frame(0x01, 0x2B, 0x0E, 0x01, 0x00), at)
+	at = at.Add(20 * time.Millisecond)
+	s.Feed(frame(0x01, 0x2B, 0x0E, 0x01, 0x01, 0x00, 0x00, 0x00), at)
+	s.Feed(nil, at.Add(20*time.Millisecond))
+	s.Flush()
+
+	if len(exchanges) != 1 || exchanges[0].Request == nil || exchanges[0].Response == nil {
+		t.Fatalf("exchanges = %v, want one paired exchange", exchanges)
+	}
+	if got := exchanges[0].Latency; got != 20*time.Millisecond {
+		t.Errorf("Latency = %v, want 20ms", got)
+	}
+}
+
+func TestSnifferLogs(t *testing.T) {
+	var text, js bytes.Buffer
+	textLog, jsonLog := NewTextLog(&text), NewJSONLog(&js)
+	s := NewSniffer(func(ex *Exchange) {
+		textLog(ex)
+		jsonLog(ex)
+	})
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.Feed(NewReadHoldingRegistersRequest(1, 0, 1), at)
+	s.Feed(frame(0x01, 0x83, ExcIllegalDataAddress), at.Add(15*time.Millisecond))
+
+	wantText := "2024-05-01 12:00:00.000 slave 1 read holding registers address 0: illegal data address (15ms)\n"
+	if text.String() != wantText {
+		t.Errorf("text log = %q, want %q", text.String(), wantText)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(js.Bytes(), &record); err != nil {
+		t.Fatalf("json log %q: %v", js.String(), err)
+	}
+	for key, want := range map[string]any{
+		"status":     "exception",
+		"slave":      1.0,
+		"function":   "read holding registers",
+		"address":    0.0,
+		"exception":  "illegal data address",
+		"response":   "018302c0f1",
+		"latency_ms": 15.0,
+	} {
+		if record[key] != want {
+			t.Errorf("json %s = %v, want %v", key, record[key], want)
+		}
+	}
+}
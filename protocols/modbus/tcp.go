@@ -0,0 +1,330 @@
+package modbus
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// tcp.go 实现了支持多个并发事务（流水线）的 Modbus TCP 传输
+
+// Transactor 是以完整帧为单位进行请求/响应交互的传输层，例如 Modbus TCP 和 UDP
+//
+// 请求和响应都是 RTU 形式的帧（从站 ID + PDU + CRC），由实现负责与线路上的格式相互转换，
+// 因此客户端的校验、重试、熔断和钩子对所有传输方式都适用。实现必须支持并发调用。
+type Transactor interface {
+	Transact(request []byte) ([]byte, error)
+}
+
+// Resender 是没有收到响应时会重发请求的 Transactor，例如 UDPTransport
+//
+// 写请求不一定幂等，实现默认不应重发写请求。客户端的重试策略设置了 RetryWrites 时，
+// 客户端调用 TransactResend 并传入 resendWrites 为 true，允许同样重发写请求。
+type Resender interface {
+	TransactResend(request []byte, resendWrites bool) ([]byte, error)
+}
+
+// 可以由客户端设置超时时间的 Transactor，Client.SetTimeout 会转发给它
+type timeoutSetter interface {
+	setTimeout(timeout time.Duration)
+}
+
+// NewTCPClient 创建一个通过 Modbus TCP 通信的客户端
+// 客户端可以被多个协程并发使用，请求会在同一个连接上并发发送
+func NewTCPClient(transport *TCPTransport, slaveID byte) *Client {
+	return newTransactorClient(transport, slaveID)
+}
+
+// TCPTransport 是 Modbus TCP 传输
+//
+// 同一个连接上最多同时有 MaxInFlight 个未完成的事务，响应按事务 ID 匹配，
+// 每个事务独立计算超时。连接断开后所有未完成的事务立即失败，
+// 下一次请求时按指数退避自动重新连接。
+type TCPTransport struct {
+	address     string
+	timeout     time.Duration // 单个事务的超时时间
+	dialTimeout time.Duration // 建立连接的超时时间
+	minBackoff  time.Duration // 重连的初始等待时间
+	maxBackoff  time.Duration // 重连的最长等待时间
+	inFlight    chan struct{} // 限制未完成事务数量的信号量
+	tlsConfig   *tls.Config   // TLS 配置，nil 表示不使用 TLS
+
+	dialMu    sync.Mutex // 保证同时只有一个协程在建立连接
+	backoff   time.Duration
+	nextDial  time.Time // 下一次允许重连的时间
+	dialError error     // 上一次建立连接的错误
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pending map[uint16]chan tcpResult // 按事务 ID 等待响应的事务
+	nextID  uint16
+	closed  bool
+
+	writeMu sync.Mutex // 保证请求帧完整地写入连接
+}
+
+// 事务结果
+type tcpResult struct {
+	frame *mbapFrame
+	err   error
+}
+
+// NewTCPTransport 创建 Modbus TCP 传输，连接在第一次请求时建立
+// address 形如 192.168.1.100:502
+func NewTCPTransport(address string) *TCPTransport {
+	return &TCPTransport{
+		address:     address,
+		timeout:     time.Second,
+		dialTimeout: 5 * time.Second,
+		minBackoff:  100 * time.Millisecond,
+		maxBackoff:  10 * time.Second,
+		inFlight:    make(chan struct{}, 16),
+		pending:     make(map[uint16]chan tcpResult),
+	}
+}
+
+// SetTimeout 设置单个事务的超时时间，包括等待连接和发送的时间，默认为 1s
+// 也可以通过 Client.SetTimeout 设置
+func (t *TCPTransport) SetTimeout(timeout time.Duration) *TCPTransport {
+	t.timeout = timeout
+	return t
+}
+
+// 实现 timeoutSetter 接口
+func (t *TCPTransport) setTimeout(timeout time.Duration) {
+	t.SetTimeout(timeout)
+}
+
+// SetDialTimeout 设置建立连接的超时时间
+func (t *TCPTransport) SetDialTimeout(timeout time.Duration) *TCPTransport {
+	t.dialTimeout = timeout
+	return t
+}
+
+// SetMaxInFlight 设置同一连接上最多未完成的事务数量，默认为 16
+// 需要在第一次请求之前设置
+func (t *TCPTransport) SetMaxInFlight(n int) *TCPTransport {
+	t.inFlight = make(chan struct{}, max(n, 1))
+	return t
+}
+
+// SetReconnectBackoff 设置连接失败后重连的初始等待时间和最长等待时间
+func (t *TCPTransport) SetReconnectBackoff(min, max time.Duration) *TCPTransport {
+	t.minBackoff = min
+	t.maxBackoff = max
+	return t
+}
+
+// SetTLSConfig 设置 TLS 配置，使用 Modbus/TCP Security（默认端口 802）
+// 双向认证时需要在 Certificates 中提供带有角色扩展的客户端证书
+func (t *TCPTransport) SetTLSConfig(config *tls.Config) *TCPTransport {
+	t.tlsConfig = config
+	return t
+}
+
+// Transact 实现 Transactor 接口
+func (t *TCPTransport) Transact(request []byte) ([]byte, error) {
+	unitID, pdu, err := rtuToPDU(request)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(t.timeout)
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	// 限制未完成的事务数量
+	select {
+	case t.inFlight <- struct{}{}:
+		defer func() { <-t.inFlight }()
+	case <-timer.C:
+		return nil, ErrTimeout
+	}
+
+	conn, err := t.connect(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	id, result := t.register()
+
+	frame := (&mbapFrame{TransactionID: id, UnitID: unitID, PDU: pdu}).encode()
+	t.writeMu.Lock()
+	conn.SetWriteDeadline(deadline)
+	_, err = conn.Write(frame)
+	t.writeMu.Unlock()
+	if err != nil {
+		t.unregister(id)
+		t.drop(conn, err)
+		return nil, err
+	}
+
+	select {
+	case res := <-result:
+		if res.err != nil {
+			return nil, res.err
+		}
+		if res.frame.UnitID != unitID {
+			return nil, ErrInvalidSlaveID
+		}
+		return pduToRTU(res.frame.UnitID, res.frame.PDU), nil
+	case <-timer.C:
+		t.unregister(id)
+		return nil, ErrTimeout
+	}
+}
+
+// Close 关闭连接，之后的请求返回 net.ErrClosed
+func (t *TCPTransport) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	conn := t.conn
+	t.mu.Unlock()
+
+	if conn != nil {
+		t.drop(conn, net.ErrClosed)
+	}
+	return nil
+}
+
+// 获取当前连接，连接不存在时按退避策略建立新连接
+func (t *TCPTransport) connect(deadline time.Time) (net.Conn, error) {
+	if conn, err := t.current(); conn != nil || err != nil {
+		return conn, err
+	}
+
+	t.dialMu.Lock()
+	defer t.dialMu.Unlock()
+
+	for {
+		// 其他协程可能已经建立了连接
+		if conn, err := t.current(); conn != nil || err != nil {
+			return conn, err
+		}
+
+		if wait := time.Until(t.nextDial); wait > 0 {
+			if time.Now().Add(wait).After(deadline) {
+				return nil, fmt.Errorf("modbus: connect %s: %w (last error: %v)", t.address, ErrTimeout, t.dialError)
+			}
+			time.Sleep(wait)
+		}
+
+		dialer := net.Dialer{Timeout: min(t.dialTimeout, time.Until(deadline))}
+		conn, err := t.dial(&dialer)
+		if err == nil {
+			t.backoff = 0
+			t.nextDial = time.Time{}
+			t.dialError = nil
+			return t.attach(conn)
+		}
+
+		t.dialError = err
+		t.backoff = min(max(t.backoff*2, t.minBackoff), t.maxBackoff)
+		t.nextDial = time.Now().Add(t.backoff)
+		if !time.Now().Before(deadline) {
+			return nil, err
+		}
+	}
+}
+
+// 建立到服务器的连接
+func (t *TCPTransport) dial(dialer *net.Dialer) (net.Conn, error) {
+	if t.tlsConfig != nil {
+		d := tls.Dialer{NetDialer: dialer, Config: t.tlsConfig}
+		return d.Dial("tcp", t.address)
+	}
+	return dialer.Dial("tcp", t.address)
+}
+
+// 返回当前连接
+func (t *TCPTransport) current() (net.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, net.ErrClosed
+	}
+	return t.conn, nil
+}
+
+// 保存新建立的连接并启动读取协程
+func (t *TCPTransport) attach(conn net.Conn) (net.Conn, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		conn.Close()
+		return nil, net.ErrClosed
+	}
+	t.conn = conn
+	t.mu.Unlock()
+
+	go t.readLoop(conn)
+	return conn, nil
+}
+
+// 分配事务 ID 并登记等待响应的事务
+func (t *TCPTransport) register() (uint16, chan tcpResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for {
+		t.nextID++
+		if _, used := t.pending[t.nextID]; !used {
+			break
+		}
+	}
+	result := make(chan tcpResult, 1)
+	t.pending[t.nextID] = result
+	return t.nextID, result
+}
+
+// 取消登记事务
+func (t *TCPTransport) unregister(id uint16) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+// 读取响应并按事务 ID 分发，连接出错时退出
+func (t *TCPTransport) readLoop(conn net.Conn) {
+	for {
+		frame, err := readMBAP(conn)
+		if err != nil {
+			t.drop(conn, err)
+			return
+		}
+
+		t.mu.Lock()
+		result, ok := t.pending[frame.TransactionID]
+		delete(t.pending, frame.TransactionID)
+		t.mu.Unlock()
+
+		// 已超时的事务的响应直接丢弃
+		if ok {
+			result <- tcpResult{frame: frame}
+		}
+	}
+}
+
+// 关闭连接并使所有未完成的事务失败
+func (t *TCPTransport) drop(conn net.Conn, cause error) {
+	t.mu.Lock()
+	if t.conn != conn {
+		t.mu.Unlock()
+		return
+	}
+	t.conn = nil
+	pending := t.pending
+	t.pending = make(map[uint16]chan tcpResult)
+	t.mu.Unlock()
+
+	conn.Close()
+	if !errors.Is(cause, net.ErrClosed) {
+		cause = fmt.Errorf("modbus: connection to %s lost: %w", t.address, cause)
+	}
+	for _, result := range pending {
+		result <- tcpResult{err: cause}
+	}
+}
@@ -0,0 +1,219 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 启动一个测试用的 MBAP 服务器，每个连接交给 handle 处理
+func startMBAPServer(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// 返回读保持寄存器请求的起始地址作为寄存器值的响应
+func echoAddressResponse(req *mbapFrame) []byte {
+	address := binary.BigEndian.Uint16(req.PDU[1:3])
+	return (&mbapFrame{
+		TransactionID: req.TransactionID,
+		UnitID:        req.UnitID,
+		PDU:           []byte{FuncReadHoldingRegisters, 0x02, byte(address >> 8), byte(address)},
+	}).encode()
+}
+
+// 测试同一连接上的多个并发事务按事务 ID 匹配响应
+func TestTCPPipelining(t *testing.T) {
+	const n = 3
+	addr := startMBAPServer(t, func(conn net.Conn) {
+		// 收齐所有请求后按相反顺序响应
+		var requests []*mbapFrame
+		for len(requests) < n {
+			req, err := readMBAP(conn)
+			if err != nil {
+				return
+			}
+			requests = append(requests, req)
+		}
+		for i := len(requests) - 1; i >= 0; i-- {
+			conn.Write(echoAddressResponse(requests[i]))
+		}
+	})
+
+	client := NewTCPClient(NewTCPTransport(addr).SetMaxInFlight(n), 0x01)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(address uint16) {
+			defer wg.Done()
+			registers, err := client.ReadHoldingRegisters(address, 1)
+			if err != nil {
+				t.Errorf("ReadHoldingRegisters(%d) error = %v", address, err)
+				return
+			}
+			if registers[0] != address {
+				t.Errorf("ReadHoldingRegisters(%d) = %v, want response of its own transaction", address, registers)
+			}
+		}(uint16(100 + i))
+	}
+	wg.Wait()
+}
+
+// 测试每个事务独立超时
+func TestTCPTransactionTimeout(t *testing.T) {
+	addr := startMBAPServer(t, func(conn net.Conn) {
+		for {
+			req, err := readMBAP(conn)
+			if err != nil {
+				return
+			}
+			// 不响应地址 99 的请求
+			if binary.BigEndian.Uint16(req.PDU[1:3]) != 99 {
+				conn.Write(echoAddressResponse(req))
+			}
+		}
+	})
+
+	client := NewTCPClient(NewTCPTransport(addr).SetTimeout(100*time.Millisecond), 0x01)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.ReadHoldingRegisters(99, 1)
+		errc <- err
+	}()
+
+	for i := 0; i < 5; i++ {
+		if _, err := client.ReadHoldingRegisters(uint16(i), 1); err != nil {
+			t.Fatalf("ReadHoldingRegisters(%d) error = %v", i, err)
+		}
+	}
+
+	if err := <-errc; !IsTimeout(err) || !errors.Is(err, ErrTimeout) {
+		t.Errorf("ReadHoldingRegisters(99) error = %v, want timeout", err)
+	}
+}
+
+// 测试客户端设置的超时时间作用于 TCP 传输
+func TestTCPClientTimeout(t *testing.T) {
+	addr := startMBAPServer(t, func(conn net.Conn) {
+		for {
+			if _, err := readMBAP(conn); err != nil { // 从不响应
+				return
+			}
+		}
+	})
+	transport := NewTCPTransport(addr)
+	defer transport.Close()
+	client := NewTCPClient(transport, 0x01).SetTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	_, err := client.ReadHoldingRegisters(0, 1)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("ReadHoldingRegisters() error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("timed out after %v, want about 50ms", elapsed)
+	}
+}
+
+// 测试连接断开后未完成的事务立即失败，之后的请求自动重连
+func TestTCPReconnect(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	addr := startMBAPServer(t, func(conn net.Conn) {
+		mu.Lock()
+		connections++
+		first := connections == 1
+		mu.Unlock()
+
+		for {
+			req, err := readMBAP(conn)
+			if err != nil {
+				return
+			}
+			// 第一个连接在收到地址 1 的请求时断开
+			if first && binary.BigEndian.Uint16(req.PDU[1:3]) == 1 {
+				return
+			}
+			conn.Write(echoAddressResponse(req))
+		}
+	})
+
+	transport := NewTCPTransport(addr).SetTimeout(time.Second).SetReconnectBackoff(10*time.Millisecond, 50*time.Millisecond)
+	client := NewTCPClient(transport, 0x01)
+
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("ReadHoldingRegisters(0) error = %v", err)
+	}
+
+	start := time.Now()
+	_, err := client.ReadHoldingRegisters(1, 1)
+	if err == nil || IsTimeout(err) {
+		t.Fatalf("ReadHoldingRegisters(1) error = %v, want connection lost", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("in-flight transaction failed after %v, want immediately", elapsed)
+	}
+
+	registers, err := client.ReadHoldingRegisters(2, 1)
+	if err != nil || registers[0] != 2 {
+		t.Fatalf("ReadHoldingRegisters(2) after reconnect = %v, %v", registers, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if connections != 2 {
+		t.Errorf("server saw %d connections, want 2", connections)
+	}
+}
+
+// 测试关闭后的请求
+func TestTCPClosed(t *testing.T) {
+	transport := NewTCPTransport("127.0.0.1:1")
+	transport.Close()
+	if _, err := NewTCPClient(transport, 1).ReadHoldingRegisters(0, 1); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want net.ErrClosed", err)
+	}
+}
+
+// 测试 MBAP 编解码
+func TestMBAP(t *testing.T) {
+	f := &mbapFrame{TransactionID: 0x1234, UnitID: 0x11, PDU: []byte{0x03, 0x00, 0x6B, 0x00, 0x03}}
+	b := f.encode()
+	want := []byte{0x12, 0x34, 0x00, 0x00, 0x00, 0x06, 0x11, 0x03, 0x00, 0x6B, 0x00, 0x03}
+	if string(b) != string(want) {
+		t.Fatalf("encode() = % X, want % X", b, want)
+	}
+
+	got, err := decodeMBAP(b)
+	if err != nil || got.TransactionID != 0x1234 || got.UnitID != 0x11 || string(got.PDU) != string(f.PDU) {
+		t.Errorf("decodeMBAP() = %+v, %v", got, err)
+	}
+
+	b[2] = 0x01
+	if _, err := decodeMBAP(b); !errors.Is(err, ErrInvalidMBAP) {
+		t.Errorf("decodeMBAP() with protocol ID 0x0100 error = %v, want ErrInvalidMBAP", err)
+	}
+}
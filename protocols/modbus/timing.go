@@ -0,0 +1,53 @@
+package modbus
+
+import "time"
+
+// timing.go 根据串口线路参数计算 Modbus RTU 规范中的字符时间和帧间隔
+
+// 波特率高于 19200 时规范建议使用的固定间隔
+const (
+	fixedT15 = 750 * time.Microsecond
+	fixedT35 = 1750 * time.Microsecond
+)
+
+// RTUTiming 描述 RTU 线路的时间参数
+//
+// T15 仅供参考，客户端和 RTU 服务器不用它拒绝帧：USB 转串口适配器和操作系统会成批交付
+// 接收到的字节，用户空间测得的字符间隔不可靠，帧边界由功能码决定的长度、T35 和 CRC 判断。
+// 直接访问 UART 并能取得可靠时间戳的实现可以用 T15 检测帧内的字符间隔。
+type RTUTiming struct {
+	CharTime time.Duration // 传输一个字符所需的时间
+	T15      time.Duration // 字符间最大间隔，规范中超过即认为帧不完整（仅供参考）
+	T35      time.Duration // 帧间最小静默时间，用于判断帧结束和发送下一帧前的等待
+}
+
+// NewRTUTiming 根据串口线路参数计算 RTU 时间参数
+// 一个字符包含 1 个起始位、数据位、可选的校验位和停止位；
+// 波特率高于 19200 时 t1.5 和 t3.5 分别固定为 750µs 和 1.75ms
+func NewRTUTiming(config SerialConfig) RTUTiming {
+	config, _ = config.normalize()
+	if config.BaudRate <= 0 {
+		config.BaudRate = 9600
+	}
+
+	bits := 1 + config.DataBits + config.StopBits
+	if config.Parity != ParityNone {
+		bits++
+	}
+	charTime := time.Duration(bits) * time.Second / time.Duration(config.BaudRate)
+
+	timing := RTUTiming{CharTime: charTime}
+	if config.BaudRate > 19200 {
+		timing.T15 = fixedT15
+		timing.T35 = fixedT35
+	} else {
+		timing.T15 = charTime * 3 / 2
+		timing.T35 = charTime * 7 / 2
+	}
+	return timing
+}
+
+// FrameTime 返回传输 n 个字节所需的时间
+func (t RTUTiming) FrameTime(n int) time.Duration {
+	return time.Duration(n) * t.CharTime
+}
@@ -0,0 +1,104 @@
+package modbus
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// 测试根据线路参数计算 RTU 时间参数
+func TestNewRTUTiming(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   SerialConfig
+		charTime time.Duration
+		t15      time.Duration
+		t35      time.Duration
+	}{
+		{"9600 8N1", SerialConfig{BaudRate: 9600}, 1041666, 1562499, 3645831},
+		{"19200 8E1", SerialConfig{BaudRate: 19200, Parity: ParityEven}, 572916, 859374, 2005206},
+		{"9600 8N2", SerialConfig{BaudRate: 9600, StopBits: 2}, 1145833, 1718749, 4010415},
+		{"115200 8N1", SerialConfig{BaudRate: 115200}, 86805, 750 * time.Microsecond, 1750 * time.Microsecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timing := NewRTUTiming(tt.config)
+			if timing.CharTime != tt.charTime || timing.T15 != tt.t15 || timing.T35 != tt.t35 {
+				t.Errorf("NewRTUTiming() = %+v, want CharTime %v, T15 %v, T35 %v", timing, tt.charTime, tt.t15, tt.t35)
+			}
+		})
+	}
+}
+
+// 分段返回响应的传输接口
+type chunkedTransport struct {
+	chunks [][]byte
+}
+
+func (c *chunkedTransport) Write(p []byte) (int, error) { return len(p), nil }
+
+func (c *chunkedTransport) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, errors.New("no more data")
+	}
+	n := copy(p, c.chunks[0])
+	c.chunks = c.chunks[1:]
+	return n, nil
+}
+
+// 测试响应被分成多次读取时能组装成完整的帧，且不再使用固定的帧间延时
+func TestClientReadsFragmentedFrame(t *testing.T) {
+	response := frame(0x01, 0x03, 0x04, 0x00, 0x01, 0x00, 0x02)
+	transport := &chunkedTransport{chunks: [][]byte{response[:2], response[2:5], response[5:]}}
+	client := NewClient(transport, 0x01).SetSerialConfig(SerialConfig{BaudRate: 115200})
+
+	start := time.Now()
+	registers, err := client.ReadHoldingRegisters(0, 2)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if len(registers) != 2 || registers[0] != 1 || registers[1] != 2 {
+		t.Errorf("ReadHoldingRegisters() = %v, want [1 2]", registers)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("ReadHoldingRegisters() took %v, want no fixed inter-frame delay", elapsed)
+	}
+}
+
+// 测试不完整的帧在超时后返回 ErrResponseTooShort
+func TestClientTruncatedFrameTimeout(t *testing.T) {
+	clientConn, deviceConn := net.Pipe()
+	defer clientConn.Close()
+	defer deviceConn.Close()
+
+	go func() {
+		buf := make([]byte, 256)
+		deviceConn.Read(buf)
+		deviceConn.Write(frame(0x01, 0x03, 0x04, 0x00, 0x01, 0x00, 0x02)[:4])
+	}()
+
+	client := NewClient(clientConn, 0x01).SetRTUTiming(NewRTUTiming(SerialConfig{BaudRate: 19200})).SetTimeout(50 * time.Millisecond)
+	if _, err := client.ReadHoldingRegisters(0, 2); !errors.Is(err, ErrResponseTooShort) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want ErrResponseTooShort", err)
+	}
+}
+
+// 测试从站无响应时返回超时错误
+func TestClientResponseTimeout(t *testing.T) {
+	clientConn, deviceConn := net.Pipe()
+	defer clientConn.Close()
+	defer deviceConn.Close()
+
+	go func() {
+		buf := make([]byte, 256)
+		deviceConn.Read(buf)
+	}()
+
+	client := NewClient(clientConn, 0x01).SetInterFrameDelay(0).SetTimeout(30 * time.Millisecond)
+	_, err := client.ReadHoldingRegisters(0, 2)
+	if !IsTimeout(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want timeout", err)
+	}
+}
@@ -0,0 +1,78 @@
+package modbus
+
+import (
+	"io"
+	"time"
+)
+
+// transport.go 定义了 RTU 客户端的传输接口及其可选能力
+
+// Transport 是 RTU 客户端的传输接口，任何 io.ReadWriter 都可以使用
+//
+// 传输接口可以另外实现以下可选接口，客户端检测到时使用，未实现时退化为只读写：
+//   - Flusher：每次发送请求前丢弃接收缓冲区中的过期数据
+//   - ReadDeadliner：按响应超时和 t3.5 设置读截止时间，未实现时超时取决于传输接口自身
+//   - LineConfigProvider：提供串口线路参数，NewClient 据此计算 RTU 时间参数
+//   - io.Closer：由 Client.Close 关闭
+//   - Reconnector：读写出现超时以外的错误后，在下一次请求前重新连接
+//
+// *SerialPort 实现了所有可选接口，net.Conn 实现了 ReadDeadliner 和 io.Closer。
+type Transport interface {
+	io.Reader
+	io.Writer
+}
+
+// Flusher 是可以丢弃接收缓冲区中尚未读取的数据的传输接口
+type Flusher interface {
+	Flush() error
+}
+
+// ReadDeadliner 是支持读截止时间的传输接口，零值表示取消截止时间
+type ReadDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// LineConfigProvider 是可以提供串口线路参数的传输接口
+type LineConfigProvider interface {
+	Config() SerialConfig
+}
+
+// Reconnector 是可以重新建立连接的传输接口，例如重新打开拔插后的 USB 转串口适配器
+type Reconnector interface {
+	Reconnect() error
+}
+
+// Close 在传输接口（或 Transactor）实现了 io.Closer 时关闭它，否则不做任何处理
+// 共享同一个传输接口的其他客户端也将无法使用
+func (c *Client) Close() error {
+	var target any = c.transport
+	if c.transactor != nil {
+		target = c.transactor
+	}
+	if closer, ok := target.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// 记录传输接口的读写错误，超时以外的错误在下一次请求前触发重新连接
+func (c *Client) transportError(err error) error {
+	if !IsTimeout(err) {
+		c.bus.reconnect = true
+	}
+	return err
+}
+
+// 上一次读写出错且传输接口支持时重新连接
+func (c *Client) reconnectIfNeeded() error {
+	if !c.bus.reconnect {
+		return nil
+	}
+	if r, ok := c.transport.(Reconnector); ok {
+		if err := r.Reconnect(); err != nil {
+			return err
+		}
+	}
+	c.bus.reconnect = false
+	return nil
+}
@@ -0,0 +1,123 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// 实现所有可选接口的传输接口，按顺序记录调用，failWrites 次写入返回 err
+type capableTransport struct {
+	*staticDevice
+	calls        []string
+	failWrites   int
+	err          error
+	reconnectErr error
+}
+
+func (c *capableTransport) Write(p []byte) (int, error) {
+	c.calls = append(c.calls, "write")
+	if c.failWrites > 0 {
+		c.failWrites--
+		return 0, c.err
+	}
+	// 没有读取的数据留在线路上
+	stale := slices.Clone(c.pending)
+	n, err := c.staticDevice.Write(p)
+	c.pending = append(stale, c.pending...)
+	return n, err
+}
+
+func (c *capableTransport) Flush() error {
+	c.calls = append(c.calls, "flush")
+	c.pending = nil
+	return nil
+}
+
+func (c *capableTransport) Reconnect() error {
+	c.calls = append(c.calls, "reconnect")
+	return c.reconnectErr
+}
+
+func (c *capableTransport) Close() error {
+	c.calls = append(c.calls, "close")
+	return nil
+}
+
+func (c *capableTransport) Config() SerialConfig {
+	return SerialConfig{BaudRate: 19200, DataBits: 8, StopBits: 1}
+}
+
+func TestTransportCapabilities(t *testing.T) {
+	transport := &capableTransport{staticDevice: newStaticDevice(), err: io.ErrUnexpectedEOF}
+	client := NewClient(transport, 1)
+	if client.timing == nil || client.interFrameDelay != 0 {
+		t.Error("line config not used for RTU timing")
+	}
+
+	// 每次请求前清空接收缓冲区，迟到的数据不影响下一次交互
+	transport.pending = frame(1, FuncReadHoldingRegisters, 2, 0xDE, 0xAD)
+	if values, err := client.ReadHoldingRegisters(5, 1); err != nil || !slices.Equal(values, []uint16{5}) {
+		t.Fatalf("ReadHoldingRegisters = %v, %v", values, err)
+	}
+
+	// 读写出错后，下一次请求前重新连接
+	transport.failWrites = 1
+	if err := client.WriteSingleRegister(1, 2); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("failed write: %v", err)
+	}
+	if err := client.WriteSingleRegister(1, 2); err != nil {
+		t.Fatalf("write after reconnect: %v", err)
+	}
+	want := []string{"flush", "write", "flush", "write", "reconnect", "flush", "write"}
+	if !slices.Equal(transport.calls, want) {
+		t.Errorf("calls = %v, want %v", transport.calls, want)
+	}
+
+	// 超时不需要重新连接
+	transport.calls = nil
+	transport.failWrites, transport.err = 1, ErrTimeout
+	client.WriteSingleRegister(1, 2)
+	client.WriteSingleRegister(1, 2)
+	if slices.Contains(transport.calls, "reconnect") {
+		t.Errorf("reconnected after timeout: %v", transport.calls)
+	}
+
+	// 重新连接失败时返回其错误，之后继续尝试
+	transport.calls = nil
+	transport.failWrites, transport.err = 1, io.EOF
+	transport.reconnectErr = errors.New("device unplugged")
+	client.WriteSingleRegister(1, 2)
+	if err := client.WriteSingleRegister(1, 2); !errors.Is(err, transport.reconnectErr) {
+		t.Errorf("write with failed reconnect: %v", err)
+	}
+	transport.reconnectErr = nil
+	if err := client.WriteSingleRegister(1, 2); err != nil {
+		t.Errorf("write after recovery: %v", err)
+	}
+
+	transport.calls = nil
+	if err := client.Close(); err != nil || !slices.Equal(transport.calls, []string{"close"}) {
+		t.Errorf("Close() = %v, calls = %v", err, transport.calls)
+	}
+}
+
+func TestTransportWithoutCapabilities(t *testing.T) {
+	// 只实现 io.ReadWriter 的传输接口照常使用，出错后也不会尝试重新连接
+	transport := &scriptedTransport{responses: [][]byte{frame(1, FuncWriteSingleRegister, 0, 1, 0, 2)}}
+	var rw io.ReadWriter = transport
+	client := NewClient(rw, 1).SetInterFrameDelay(0)
+	if err := client.WriteSingleRegister(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WriteSingleRegister(1, 2); err == nil {
+		t.Fatal("write without response succeeded")
+	}
+	if err := client.WriteSingleRegister(1, 2); err == nil || transport.writes != 3 {
+		t.Errorf("write after transport error: %v, %d writes", err, transport.writes)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package modbus
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// udp.go 实现了 Modbus UDP 传输，每个 ADU 作为一个数据报发送
+
+// NewUDPClient 创建一个通过 Modbus UDP 通信的客户端
+// 客户端可以被多个协程并发使用
+func NewUDPClient(transport *UDPTransport, slaveID byte) *Client {
+	return newTransactorClient(transport, slaveID)
+}
+
+// UDPTransport 是 Modbus UDP 传输
+//
+// 响应按事务 ID 匹配。数据报可能丢失，在超时时间内没有收到响应时会使用同一个事务 ID
+// 重新发送请求，因此对前一次发送的迟到响应同样有效。所有重发都失败后返回 ErrTimeout，
+// 之后由客户端的重试策略决定是否重新发起事务。
+//
+// 默认只重发读请求。写请求不一定幂等，响应丢失时重发会使写操作执行多次，
+// 只有调用 SetResendWrites(true) 或客户端的重试策略设置了 RetryWrites 时才会重发。
+type UDPTransport struct {
+	address string
+	timeout time.Duration // 每次发送后等待响应的时间
+	resends int           // 没有收到响应时重发的次数
+	// 是否重发写请求
+	resendWrites bool
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pending map[uint16]chan *mbapFrame // 按事务 ID 等待响应的事务
+	nextID  uint16
+	closed  bool
+}
+
+// NewUDPTransport 创建 Modbus UDP 传输
+// address 形如 192.168.1.100:502
+func NewUDPTransport(address string) *UDPTransport {
+	return &UDPTransport{
+		address: address,
+		timeout: 500 * time.Millisecond,
+		resends: 2,
+		pending: make(map[uint16]chan *mbapFrame),
+	}
+}
+
+// SetTimeout 设置每次发送后等待响应的时间，默认为 500ms
+// 也可以通过 Client.SetTimeout 设置
+func (t *UDPTransport) SetTimeout(timeout time.Duration) *UDPTransport {
+	t.timeout = timeout
+	return t
+}
+
+// 实现 timeoutSetter 接口
+func (t *UDPTransport) setTimeout(timeout time.Duration) {
+	t.SetTimeout(timeout)
+}
+
+// SetResends 设置没有收到读请求的响应时重发的次数，默认为 2，0 表示不重发
+// 写请求默认不重发，见 SetResendWrites
+func (t *UDPTransport) SetResends(n int) *UDPTransport {
+	t.resends = max(n, 0)
+	return t
+}
+
+// SetResendWrites 设置是否同样重发写请求以及不访问数据表的请求，默认不重发
+// 只应在写操作幂等（例如写入固定的设定值）时开启
+func (t *UDPTransport) SetResendWrites(enable bool) *UDPTransport {
+	t.resendWrites = enable
+	return t
+}
+
+// Transact 实现 Transactor 接口
+func (t *UDPTransport) Transact(request []byte) ([]byte, error) {
+	return t.TransactResend(request, false)
+}
+
+// TransactResend 实现 Resender 接口
+// resendWrites 为 true 或调用过 SetResendWrites(true) 时写请求同样重发，否则只发送一次
+func (t *UDPTransport) TransactResend(request []byte, resendWrites bool) ([]byte, error) {
+	unitID, pdu, err := rtuToPDU(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resends := t.resends
+	if table, write := FunctionTable(pdu[0]); (write || table == 0) && !resendWrites && !t.resendWrites {
+		resends = 0
+	}
+
+	conn, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	id, result := t.register()
+	defer t.unregister(id)
+
+	frame := (&mbapFrame{TransactionID: id, UnitID: unitID, PDU: pdu}).encode()
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	for send := 0; send <= resends; send++ {
+		if _, err := conn.Write(frame); err != nil {
+			return nil, err
+		}
+		timer.Reset(t.timeout)
+
+		select {
+		case res, ok := <-result:
+			if !ok {
+				return nil, net.ErrClosed
+			}
+			if res.UnitID != unitID {
+				return nil, ErrInvalidSlaveID
+			}
+			return pduToRTU(res.UnitID, res.PDU), nil
+		case <-timer.C:
+		}
+	}
+	return nil, ErrTimeout
+}
+
+// Close 关闭套接字，之后的请求返回 net.ErrClosed
+func (t *UDPTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	for id, result := range t.pending {
+		close(result)
+		delete(t.pending, id)
+	}
+	if t.conn != nil {
+		return t.conn.Close()
+	}
+	return nil
+}
+
+// 返回套接字，第一次调用时创建并启动读取协程
+func (t *UDPTransport) connect() (net.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, net.ErrClosed
+	}
+	if t.conn == nil {
+		conn, err := net.Dial("udp", t.address)
+		if err != nil {
+			return nil, err
+		}
+		t.conn = conn
+		go t.readLoop(conn)
+	}
+	return t.conn, nil
+}
+
+// 分配事务 ID 并登记等待响应的事务
+func (t *UDPTransport) register() (uint16, chan *mbapFrame) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for {
+		t.nextID++
+		if _, used := t.pending[t.nextID]; !used {
+			break
+		}
+	}
+	result := make(chan *mbapFrame, 1)
+	t.pending[t.nextID] = result
+	return t.nextID, result
+}
+
+// 取消登记事务
+func (t *UDPTransport) unregister(id uint16) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+// 读取响应数据报并按事务 ID 分发，套接字关闭时退出
+func (t *UDPTransport) readLoop(conn net.Conn) {
+	buf := make([]byte, mbapHeaderLength+maxPDULength)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// 对端不可达等 ICMP 错误只影响当前数据报，由请求超时处理
+			continue
+		}
+
+		frame, err := decodeMBAP(append([]byte(nil), buf[:n]...))
+		if err != nil {
+			continue
+		}
+
+		t.mu.Lock()
+		result, ok := t.pending[frame.TransactionID]
+		delete(t.pending, frame.TransactionID)
+		t.mu.Unlock()
+
+		// 重发导致的重复响应和已超时事务的响应直接丢弃
+		if ok {
+			result <- frame
+		}
+	}
+}
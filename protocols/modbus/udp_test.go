@@ -0,0 +1,195 @@
+package modbus
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 启动一个测试用的 Modbus UDP 服务器，handle 返回 nil 时不响应
+func startUDPServer(t *testing.T, handle func(req *mbapFrame) []byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req, err := decodeMBAP(append([]byte(nil), buf[:n]...))
+			if err != nil {
+				continue
+			}
+			if resp := handle(req); resp != nil {
+				conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUDPClient(t *testing.T) {
+	addr := startUDPServer(t, echoAddressResponse)
+	transport := NewUDPTransport(addr)
+	defer transport.Close()
+	client := NewUDPClient(transport, 0x01)
+
+	for _, address := range []uint16{1, 200, 3000} {
+		registers, err := client.ReadHoldingRegisters(address, 1)
+		if err != nil {
+			t.Fatalf("ReadHoldingRegisters(%d) error = %v", address, err)
+		}
+		if registers[0] != address {
+			t.Errorf("ReadHoldingRegisters(%d) = %v", address, registers)
+		}
+	}
+}
+
+// 测试丢失的数据报会使用同一个事务 ID 重发
+func TestUDPResend(t *testing.T) {
+	var received atomic.Int32
+	var firstID, lastID atomic.Uint32
+	addr := startUDPServer(t, func(req *mbapFrame) []byte {
+		if received.Add(1) == 1 {
+			firstID.Store(uint32(req.TransactionID))
+			return nil // 丢弃第一个请求
+		}
+		lastID.Store(uint32(req.TransactionID))
+		return echoAddressResponse(req)
+	})
+	transport := NewUDPTransport(addr).SetTimeout(50 * time.Millisecond)
+	defer transport.Close()
+
+	registers, err := NewUDPClient(transport, 0x01).ReadHoldingRegisters(7, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v", err)
+	}
+	if registers[0] != 7 {
+		t.Errorf("ReadHoldingRegisters() = %v, want [7]", registers)
+	}
+	if received.Load() != 2 || firstID.Load() != lastID.Load() {
+		t.Errorf("received %d datagrams with IDs %d and %d, want 2 with the same ID",
+			received.Load(), firstID.Load(), lastID.Load())
+	}
+}
+
+func TestUDPTimeout(t *testing.T) {
+	var received atomic.Int32
+	addr := startUDPServer(t, func(req *mbapFrame) []byte {
+		received.Add(1)
+		return nil
+	})
+	transport := NewUDPTransport(addr).SetTimeout(20 * time.Millisecond).SetResends(1)
+	defer transport.Close()
+
+	_, err := NewUDPClient(transport, 0x01).ReadHoldingRegisters(0, 1)
+	if !errors.Is(err, ErrTimeout) || !IsTimeout(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want ErrTimeout", err)
+	}
+	if n := received.Load(); n != 2 {
+		t.Errorf("server received %d datagrams, want 2", n)
+	}
+
+	transport.Close()
+	if _, err := NewUDPClient(transport, 0x01).ReadHoldingRegisters(0, 1); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("ReadHoldingRegisters() after Close error = %v, want net.ErrClosed", err)
+	}
+}
+
+// 测试响应丢失时写请求默认只发送一次
+// 测试客户端设置的超时时间作用于 UDP 传输
+func TestUDPClientTimeout(t *testing.T) {
+	addr := startUDPServer(t, func(req *mbapFrame) []byte {
+		return nil // 丢弃所有响应
+	})
+	transport := NewUDPTransport(addr).SetResends(0)
+	defer transport.Close()
+	client := NewUDPClient(transport, 0x01).SetTimeout(30 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := client.ReadHoldingRegisters(0, 1); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("ReadHoldingRegisters() error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("timed out after %v, want about 30ms", elapsed)
+	}
+}
+
+func TestUDPWriteNotResent(t *testing.T) {
+	var received atomic.Int32
+	addr := startUDPServer(t, func(req *mbapFrame) []byte {
+		received.Add(1)
+		return nil // 丢弃所有响应
+	})
+	transport := NewUDPTransport(addr).SetTimeout(20 * time.Millisecond)
+	defer transport.Close()
+	client := NewUDPClient(transport, 0x01)
+
+	if err := client.WriteSingleRegister(1, 2); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("WriteSingleRegister() error = %v, want ErrTimeout", err)
+	}
+	// 不访问数据表的请求同样不重发
+	if _, err := transport.Transact(AppendCRC16([]byte{0x01, FuncReadExceptionStatus})); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Transact() error = %v, want ErrTimeout", err)
+	}
+	if n := received.Swap(0); n != 2 {
+		t.Errorf("server received %d datagrams, want each request once", n)
+	}
+
+	// 重试策略允许重复写入时重发
+	policy := NewRetryPolicy(1)
+	policy.RetryWrites = true
+	client.SetRetryPolicy(policy)
+	client.WriteSingleRegister(1, 2)
+	if n := received.Swap(0); n != 3 {
+		t.Errorf("with RetryWrites: server received %d datagrams, want 3", n)
+	}
+
+	// 在传输上显式开启
+	transport.SetResendWrites(true)
+	NewUDPClient(transport, 0x01).WriteSingleRegister(1, 2)
+	if n := received.Swap(0); n != 3 {
+		t.Errorf("with SetResendWrites: server received %d datagrams, want 3", n)
+	}
+}
+
+// 记录客户端是否允许重发写请求的 Resender，写请求的响应原样返回请求
+type resendRecorder struct {
+	calls []bool
+}
+
+func (r *resendRecorder) Transact(request []byte) ([]byte, error) {
+	return r.TransactResend(request, false)
+}
+
+func (r *resendRecorder) TransactResend(request []byte, resendWrites bool) ([]byte, error) {
+	r.calls = append(r.calls, resendWrites)
+	return request, nil
+}
+
+// 测试客户端按重试策略通过 Resender 接口允许重发写请求
+func TestClientResender(t *testing.T) {
+	transport := &resendRecorder{}
+	client := newTransactorClient(transport, 0x01)
+	if err := client.WriteSingleRegister(1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := NewRetryPolicy(1)
+	policy.RetryWrites = true
+	client.SetRetryPolicy(policy)
+	if err := client.WriteSingleRegister(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.calls) != 2 || transport.calls[0] || !transport.calls[1] {
+		t.Errorf("resendWrites = %v, want [false true]", transport.calls)
+	}
+}
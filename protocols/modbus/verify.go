@@ -0,0 +1,143 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// verify.go 实现了写入后读回确认
+
+// ErrVerifyMismatch 表示写入后读回的值与写入的值不一致，可以用 errors.Is 判断
+var ErrVerifyMismatch = errors.New("modbus: verify mismatch")
+
+// WriteOption 调整单次写操作的行为
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	verify *bool // nil 表示使用客户端的设置
+}
+
+// WithVerify 设置本次写操作是否读回确认，覆盖 SetWriteVerify 的设置
+func WithVerify(enable bool) WriteOption {
+	return func(o *writeOptions) {
+		o.verify = &enable
+	}
+}
+
+// Mismatch 描述一个读回值与写入值不一致的地址
+type Mismatch struct {
+	Address  uint16 // 地址
+	Expected uint16 // 写入的值，线圈为 0 或 1
+	Actual   uint16 // 读回的值
+}
+
+// VerifyError 表示写入后读回确认失败
+//
+// 读回成功但值不一致时 Mismatches 列出所有不一致的地址，errors.Is(err, ErrVerifyMismatch) 为 true；
+// 读回本身失败时 Err 为读取的错误，此时写操作已被从站确认，但无法确定写入的值。
+type VerifyError struct {
+	Table      Table      // 写入的数据表
+	Mismatches []Mismatch // 不一致的地址
+	Err        error      // 读回失败的错误
+}
+
+// Error 实现 error 接口
+func (e *VerifyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("modbus: verify %v: read back failed: %v", e.Table, e.Err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "modbus: verify %v: %d mismatched", e.Table, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		fmt.Fprintf(&b, "%saddress %d wrote %d read %d", sep, m.Address, m.Expected, m.Actual)
+	}
+	return b.String()
+}
+
+// Unwrap 返回读回失败的错误
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is(err, ErrVerifyMismatch) 在值不一致时成立
+func (e *VerifyError) Is(target error) bool {
+	return target == ErrVerifyMismatch && len(e.Mismatches) > 0
+}
+
+// SetWriteVerify 设置写操作成功后是否读回写入的范围并比较，默认不读回
+// 用于确认会静默限幅或忽略写入值的设备，单次写操作可以用 WithVerify 覆盖。
+// 广播（从站 ID 为 0）不读回。
+func (c *Client) SetWriteVerify(enable bool) *Client {
+	c.verify = enable
+	return c
+}
+
+// 判断本次写操作是否需要读回
+func (c *Client) verifyEnabled(opts []WriteOption) bool {
+	enabled := c.verify
+	if len(opts) > 0 { // 没有选项时不分配内存
+		var o writeOptions
+		for _, opt := range opts {
+			opt(&o)
+		}
+		if o.verify != nil {
+			enabled = *o.verify
+		}
+	}
+	return enabled && c.slaveID != 0
+}
+
+// 读回保持寄存器并比较
+func (c *Client) verifyRegisters(address uint16, expected []uint16) error {
+	actual, err := c.ReadHoldingRegisters(address, uint16(len(expected)))
+	if err != nil {
+		return &VerifyError{Table: TableHoldingRegisters, Err: err}
+	}
+	return compareValues(TableHoldingRegisters, address, expected, actual)
+}
+
+// 读回线圈并比较
+func (c *Client) verifyCoils(address uint16, expected []bool) error {
+	bits, err := c.ReadCoils(address, uint16(len(expected)))
+	if err != nil {
+		return &VerifyError{Table: TableCoils, Err: err}
+	}
+	return compareValues(TableCoils, address, boolValues(expected), boolValues(bits))
+}
+
+// 比较写入和读回的值，一致时返回 nil
+func compareValues(table Table, address uint16, expected, actual []uint16) error {
+	if len(actual) != len(expected) {
+		return &VerifyError{Table: table, Err: ErrQuantityMismatch}
+	}
+	var mismatches []Mismatch
+	for i := range expected {
+		if expected[i] != actual[i] {
+			mismatches = append(mismatches, Mismatch{
+				Address:  address + uint16(i),
+				Expected: expected[i],
+				Actual:   actual[i],
+			})
+		}
+	}
+	if len(mismatches) > 0 {
+		return &VerifyError{Table: table, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// 把线圈状态转换为 0 或 1
+func boolValues(bits []bool) []uint16 {
+	values := make([]uint16, len(bits))
+	for i, b := range bits {
+		if b {
+			values[i] = 1
+		}
+	}
+	return values
+}
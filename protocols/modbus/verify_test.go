@@ -0,0 +1,117 @@
+package modbus
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// 启动一个把保持寄存器限幅到 100、忽略线圈 7 写入的设备
+func startClampingDevice(t *testing.T) *Client {
+	t.Helper()
+	model := NewDataModel(100)
+	handler := HandlerFunc(func(req *Request) ([]byte, error) {
+		address, _, _ := req.AddressRange()
+		values, ok := RequestValues(req.FunctionCode, req.Data)
+		if !ok {
+			return model.ServeModbus(req)
+		}
+		switch req.FunctionCode {
+		case FuncWriteSingleRegister, FuncWriteMultipleRegisters:
+			for i := range values {
+				values[i] = min(values[i], 100)
+			}
+			model.Write(TableHoldingRegisters, address, values...)
+		case FuncWriteSingleCoil, FuncWriteMultipleCoils:
+			for i, v := range values {
+				if address+uint16(i) != 7 {
+					model.Write(TableCoils, address+uint16(i), v)
+				}
+			}
+		}
+		return req.Data[:4], nil // 照常确认
+	})
+	addr := startTCPServer(t, NewTCPServer(handler))
+	transport := NewTCPTransport(addr)
+	t.Cleanup(func() { transport.Close() })
+	return NewTCPClient(transport, 1)
+}
+
+func TestWriteVerify(t *testing.T) {
+	client := startClampingDevice(t)
+
+	// 默认不读回，限幅不会被发现
+	if err := client.WriteMultipleRegisters(0, []uint16{50, 150}); err != nil {
+		t.Fatalf("unverified write: %v", err)
+	}
+
+	err := client.WriteMultipleRegisters(10, []uint16{50, 150, 250}, WithVerify(true))
+	if !errors.Is(err, ErrVerifyMismatch) {
+		t.Fatalf("verified write: %v, want verify mismatch", err)
+	}
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("error %T does not contain *VerifyError", err)
+	}
+	want := []Mismatch{{Address: 11, Expected: 150, Actual: 100}, {Address: 12, Expected: 250, Actual: 100}}
+	if verifyErr.Table != TableHoldingRegisters || !slices.Equal(verifyErr.Mismatches, want) {
+		t.Errorf("mismatches = %+v, want %+v", verifyErr.Mismatches, want)
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.FunctionCode != FuncWriteMultipleRegisters || opErr.Address != 10 {
+		t.Errorf("error not wrapped in OpError for the write: %v", err)
+	}
+
+	// 客户端级别开启后对所有写操作生效，单次调用可以关闭
+	client.SetWriteVerify(true)
+	if err := client.WriteSingleRegister(20, 99); err != nil {
+		t.Errorf("write within range: %v", err)
+	}
+	if err := client.WriteSingleRegister(20, 101); !errors.Is(err, ErrVerifyMismatch) {
+		t.Errorf("clamped single register: %v, want verify mismatch", err)
+	}
+	if err := client.WriteSingleRegister(20, 101, WithVerify(false)); err != nil {
+		t.Errorf("write with verify disabled: %v", err)
+	}
+	if err := client.WriteMultipleCoils(5, []bool{true, true, true}); !errors.Is(err, ErrVerifyMismatch) {
+		t.Errorf("ignored coil: %v, want verify mismatch", err)
+	} else if !errors.As(err, &verifyErr) || !slices.Equal(verifyErr.Mismatches, []Mismatch{{Address: 7, Expected: 1, Actual: 0}}) {
+		t.Errorf("coil mismatches = %+v", verifyErr.Mismatches)
+	}
+	if err := client.WriteSingleCoil(8, true); err != nil {
+		t.Errorf("write coil: %v", err)
+	}
+
+	// 读回失败时返回读取的错误，不是不一致
+	err = client.WriteSingleRegister(99, 1)
+	if err != nil {
+		t.Fatalf("write last register: %v", err)
+	}
+	err = client.WriteMultipleRegisters(99, []uint16{1, 2})
+	if err == nil || errors.Is(err, ErrVerifyMismatch) {
+		t.Errorf("write beyond model: %v", err)
+	}
+}
+
+func TestVerifyReadBackFailure(t *testing.T) {
+	model := NewDataModel(10)
+	handler := HandlerFunc(func(req *Request) ([]byte, error) {
+		if req.FunctionCode == FuncReadHoldingRegisters {
+			return nil, ErrServerDeviceBusy
+		}
+		return model.ServeModbus(req)
+	})
+	addr := startTCPServer(t, NewTCPServer(handler))
+	transport := NewTCPTransport(addr)
+	defer transport.Close()
+	client := NewTCPClient(transport, 1).SetWriteVerify(true)
+
+	err := client.WriteSingleRegister(1, 5)
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) || verifyErr.Err == nil || !errors.Is(err, ErrServerDeviceBusy) {
+		t.Fatalf("error = %v, want read-back failure", err)
+	}
+	if errors.Is(err, ErrVerifyMismatch) {
+		t.Error("read-back failure reported as mismatch")
+	}
+}